@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -78,6 +79,24 @@ func (b *API) Send(msg config.Message) (string, error) {
 	return "", nil
 }
 
+// SendBatch queues msgs as one item, so they're streamed together. Both /api/messages and
+// /api/stream return the messages of a batch as separate messages.
+func (b *API) SendBatch(msgs []config.Message) error {
+	b.Lock()
+	defer b.Unlock()
+	batch := make([]config.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		// ignore delete messages
+		if msg.Event != config.EventMsgDelete {
+			batch = append(batch, msg)
+		}
+	}
+	if len(batch) > 0 {
+		b.Messages.Enqueue(batch)
+	}
+	return nil
+}
+
 func (b *API) handleHealthcheck(c echo.Context) error {
 	return c.String(http.StatusOK, "OK")
 }
@@ -91,8 +110,12 @@ func (b *API) handlePostMessage(c echo.Context) error {
 	message.Channel = "api"
 	message.Protocol = "api"
 	message.Account = b.Account
-	message.ID = ""
 	message.Timestamp = time.Now()
+	// updates refer to a message posted before, new messages get a new ID
+	// that can be used to update them later
+	if message.Event != config.EventMsgUpdate {
+		message.ID = strconv.FormatInt(message.Timestamp.UnixNano(), 10)
+	}
 	b.Log.Debugf("Sending message from %s on %s to gateway", message.Username, "api")
 	b.Remote <- message
 	return c.JSON(http.StatusOK, message)
@@ -101,11 +124,28 @@ func (b *API) handlePostMessage(c echo.Context) error {
 func (b *API) handleMessages(c echo.Context) error {
 	b.Lock()
 	defer b.Unlock()
-	c.JSONPretty(http.StatusOK, b.Messages.Values(), " ")
+	messages := []interface{}{}
+	for _, v := range b.Messages.Values() {
+		messages = append(messages, unbatch(v)...)
+	}
+	c.JSONPretty(http.StatusOK, messages, " ")
 	b.Messages = ring.Ring{}
 	return nil
 }
 
+// unbatch returns the messages of the queued item v, a message or a batch of messages.
+func unbatch(v interface{}) []interface{} {
+	batch, ok := v.([]config.Message)
+	if !ok {
+		return []interface{}{v}
+	}
+	messages := make([]interface{}, 0, len(batch))
+	for i := range batch {
+		messages = append(messages, &batch[i])
+	}
+	return messages
+}
+
 func (b *API) handleStream(c echo.Context) error {
 	c.Response().Header().Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	c.Response().WriteHeader(http.StatusOK)
@@ -118,10 +158,12 @@ func (b *API) handleStream(c echo.Context) error {
 	}
 	c.Response().Flush()
 	for {
-		msg := b.Messages.Dequeue()
-		if msg != nil {
-			if err := json.NewEncoder(c.Response()).Encode(msg); err != nil {
-				return err
+		item := b.Messages.Dequeue()
+		if item != nil {
+			for _, msg := range unbatch(item) {
+				if err := json.NewEncoder(c.Response()).Encode(msg); err != nil {
+					return err
+				}
 			}
 			c.Response().Flush()
 		}
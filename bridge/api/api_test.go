@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sendTestBatch queues a message and a batch of two messages on b.
+func sendTestBatch(t *testing.T, b *API) {
+	_, err := b.Send(config.Message{Text: "single", Username: "alice"})
+	require.NoError(t, err)
+	require.NoError(t, b.SendBatch([]config.Message{
+		{Text: "one", Username: "bob"},
+		{Event: config.EventMsgDelete},
+		{Text: "two", Username: "bob"},
+	}))
+}
+
+func TestHandleMessagesBatch(t *testing.T) {
+	b := &API{}
+	sendTestBatch(t, b)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/messages", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, b.handleMessages(echo.New().NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// the messages of a batch are returned as separate messages
+	var messages []config.Message
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &messages))
+	require.Len(t, messages, 3)
+	assert.Equal(t, "single", messages[0].Text)
+	assert.Equal(t, "one", messages[1].Text)
+	assert.Equal(t, "two", messages[2].Text)
+
+	// the messages are only returned once
+	rec = httptest.NewRecorder()
+	require.NoError(t, b.handleMessages(echo.New().NewContext(req, rec)))
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &messages))
+	assert.Empty(t, messages)
+}
+
+// streamRecorder is a http.ResponseWriter for handleStream, writes fail once it's closed
+// so the stream ends.
+type streamRecorder struct {
+	sync.Mutex
+	header http.Header
+	body   bytes.Buffer
+	closed bool
+}
+
+func (w *streamRecorder) Header() http.Header { return w.header }
+func (w *streamRecorder) WriteHeader(int)     {}
+func (w *streamRecorder) Flush()              {}
+
+func (w *streamRecorder) Write(p []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+	if w.closed {
+		return 0, errors.New("closed")
+	}
+	return w.body.Write(p)
+}
+
+func (w *streamRecorder) lines() []string {
+	w.Lock()
+	defer w.Unlock()
+	return strings.Split(strings.TrimSpace(w.body.String()), "\n")
+}
+
+func TestHandleStreamBatch(t *testing.T) {
+	b := &API{}
+	sendTestBatch(t, b)
+
+	w := &streamRecorder{header: http.Header{}}
+	done := make(chan error)
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, "/api/stream", nil)
+		done <- b.handleStream(echo.New().NewContext(req, w))
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(w.lines()) < 4 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// every line is one message, after the greeting
+	var texts []string
+	for _, line := range w.lines() {
+		var msg config.Message
+		require.NoError(t, json.Unmarshal([]byte(line), &msg))
+		texts = append(texts, msg.Text)
+	}
+	assert.Equal(t, []string{"", "single", "one", "two"}, texts)
+
+	// the next message ends the stream
+	w.Lock()
+	w.closed = true
+	w.Unlock()
+	_, err := b.Send(config.Message{Text: "last"})
+	require.NoError(t, err)
+	assert.Error(t, <-done)
+}
@@ -1,6 +1,7 @@
 package bridge
 
 import (
+	"context"
 	"log"
 	"strings"
 	"sync"
@@ -17,6 +18,43 @@ type Bridger interface {
 	Disconnect() error
 }
 
+// ReactionSender is implemented by bridgers that can add and remove reactions.
+// The message has EventReactionAdd or EventReactionRemove as event, the ID of the
+// reacted message as ID and the emoji as text.
+type ReactionSender interface {
+	SendReaction(msg config.Message) error
+}
+
+// MessagePinner is implemented by bridgers that can pin and unpin messages.
+// The message has EventMsgPin or EventMsgUnpin as event and the ID of the
+// pinned message as ID.
+type MessagePinner interface {
+	PinMessage(msg config.Message) error
+}
+
+// PollSender is implemented by bridgers that can send polls natively.
+type PollSender interface {
+	SendPoll(msg config.Message, poll config.Poll) (string, error)
+}
+
+// BatchSender is implemented by bridgers that can send multiple messages in one call,
+// see BatchWindow.
+type BatchSender interface {
+	SendBatch(msgs []config.Message) error
+}
+
+// FileUploader is implemented by bridgers that can upload a file on their own.
+// UploadFile uploads fi to msg.Channel and returns the remote URL of the uploaded file.
+type FileUploader interface {
+	UploadFile(msg config.Message, fi config.FileInfo) (string, error)
+}
+
+// ContextSender is implemented by bridgers that can stop sending a message when ctx is
+// done, see SendTimeout.
+type ContextSender interface {
+	SendContext(ctx context.Context, msg config.Message) (string, error)
+}
+
 type Bridge struct {
 	Bridger
 	*sync.RWMutex
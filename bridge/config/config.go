@@ -25,13 +25,30 @@ const (
 	EventAPIConnected      = "api_connected"
 	EventUserTyping        = "user_typing"
 	EventGetChannelMembers = "get_channel_members"
+	EventReactionAdd       = "reaction_add"
+	EventReactionRemove    = "reaction_remove"
+	EventMsgUpdate         = "msg_update"
+	EventMsgPin            = "msg_pin"
+	EventMsgUnpin          = "msg_unpin"
+	EventVoiceJoin         = "voice_join"
+	EventVoiceLeave        = "voice_leave"
+	EventReadReceipt       = "read_receipt"
+	EventMemberCount       = "member_count"
+)
+
+// User flags describe the role of the author of a message on the source bridge.
+const (
+	UserFlagOwner = "owner"
+	UserFlagAdmin = "admin"
+	UserFlagOp    = "op"
+	UserFlagVoice = "voice"
 )
 
 type Message struct {
 	Text      string    `json:"text"`
 	Channel   string    `json:"channel"`
 	Username  string    `json:"username"`
-	UserID    string    `json:"userid"` // userid on the bridge
+	UserID    string    `json:"userid"` // userid on the bridge, set by the bridge when receiving (ident@host on irc)
 	Avatar    string    `json:"avatar"`
 	Account   string    `json:"account"`
 	Event     string    `json:"event"`
@@ -40,9 +57,42 @@ type Message struct {
 	ParentID  string    `json:"parent_id"`
 	Timestamp time.Time `json:"timestamp"`
 	ID        string    `json:"id"`
+	UserFlags []string  `json:"user_flags"` // see UserFlagOwner etc
 	Extra     map[string][]interface{}
 }
 
+// ExtraTopic is the key in Message.Extra that contains the new topic (a string) of
+// an EventTopicChange, when the bridge knows it.
+const ExtraTopic = "topic"
+
+// ExtraLang is the key in Message.Extra that contains the language (a string, eg "en")
+// of the text detected by the LanguageDetector of the gateway.
+const ExtraLang = "lang"
+
+// ExtraPoll is the key in Message.Extra that contains a Poll.
+const ExtraPoll = "poll"
+
+// Poll is a poll sent as a message.
+type Poll struct {
+	Question string
+	Options  []string
+}
+
+// ExtraCount is the key in Message.Extra that contains the value (an uint64) of the message
+// counter of the gateway when it received the message, used by {COUNT}.
+const ExtraCount = "count"
+
+// ExtraDeadLetter is the key in Message.Extra that contains the DeadLetter information.
+const ExtraDeadLetter = "deadletter"
+
+// DeadLetter describes why a message was sent to a DeadLetterGateway.
+type DeadLetter struct {
+	Gateway string
+	Account string
+	Channel string
+	Error   string
+}
+
 type FileInfo struct {
 	Name    string
 	Data    *[]byte
@@ -73,93 +123,195 @@ type ChannelMember struct {
 type ChannelMembers []ChannelMember
 
 type Protocol struct {
-	AuthCode               string // steam
-	BindAddress            string // mattermost, slack // DEPRECATED
-	Buffer                 int    // api
-	Charset                string // irc
-	ClientID               string // msteams
-	ColorNicks             bool   // only irc for now
-	Debug                  bool   // general
-	DebugLevel             int    // only for irc now
-	DisableWebPagePreview  bool   // telegram
-	EditSuffix             string // mattermost, slack, discord, telegram, gitter
-	EditDisable            bool   // mattermost, slack, discord, telegram, gitter
-	IconURL                string // mattermost, slack
-	IgnoreFailureOnStart   bool   // general
-	IgnoreNicks            string // all protocols
-	IgnoreMessages         string // all protocols
-	Jid                    string // xmpp
-	JoinDelay              string // all protocols
-	Label                  string // all protocols
-	Login                  string // mattermost, matrix
+	AllowedFileTypes       []string // all protocols
+	AllowMessages          string   // all protocols
+	AttachmentDedupWindow  int      // all protocols
+	AttachmentOrder        string   // all protocols
+	AuthCode               string   // steam
+	BatchSize              int      // api
+	BatchWindow            int      // api
+	BindAddress            string   // mattermost, slack // DEPRECATED
+	BlockedFileTypes       []string // all protocols
+	BotNicks               []string // all protocols
+	BotUserIDs             []string // all protocols
+	Buffer                 int      // api
+	CacheUploads           bool     // discord
+	Charset                string   // irc
+	ClientID               string   // msteams
+	CoalesceWindow         int      // all protocols
+	CodeBlockHandling      string   // all protocols
+	ColorNicks             bool     // only irc for now
+	CommandPrefix          string   // all protocols
+	CustomEmojiFile        string   // all protocols
+	CustomEmojiHandling    string   // all protocols
+	Debug                  bool     // general
+	DebugLevel             int      // only for irc now
+	DebugSampleRate        float64  // general
+	DefaultAvatarURL       string   // mattermost, slack, discord
+	DeleteNoticeFormat     string   // all protocols
+	DeleteWindow           int      // all protocols
+	DisableWebPagePreview  bool     // telegram
+	DisconnectedBehavior   string   // all protocols
+	DropCommands           bool     // all protocols
+	EditDisplay            string   // all protocols
+	EditedToEmptyBehavior  string   // all protocols
+	EditNoticeFormat       string   // all protocols
+	EditSuffix             string   // mattermost, slack, discord, telegram, gitter
+	EditDisable            bool     // mattermost, slack, discord, telegram, gitter
+	ExcludeLabels          []string // all protocols
+	ForwardDelay           int      // all protocols
+	GravatarFallback       bool     // mattermost, slack, discord
+	HealthCheckAddr        string   // general
+	HealthCheckReconnect   bool     // general
+	HealthCheckToken       string   // general
+	HideRepeatedNick       bool     // all protocols
+	IconURL                string   // mattermost, slack
+	IgnoreFailureOnStart   bool     // general
+	IgnoreNicks            string   // all protocols
+	IgnoreMessages         string   // all protocols
+	IgnoreSelfMessages     bool     // all protocols
+	IgnoreUserIDs          string   // all protocols
+	InboundStripNickFormat string   // all protocols
+	Jid                    string   // xmpp
+	JoinDelay              string   // all protocols
+	JoinLeaveDigest        bool     // all protocols
+	JoinLeaveThrottle      int      // all protocols
+	Label                  string   // all protocols
+	LocalNicks             []string // all protocols
+	Login                  string   // mattermost, matrix
+	LoopDetectionThreshold int      // general
+	LoopDetectionWindow    int      // general
+	MaxAttachments         int      // all protocols
+	MaxAttachmentsNotice   bool     // all protocols
+	MaxMessageLength       int      // all protocols
+	MaxNickLength          int      // all protocols
 	MediaDownloadBlackList []string
 	MediaDownloadPath      string // Basically MediaServerUpload, but instead of uploading it, just write it to a file on the same server.
 	MediaDownloadSize      int    // all protocols
 	MediaServerDownload    string
 	MediaServerUpload      string
-	MediaConvertWebPToPNG  bool       // telegram
-	MessageDelay           int        // IRC, time in millisecond to wait between messages
-	MessageFormat          string     // telegram
-	MessageLength          int        // IRC, max length of a message allowed
-	MessageQueue           int        // IRC, size of message queue for flood control
-	MessageSplit           bool       // IRC, split long messages with newlines on MessageLength instead of clipping
-	Muc                    string     // xmpp
-	Name                   string     // all protocols
-	Nick                   string     // all protocols
-	NickFormatter          string     // mattermost, slack
-	NickServNick           string     // IRC
-	NickServUsername       string     // IRC
-	NickServPassword       string     // IRC
-	NicksPerRow            int        // mattermost, slack
-	NoHomeServerSuffix     bool       // matrix
-	NoSendJoinPart         bool       // all protocols
-	NoTLS                  bool       // mattermost
-	Password               string     // IRC,mattermost,XMPP,matrix
-	PrefixMessagesWithNick bool       // mattemost, slack
-	PreserveThreading      bool       // slack
-	Protocol               string     // all protocols
-	QuoteDisable           bool       // telegram
-	QuoteFormat            string     // telegram
-	QuoteLengthLimit       int        // telegram
-	RejoinDelay            int        // IRC
-	ReplaceMessages        [][]string // all protocols
-	ReplaceNicks           [][]string // all protocols
-	RemoteNickFormat       string     // all protocols
-	RunCommands            []string   // IRC
-	Server                 string     // IRC,mattermost,XMPP,discord
-	SessionFile            string     // msteams,whatsapp
-	ShowJoinPart           bool       // all protocols
-	ShowTopicChange        bool       // slack
-	ShowUserTyping         bool       // slack
-	ShowEmbeds             bool       // discord
-	SkipTLSVerify          bool       // IRC, mattermost
-	SkipVersionCheck       bool       // mattermost
-	StripNick              bool       // all protocols
-	SyncTopic              bool       // slack
-	TengoModifyMessage     string     // general
-	Team                   string     // mattermost, keybase
-	TeamID                 string     // msteams
-	TenantID               string     // msteams
-	Token                  string     // gitter, slack, discord, api
-	Topic                  string     // zulip
-	URL                    string     // mattermost, slack // DEPRECATED
-	UseAPI                 bool       // mattermost, slack
-	UseLocalAvatar         []string   // discord
-	UseSASL                bool       // IRC
-	UseTLS                 bool       // IRC
-	UseDiscriminator       bool       // discord
-	UseFirstName           bool       // telegram
-	UseUserName            bool       // discord
-	UseInsecureURL         bool       // telegram
-	VerboseJoinPart        bool       // IRC
-	WebhookBindAddress     string     // mattermost, slack
-	WebhookURL             string     // mattermost, slack
+	MediaConvertWebPToPNG  bool              // telegram
+	MessageDelay           int               // IRC, time in millisecond to wait between messages
+	MessageFormat          string            // telegram
+	MessageLength          int               // IRC, max length of a message allowed
+	MessageQueue           int               // IRC, size of message queue for flood control
+	MessageSplit           bool              // IRC, split long messages with newlines on MessageLength instead of clipping
+	MentionNicks           [][]string        // all protocols
+	MessageTextFormat      string            // all protocols
+	Muc                    string            // xmpp
+	Name                   string            // all protocols
+	Nick                   string            // all protocols
+	NickCollisionSuffix    string            // all protocols
+	NickColor              [][]string        // IRC
+	NickFormatter          string            // mattermost, slack
+	NickServNick           string            // IRC
+	NickServUsername       string            // IRC
+	NickServPassword       string            // IRC
+	NicksPerRow            int               // mattermost, slack
+	NoHomeServerSuffix     bool              // matrix
+	NoPingChar             string            // all protocols
+	NoSendJoinPart         bool              // all protocols
+	NormalizeWhitespace    bool              // all protocols
+	NotifyFileFailure      bool              // all protocols
+	NoTLS                  bool              // mattermost
+	ParallelSend           bool              // general
+	Password               string            // IRC,mattermost,XMPP,matrix
+	OnlyBridgeReplies      bool              // all protocols
+	OnlyFromLabels         []string          // all protocols
+	OnlyLanguages          []string          // all protocols
+	PayloadTemplate        string            // webhook
+	PollFormat             string            // all protocols
+	PrefixMessagesWithNick bool              // mattemost, slack
+	PreserveThreading      bool              // slack
+	PreserveTimestamp      bool              // all protocols
+	Protocol               string            // all protocols
+	QuoteDisable           bool              // telegram
+	QuoteFormat            string            // telegram
+	QuoteLengthLimit       int               // telegram
+	ReactionNotice         bool              // all protocols
+	ReactionSummaryWindow  int               // all protocols
+	RedactMessages         [][]string        // all protocols
+	RejoinDelay            int               // IRC
+	ReplaceMessages        [][]string        // all protocols
+	ReplaceNicks           [][]string        // all protocols
+	ReplyStyle             string            // all protocols
+	ResolveMentions        bool              // all protocols
+	RepeatSuppressInterval int               // all protocols
+	RepeatedNickWindow     int               // all protocols
+	RemoteNickFormat       string            // all protocols
+	RunCommands            []string          // IRC
+	SendQueueFile          string            // all protocols
+	SendTimeout            int               // all protocols
+	SendTimeoutRetries     int               // all protocols
+	Server                 string            // IRC,mattermost,XMPP,discord
+	SessionFile            string            // msteams,whatsapp
+	ShowJoinPart           bool              // all protocols
+	ShowMemberCount        int               // all protocols
+	ShowReadReceipts       int               // all protocols
+	ShowSourceChannel      bool              // all protocols
+	ShowTopicChange        bool              // slack
+	ShowVoiceActivity      bool              // all protocols
+	ShowUserTyping         bool              // slack
+	ShowEmbeds             bool              // discord
+	SigningSecret          string            // api, webhook
+	SkipTLSVerify          bool              // IRC, mattermost
+	SkipVersionCheck       bool              // mattermost
+	SmoothingRate          int               // all protocols
+	SourceChannelFormat    string            // all protocols
+	SplitLongMessages      bool              // all protocols
+	SplitStrategy          string            // all protocols
+	StripLeadingCommands   bool              // all protocols
+	StripNick              bool              // all protocols
+	StripNickReplacement   string            // all protocols
+	StripReplyQuote        bool              // all protocols
+	StripZeroWidth         bool              // all protocols
+	SyncTopic              bool              // slack
+	TargetLanguage         string            // all protocols
+	TengoModifyMessage     string            // general
+	TengoScriptData        map[string]string // general
+	Team                   string            // mattermost, keybase
+	TeamID                 string            // msteams
+	TenantID               string            // msteams
+	TextColor              [][]string        // IRC
+	ThreadAsChannel        string            // all protocols
+	TimestampFooter        string            // all protocols
+	TimestampTimezone      string            // all protocols
+	Token                  string            // gitter, slack, discord, api
+	Topic                  string            // zulip
+	TopicChangeFormat      string            // all protocols
+	Transliterate          bool              // all protocols
+	TruncateSuffix         string            // all protocols
+	TypingDebounce         int               // all protocols
+	URL                    string            // mattermost, slack // DEPRECATED
+	UseAPI                 bool              // mattermost, slack
+	UseLocalAvatar         []string          // discord
+	UserFlagPrefixes       [][]string        // all protocols
+	UseSASL                bool              // IRC
+	UseTLS                 bool              // IRC
+	UseDiscriminator       bool              // discord
+	UsernamePrefix         string            // all protocols
+	UsernameSuffix         string            // all protocols
+	UseFirstName           bool              // telegram
+	UseUserName            bool              // discord
+	UnfurlLinks            bool              // all protocols
+	UnresolvedMention      string            // all protocols
+	UseInsecureURL         bool              // telegram
+	VerboseJoinPart        bool              // IRC
+	WebhookBindAddress     string            // mattermost, slack
+	WebhookURL             string            // mattermost, slack, webhook
 }
 
 type ChannelOptions struct {
 	Key        string // irc, xmpp
 	WebhookURL string // discord
 	Topic      string // zulip
+	// ShowJoinPart overrides the ShowJoinPart of the bridge for this channel when set.
+	ShowJoinPart *bool // all protocols
+	// ForwardFiles set to false drops the files of the messages sent to this channel.
+	ForwardFiles *bool // all protocols
+	// MediaOnly only sends messages with files to this channel, TextOnly only messages without.
+	MediaOnly bool // all protocols
+	TextOnly  bool // all protocols
 }
 
 type Bridge struct {
@@ -170,11 +322,26 @@ type Bridge struct {
 }
 
 type Gateway struct {
-	Name   string
-	Enable bool
-	In     []Bridge
-	Out    []Bridge
-	InOut  []Bridge
+	Name                 string
+	Enable               bool
+	CountFile            string
+	ArchiveFile          string
+	ArchiveFormat        string
+	ArchiveMaxSize       int64
+	DeadLetterGateway    string
+	ActiveHours          string
+	OutsideHoursBehavior string
+	DigestMaxMessages    int
+	KeywordRoutes        [][]string
+	TransformOrder       []string
+	MaxConcurrentSends   int
+	ShowProtocolIcon     bool
+	ProtocolIcons        map[string]string
+	BroadcastNick        string
+	NickAliases          map[string]string
+	In                   []Bridge
+	Out                  []Bridge
+	InOut                []Bridge
 }
 
 type Tengo struct {
@@ -36,6 +36,9 @@ type Bdiscord struct {
 	nickMemberMap map[string]*discordgo.Member
 	webhookCache  map[string]string
 	webhookMutex  sync.RWMutex
+
+	voiceMutex    sync.Mutex
+	voiceChannels map[string]string // user ID to the ID of the voice channel the user is in
 }
 
 func New(cfg *bridge.Config) bridge.Bridger {
@@ -44,6 +47,7 @@ func New(cfg *bridge.Config) bridge.Bridger {
 	b.nickMemberMap = make(map[string]*discordgo.Member)
 	b.channelInfoMap = make(map[string]*config.ChannelInfo)
 	b.webhookCache = make(map[string]string)
+	b.voiceChannels = make(map[string]string)
 	if b.GetString("WebhookURL") != "" {
 		b.Log.Debug("Configuring Discord Incoming Webhook")
 		b.webhookID, b.webhookToken = b.splitURL(b.GetString("WebhookURL"))
@@ -80,8 +84,11 @@ func (b *Bdiscord) Connect() error {
 	b.c.AddHandler(b.messageUpdate)
 	b.c.AddHandler(b.messageDelete)
 	b.c.AddHandler(b.messageDeleteBulk)
+	b.c.AddHandler(b.messageReactionAdd)
+	b.c.AddHandler(b.messageReactionRemove)
 	b.c.AddHandler(b.memberAdd)
 	b.c.AddHandler(b.memberRemove)
+	b.c.AddHandler(b.voiceStateUpdate)
 	err = b.c.Open()
 	if err != nil {
 		return err
@@ -327,6 +334,24 @@ func (b *Bdiscord) Send(msg config.Message) (string, error) {
 	return res.ID, nil
 }
 
+// SendReaction adds or removes our reaction msg.Text on the message msg.ID.
+func (b *Bdiscord) SendReaction(msg config.Message) error {
+	b.Log.Debugf("=> Receiving reaction %#v", msg)
+
+	channelID := b.getChannelID(msg.Channel)
+	if channelID == "" {
+		return fmt.Errorf("Could not find channelID for %v", msg.Channel)
+	}
+
+	// the message may have been replaced by a webhook edit
+	msgID := b.getCacheID(msg.ID)
+
+	if msg.Event == config.EventReactionRemove {
+		return b.c.MessageReactionRemove(channelID, msgID, msg.Text, "@me")
+	}
+	return b.c.MessageReactionAdd(channelID, msgID, msg.Text)
+}
+
 // useWebhook returns true if we have a webhook defined somewhere
 func (b *Bdiscord) useWebhook() bool {
 	if b.GetString("WebhookURL") != "" {
@@ -369,26 +394,48 @@ func (b *Bdiscord) isWebhookID(id string) bool {
 
 // handleUploadFile handles native upload of files
 func (b *Bdiscord) handleUploadFile(msg *config.Message, channelID string) (string, error) {
-	var err error
 	for _, f := range msg.Extra["file"] {
 		fi := f.(config.FileInfo)
-		file := discordgo.File{
-			Name:        fi.Name,
-			ContentType: "",
-			Reader:      bytes.NewReader(*fi.Data),
-		}
-		m := discordgo.MessageSend{
-			Content: msg.Username + fi.Comment,
-			Files:   []*discordgo.File{&file},
-		}
-		_, err = b.c.ChannelMessageSendComplex(channelID, &m)
-		if err != nil {
-			return "", fmt.Errorf("file upload failed: %s", err)
+		if _, err := b.uploadFile(msg, &fi, channelID); err != nil {
+			return "", err
 		}
 	}
 	return "", nil
 }
 
+// UploadFile uploads the file fi of msg and returns the URL of the attachment.
+func (b *Bdiscord) UploadFile(msg config.Message, fi config.FileInfo) (string, error) {
+	channelID := b.getChannelID(msg.Channel)
+	if channelID == "" {
+		return "", fmt.Errorf("Could not find channelID for %v", msg.Channel)
+	}
+	res, err := b.uploadFile(&msg, &fi, channelID)
+	if err != nil {
+		return "", err
+	}
+	if len(res.Attachments) == 0 {
+		return "", nil
+	}
+	return res.Attachments[0].URL, nil
+}
+
+func (b *Bdiscord) uploadFile(msg *config.Message, fi *config.FileInfo, channelID string) (*discordgo.Message, error) {
+	file := discordgo.File{
+		Name:        fi.Name,
+		ContentType: "",
+		Reader:      bytes.NewReader(*fi.Data),
+	}
+	m := discordgo.MessageSend{
+		Content: msg.Username + fi.Comment,
+		Files:   []*discordgo.File{&file},
+	}
+	res, err := b.c.ChannelMessageSendComplex(channelID, &m)
+	if err != nil {
+		return nil, fmt.Errorf("file upload failed: %s", err)
+	}
+	return res, nil
+}
+
 // webhookSend send one or more message via webhook, taking care of file
 // uploads (from slack, telegram or mattermost).
 // Returns messageID and error.
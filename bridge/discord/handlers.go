@@ -31,6 +31,35 @@ func (b *Bdiscord) messageDeleteBulk(s *discordgo.Session, m *discordgo.MessageD
 	}
 }
 
+func (b *Bdiscord) messageReactionAdd(s *discordgo.Session, m *discordgo.MessageReactionAdd) { //nolint:unparam
+	b.handleReaction(m.MessageReaction, config.EventReactionAdd)
+}
+
+func (b *Bdiscord) messageReactionRemove(s *discordgo.Session, m *discordgo.MessageReactionRemove) { //nolint:unparam
+	b.handleReaction(m.MessageReaction, config.EventReactionRemove)
+}
+
+func (b *Bdiscord) handleReaction(m *discordgo.MessageReaction, event string) {
+	// Ignore our own reactions
+	if m.UserID == b.userID {
+		return
+	}
+
+	rmsg := config.Message{
+		Account:  b.Account,
+		ID:       m.MessageID,
+		Event:    event,
+		Text:     m.Emoji.APIName(),
+		UserID:   m.UserID,
+		Username: b.getNick(&discordgo.User{ID: m.UserID}, m.GuildID),
+		Channel:  b.getChannelName(m.ChannelID),
+	}
+
+	b.Log.Debugf("<= Sending message from %s to gateway", b.Account)
+	b.Log.Debugf("<= Message is %#v", rmsg)
+	b.Remote <- rmsg
+}
+
 func (b *Bdiscord) messageTyping(s *discordgo.Session, m *discordgo.TypingStart) {
 	if !b.GetBool("ShowUserTyping") {
 		return
@@ -201,6 +230,65 @@ func (b *Bdiscord) memberRemove(s *discordgo.Session, m *discordgo.GuildMemberRe
 	b.Remote <- rmsg
 }
 
+func (b *Bdiscord) voiceStateUpdate(s *discordgo.Session, m *discordgo.VoiceStateUpdate) {
+	if m.GuildID != b.guildID || m.UserID == b.userID {
+		return
+	}
+	previous := b.updateVoiceChannel(m.UserID, m.ChannelID)
+	// mute, deafen etc also send an update
+	if previous == m.ChannelID {
+		return
+	}
+
+	username := b.getVoiceNick(s, m.UserID, m.GuildID)
+	if previous != "" {
+		b.sendVoiceActivity(config.EventVoiceLeave, username, m.UserID, previous)
+	}
+	if m.ChannelID != "" {
+		b.sendVoiceActivity(config.EventVoiceJoin, username, m.UserID, m.ChannelID)
+	}
+}
+
+// updateVoiceChannel records that userID is in the voice channel channelID, which is empty
+// when the user left voice, and returns the voice channel the user was in before.
+func (b *Bdiscord) updateVoiceChannel(userID, channelID string) string {
+	b.voiceMutex.Lock()
+	defer b.voiceMutex.Unlock()
+	previous := b.voiceChannels[userID]
+	if channelID == "" {
+		delete(b.voiceChannels, userID)
+	} else {
+		b.voiceChannels[userID] = channelID
+	}
+	return previous
+}
+
+// getVoiceNick returns the nick of userID, voice state updates only contain the user ID.
+func (b *Bdiscord) getVoiceNick(s *discordgo.Session, userID, guildID string) string {
+	user := &discordgo.User{ID: userID}
+	if member, err := s.State.Member(guildID, userID); err == nil && member.User != nil {
+		user = member.User
+	} else if u, err := s.User(userID); err == nil {
+		user = u
+	}
+	return b.getNick(user, guildID)
+}
+
+// sendVoiceActivity sends the voice activity event of username in the voice channel channelID
+// to the gateway, which formats it. Like joins and leaves it is for the whole bridge.
+func (b *Bdiscord) sendVoiceActivity(event, username, userID, channelID string) {
+	rmsg := config.Message{
+		Account:  b.Account,
+		Event:    event,
+		Username: username,
+		UserID:   userID,
+		Text:     b.getChannelName(channelID),
+	}
+	b.Log.Debugf("<= Sending message from %s to gateway", b.Account)
+	b.Log.Debugf("<= Message is %#v", rmsg)
+	b.Remote <- rmsg
+}
+
 func handleEmbed(embed *discordgo.MessageEmbed) string {
 	var t []string
 	var result string
@@ -56,3 +56,12 @@ func TestHandleEmbed(t *testing.T) {
 		assert.Equalf(t, tc.result, handleEmbed(tc.embed), "Testcases %s", name)
 	}
 }
+
+func TestUpdateVoiceChannel(t *testing.T) {
+	b := &Bdiscord{voiceChannels: make(map[string]string)}
+	assert.Equal(t, "", b.updateVoiceChannel("alice", "voice1"))
+	assert.Equal(t, "voice1", b.updateVoiceChannel("alice", "voice1"))
+	assert.Equal(t, "voice1", b.updateVoiceChannel("alice", "voice2"))
+	assert.Equal(t, "voice2", b.updateVoiceChannel("alice", ""))
+	assert.Empty(t, b.voiceChannels)
+}
@@ -166,11 +166,38 @@ func (b *Birc) handleOtherAuth(client *girc.Client, event girc.Event) {
 	}
 }
 
+// getUserFlags returns the user flags of nick based on its modes on channel.
+func getUserFlags(client *girc.Client, nick, channel string) []string {
+	user := client.LookupUser(nick)
+	if user == nil {
+		return nil
+	}
+	perms, ok := user.Perms.Lookup(channel)
+	if !ok {
+		return nil
+	}
+	var flags []string
+	if perms.Owner {
+		flags = append(flags, config.UserFlagOwner)
+	}
+	if perms.Admin {
+		flags = append(flags, config.UserFlagAdmin)
+	}
+	if perms.Op || perms.HalfOp {
+		flags = append(flags, config.UserFlagOp)
+	}
+	if perms.Voice {
+		flags = append(flags, config.UserFlagVoice)
+	}
+	return flags
+}
+
 func (b *Birc) handlePrivMsg(client *girc.Client, event girc.Event) {
 	if b.skipPrivMsg(event) {
 		return
 	}
 	rmsg := config.Message{Username: event.Source.Name, Channel: strings.ToLower(event.Params[0]), Account: b.Account, UserID: event.Source.Ident + "@" + event.Source.Host}
+	rmsg.UserFlags = getUserFlags(client, event.Source.Name, rmsg.Channel)
 	b.Log.Debugf("== Receiving PRIVMSG: %s %s %#v", event.Source.Name, event.Last(), event)
 
 	// set action event
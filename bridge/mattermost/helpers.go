@@ -1,6 +1,7 @@
 package bmattermost
 
 import (
+	"context"
 	"strings"
 
 	"github.com/42wim/matterbridge/bridge/config"
@@ -104,7 +105,7 @@ func (b *Bmattermost) cacheAvatar(msg *config.Message) (string, error) {
 }
 
 // sendWebhook uses the configured WebhookURL to send the message
-func (b *Bmattermost) sendWebhook(msg config.Message) (string, error) {
+func (b *Bmattermost) sendWebhook(ctx context.Context, msg config.Message) (string, error) {
 	// skip events
 	if msg.Event != "" {
 		return "", nil
@@ -126,7 +127,7 @@ func (b *Bmattermost) sendWebhook(msg config.Message) (string, error) {
 				Props:    make(map[string]interface{}),
 			}
 			matterMessage.Props["matterbridge_"+b.uuid] = true
-			if err := b.mh.Send(matterMessage); err != nil {
+			if err := b.mh.SendContext(ctx, matterMessage); err != nil {
 				b.Log.Errorf("sendWebhook failed: %s ", err)
 			}
 		}
@@ -154,7 +155,7 @@ func (b *Bmattermost) sendWebhook(msg config.Message) (string, error) {
 		matterMessage.IconURL = msg.Avatar
 	}
 	matterMessage.Props["matterbridge_"+b.uuid] = true
-	err := b.mh.Send(matterMessage)
+	err := b.mh.SendContext(ctx, matterMessage)
 	if err != nil {
 		b.Log.Info(err)
 		return "", err
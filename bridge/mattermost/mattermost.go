@@ -1,6 +1,7 @@
 package bmattermost
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -93,6 +94,12 @@ func (b *Bmattermost) JoinChannel(channel config.ChannelInfo) error {
 }
 
 func (b *Bmattermost) Send(msg config.Message) (string, error) {
+	return b.SendContext(context.Background(), msg)
+}
+
+// SendContext sends msg like Send, messages sent with the WebhookURL are cancelled when
+// ctx is done.
+func (b *Bmattermost) SendContext(ctx context.Context, msg config.Message) (string, error) {
 	if b.Account == mattermostPlugin {
 		return "", nil
 	}
@@ -110,7 +117,7 @@ func (b *Bmattermost) Send(msg config.Message) (string, error) {
 
 	// Use webhook to send the message
 	if b.GetString("WebhookURL") != "" {
-		return b.sendWebhook(msg)
+		return b.sendWebhook(ctx, msg)
 	}
 
 	// Delete message
@@ -76,6 +76,24 @@ func (b *Bslack) handleSlackClient(messages chan *config.Message) {
 				continue
 			}
 			messages <- rmsg
+		case *slack.PinAddedEvent:
+			rmsg, err := b.handlePinEvent(config.EventMsgPin, ev.User, ev.Channel, ev.Item)
+			if err == ErrEventIgnored {
+				continue
+			} else if err != nil {
+				b.Log.Errorf("%#v", err)
+				continue
+			}
+			messages <- rmsg
+		case *slack.PinRemovedEvent:
+			rmsg, err := b.handlePinEvent(config.EventMsgUnpin, ev.User, ev.Channel, ev.Item)
+			if err == ErrEventIgnored {
+				continue
+			} else if err != nil {
+				b.Log.Errorf("%#v", err)
+				continue
+			}
+			messages <- rmsg
 		case *slack.OutgoingErrorEvent:
 			b.Log.Debugf("%#v", ev.Error())
 		case *slack.ChannelJoinedEvent:
@@ -232,6 +250,9 @@ func (b *Bslack) handleStatusEvent(ev *slack.MessageEvent, rmsg *config.Message)
 	case sChannelTopic, sChannelPurpose:
 		b.channels.populateChannels(false)
 		rmsg.Event = config.EventTopicChange
+		if ev.SubType == sChannelTopic {
+			rmsg.Extra[config.ExtraTopic] = []interface{}{ev.Topic}
+		}
 	case sMessageChanged:
 		rmsg.Text = ev.SubMessage.Text
 		// handle deleted thread starting messages
@@ -300,6 +321,30 @@ func (b *Bslack) handleTypingEvent(ev *slack.UserTypingEvent) (*config.Message,
 	}, nil
 }
 
+// handlePinEvent handles the pin or unpin of a message by userID.
+func (b *Bslack) handlePinEvent(event, userID, channelID string, item slack.Item) (*config.Message, error) {
+	if userID == b.si.User.ID || item.Message == nil {
+		return nil, ErrEventIgnored
+	}
+	channelInfo, err := b.channels.getChannelByID(channelID)
+	if err != nil {
+		return nil, err
+	}
+	rmsg := &config.Message{
+		Username: b.users.getUsername(userID),
+		UserID:   userID,
+		Channel:  channelInfo.Name,
+		Account:  b.Account,
+		ID:       item.Message.Timestamp,
+		Event:    event,
+		Protocol: b.Protocol,
+	}
+	if b.useChannelID {
+		rmsg.Channel = "ID:" + channelInfo.ID
+	}
+	return rmsg, nil
+}
+
 // handleDownloadFile handles file download
 func (b *Bslack) handleDownloadFile(rmsg *config.Message, file *slack.File, retry bool) error {
 	if b.fileCached(file) {
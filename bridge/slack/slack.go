@@ -381,6 +381,27 @@ func (b *Bslack) handleTopicOrPurpose(msg *config.Message, channelInfo *slack.Ch
 	return true, nil
 }
 
+// PinMessage pins or unpins the message msg.ID.
+func (b *Bslack) PinMessage(msg config.Message) error {
+	channelInfo, err := b.channels.getChannel(msg.Channel)
+	if err != nil {
+		return fmt.Errorf("could not pin message: %v", err)
+	}
+	pinFunc := b.rtm.AddPin
+	if msg.Event == config.EventMsgUnpin {
+		pinFunc = b.rtm.RemovePin
+	}
+	for {
+		err := pinFunc(channelInfo.ID, slack.NewRefToMessage(channelInfo.ID, msg.ID))
+		if err == nil {
+			return nil
+		}
+		if err = handleRateLimit(b.Log, err); err != nil {
+			return err
+		}
+	}
+}
+
 func (b *Bslack) deleteMessage(msg *config.Message, channelInfo *slack.Channel) (bool, error) {
 	if msg.Event != config.EventMsgDelete {
 		return false, nil
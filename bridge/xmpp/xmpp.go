@@ -233,8 +233,11 @@ func (b *Bxmpp) handleXMPP() error {
 				}
 
 				var event string
-				if strings.Contains(v.Text, "has set the subject to:") {
+				var extra map[string][]interface{}
+				if i := strings.Index(v.Text, "has set the subject to:"); i >= 0 {
 					event = config.EventTopicChange
+					topic := strings.TrimSpace(v.Text[i+len("has set the subject to:"):])
+					extra = map[string][]interface{}{config.ExtraTopic: {topic}}
 				}
 
 				avatar := getAvatar(b.avatarMap, v.Remote, b.General)
@@ -256,6 +259,7 @@ func (b *Bxmpp) handleXMPP() error {
 					UserID:   v.Remote,
 					ID:       msgID,
 					Event:    event,
+					Extra:    extra,
 				}
 
 				// Check if we have an action event.
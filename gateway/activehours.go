@@ -0,0 +1,200 @@
+package gateway
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+const (
+	// outsideHoursQueue is the OutsideHoursBehavior that relays the messages received
+	// outside ActiveHours when the gateway becomes active again.
+	outsideHoursQueue = "queue"
+
+	// outsideHoursDigest is the OutsideHoursBehavior for quiet hours, the messages received
+	// outside ActiveHours are announced in a single digest when the gateway is active again.
+	outsideHoursDigest = "digest"
+
+	// defaultDigestMaxMessages is the number of messages in a digest without DigestMaxMessages.
+	defaultDigestMaxMessages = 50
+
+	// maxOutsideHoursQueue is the maximum amount of messages kept outside ActiveHours.
+	maxOutsideHoursQueue = 1000
+)
+
+// activeHours is the daily time window in which a gateway relays messages.
+type activeHours struct {
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+// parseActiveHours parses a window like "09:00-17:00", optionally followed by a
+// timezone like "09:00-17:00 Europe/Brussels". The local timezone is used by default.
+// An empty window returns nil, which is always active.
+func parseActiveHours(window string) (*activeHours, error) {
+	if window == "" {
+		return nil, nil
+	}
+	fields := strings.Fields(window)
+	if len(fields) > 2 {
+		return nil, fmt.Errorf("invalid ActiveHours %#v", window)
+	}
+	location := time.Local
+	if len(fields) == 2 {
+		var err error
+		if location, err = time.LoadLocation(fields[1]); err != nil {
+			return nil, err
+		}
+	}
+	times := strings.Split(fields[0], "-")
+	if len(times) != 2 {
+		return nil, fmt.Errorf("invalid ActiveHours %#v", window)
+	}
+	start, err := parseClock(times[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(times[1])
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, fmt.Errorf("ActiveHours %#v is empty", window)
+	}
+	return &activeHours{start: start, end: end, location: location}, nil
+}
+
+// parseClock returns the time since midnight of a time like "17:00".
+func parseClock(clock string) (time.Duration, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// midnight returns the start of the day of t in the timezone of the window.
+func (a *activeHours) midnight(t time.Time) time.Time {
+	t = t.In(a.location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, a.location)
+}
+
+// active returns true if t falls within the window.
+func (a *activeHours) active(t time.Time) bool {
+	if a == nil {
+		return true
+	}
+	since := t.Sub(a.midnight(t))
+	if a.start < a.end {
+		return since >= a.start && since < a.end
+	}
+	// the window spans midnight, eg 22:00-06:00
+	return since >= a.start || since < a.end
+}
+
+// next returns the first start of the window after t.
+func (a *activeHours) next(t time.Time) time.Time {
+	midnight := a.midnight(t)
+	start := midnight.Add(a.start)
+	if !start.After(t) {
+		start = midnight.AddDate(0, 0, 1).Add(a.start)
+	}
+	return start
+}
+
+// holdOutsideActiveHours returns true if msg must not be relayed now because gw is outside
+// its ActiveHours. Depending on OutsideHoursBehavior msg is dropped or queued until gw
+// is active again.
+func (gw *Gateway) holdOutsideActiveHours(msg *config.Message) bool {
+	now := gw.now()
+	if gw.activeHours.active(now) {
+		// relay what was queued first so the order is kept
+		gw.flushOutsideActiveHours()
+		return false
+	}
+	behavior := gw.MyConfig.OutsideHoursBehavior
+	if behavior == outsideHoursDigest && !isDigestable(msg) ||
+		behavior != outsideHoursQueue && behavior != outsideHoursDigest {
+		gw.logger.Debugf("outside ActiveHours of gateway %s, dropping message from %s", gw.Name, msg.Account)
+		return true
+	}
+	if behavior == outsideHoursDigest && len(gw.outsideHoursQueue) >= gw.digestMaxMessages() {
+		gw.digestOverflow++
+		gw.startOutsideHoursTimer(now)
+		return true
+	}
+	if len(gw.outsideHoursQueue) >= maxOutsideHoursQueue {
+		gw.logger.Warnf("outside ActiveHours of gateway %s and queue is full, dropping message from %s", gw.Name, msg.Account)
+		return true
+	}
+	gw.logger.Debugf("outside ActiveHours of gateway %s, queueing message from %s", gw.Name, msg.Account)
+	gw.outsideHoursQueue = append(gw.outsideHoursQueue, *msg)
+	gw.startOutsideHoursTimer(now)
+	return true
+}
+
+// startOutsideHoursTimer flushes the queue of gw at the next start of ActiveHours.
+func (gw *Gateway) startOutsideHoursTimer(now time.Time) {
+	if gw.outsideHoursTimer == nil {
+		gw.outsideHoursTimer = time.AfterFunc(gw.activeHours.next(now).Sub(now), func() {
+			gw.Router.activeHoursStart <- gw
+		})
+	}
+}
+
+// isDigestable returns true if msg goes in the digest of quiet hours, events like joins
+// or edits are dropped.
+func isDigestable(msg *config.Message) bool {
+	return msg.Text != "" && (msg.Event == "" || msg.Event == config.EventUserAction)
+}
+
+// digestMaxMessages returns the DigestMaxMessages of gw, messages after it are only counted.
+func (gw *Gateway) digestMaxMessages() int {
+	max := gw.MyConfig.DigestMaxMessages
+	switch {
+	case max <= 0:
+		return defaultDigestMaxMessages
+	case max > maxOutsideHoursQueue:
+		return maxOutsideHoursQueue
+	}
+	return max
+}
+
+// outsideHoursDigestText returns the digest of the messages in queue, like
+// "[irc] alice: hello", followed by a line with the number of overflow messages.
+func outsideHoursDigestText(queue []config.Message, overflow int) string {
+	lines := []string{fmt.Sprintf("%d messages during quiet hours:", len(queue)+overflow)}
+	for _, msg := range queue {
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s", msg.Protocol, msg.Username, msg.Text))
+	}
+	if overflow > 0 {
+		lines = append(lines, fmt.Sprintf("(+%d more messages)", overflow))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// flushOutsideActiveHours relays the messages queued outside ActiveHours, or announces
+// their digest.
+func (gw *Gateway) flushOutsideActiveHours() {
+	if gw.outsideHoursTimer != nil {
+		gw.outsideHoursTimer.Stop()
+		gw.outsideHoursTimer = nil
+	}
+	queue, overflow := gw.outsideHoursQueue, gw.digestOverflow
+	gw.outsideHoursQueue, gw.digestOverflow = nil, 0
+	if gw.isClosed() {
+		return
+	}
+	if gw.MyConfig.OutsideHoursBehavior == outsideHoursDigest {
+		if len(queue)+overflow > 0 {
+			gw.announce(outsideHoursDigestText(queue, overflow))
+		}
+		return
+	}
+	for i := range queue {
+		gw.relayMessage(&queue[i], true)
+	}
+}
@@ -0,0 +1,240 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseActiveHours(t *testing.T) {
+	brussels, err := time.LoadLocation("Europe/Brussels")
+	require.NoError(t, err)
+
+	parseTests := map[string]struct {
+		input  string
+		output *activeHours
+		err    bool
+	}{
+		"empty": {
+			input:  "",
+			output: nil,
+		},
+		"local": {
+			input:  "09:00-17:30",
+			output: &activeHours{start: 9 * time.Hour, end: 17*time.Hour + 30*time.Minute, location: time.Local},
+		},
+		"timezone": {
+			input:  "22:00-06:00 Europe/Brussels",
+			output: &activeHours{start: 22 * time.Hour, end: 6 * time.Hour, location: brussels},
+		},
+		"unknown timezone": {
+			input: "09:00-17:00 Nowhere/Special",
+			err:   true,
+		},
+		"no range": {
+			input: "09:00",
+			err:   true,
+		},
+		"invalid time": {
+			input: "9am-5pm",
+			err:   true,
+		},
+		"empty window": {
+			input: "09:00-09:00",
+			err:   true,
+		},
+	}
+	for testname, testcase := range parseTests {
+		output, err := parseActiveHours(testcase.input)
+		if testcase.err {
+			assert.Errorf(t, err, "case '%s' failed", testname)
+			continue
+		}
+		assert.NoErrorf(t, err, "case '%s' failed", testname)
+		assert.Equalf(t, testcase.output, output, "case '%s' failed", testname)
+	}
+}
+
+func TestActiveHoursActive(t *testing.T) {
+	day, err := parseActiveHours("09:00-17:00 UTC")
+	require.NoError(t, err)
+	night, err := parseActiveHours("22:00-06:00 UTC")
+	require.NoError(t, err)
+	brussels, err := parseActiveHours("09:00-17:00 Europe/Brussels")
+	require.NoError(t, err)
+
+	activeTests := map[string]struct {
+		window *activeHours
+		time   time.Time
+		output bool
+	}{
+		"no window": {
+			window: nil,
+			time:   time.Date(2020, 6, 1, 3, 0, 0, 0, time.UTC),
+			output: true,
+		},
+		"day in window": {
+			window: day,
+			time:   time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+			output: true,
+		},
+		"day at start": {
+			window: day,
+			time:   time.Date(2020, 6, 1, 9, 0, 0, 0, time.UTC),
+			output: true,
+		},
+		"day at end": {
+			window: day,
+			time:   time.Date(2020, 6, 1, 17, 0, 0, 0, time.UTC),
+			output: false,
+		},
+		"day before window": {
+			window: day,
+			time:   time.Date(2020, 6, 1, 8, 59, 0, 0, time.UTC),
+			output: false,
+		},
+		"night before midnight": {
+			window: night,
+			time:   time.Date(2020, 6, 1, 23, 0, 0, 0, time.UTC),
+			output: true,
+		},
+		"night after midnight": {
+			window: night,
+			time:   time.Date(2020, 6, 1, 5, 0, 0, 0, time.UTC),
+			output: true,
+		},
+		"night during day": {
+			window: night,
+			time:   time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+			output: false,
+		},
+		"timezone in window": {
+			window: brussels,
+			time:   time.Date(2020, 6, 1, 8, 0, 0, 0, time.UTC),
+			output: true,
+		},
+		"timezone outside window": {
+			window: brussels,
+			time:   time.Date(2020, 6, 1, 16, 0, 0, 0, time.UTC),
+			output: false,
+		},
+	}
+	for testname, testcase := range activeTests {
+		assert.Equalf(t, testcase.output, testcase.window.active(testcase.time), "case '%s' failed", testname)
+	}
+}
+
+func TestActiveHoursNext(t *testing.T) {
+	day, err := parseActiveHours("09:00-17:00 UTC")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 6, 1, 9, 0, 0, 0, time.UTC), day.next(time.Date(2020, 6, 1, 3, 0, 0, 0, time.UTC)))
+	assert.Equal(t, time.Date(2020, 6, 2, 9, 0, 0, 0, time.UTC), day.next(time.Date(2020, 6, 1, 18, 0, 0, 0, time.UTC)))
+}
+
+var testconfigActiveHours = []byte(`
+[irc.test]
+server=""
+[slack.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+activehours="09:00-17:00 UTC"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+`)
+
+func TestHoldOutsideActiveHours(t *testing.T) {
+	for _, behavior := range []string{"drop", outsideHoursQueue} {
+		r := maketestRouterWithMap(testconfigActiveHours, testBridgeMap)
+		gw := r.Gateways["main"]
+		gw.MyConfig.OutsideHoursBehavior = behavior
+		slack := testBridgerOf(gw, "slack.test")
+
+		now := time.Date(2020, 6, 1, 8, 0, 0, 0, time.UTC)
+		gw.now = func() time.Time { return now }
+
+		r.relayMessage(config.Message{Text: "early", Username: "user", Account: "irc.test", Channel: "#main"})
+		assert.Emptyf(t, slack.messages(), "behavior '%s' failed", behavior)
+
+		now = time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+		r.relayMessage(config.Message{Text: "in time", Username: "user", Account: "irc.test", Channel: "#main"})
+
+		var texts []string
+		for _, msg := range slack.messages() {
+			texts = append(texts, msg.Text)
+		}
+		if behavior == outsideHoursQueue {
+			assert.Equal(t, []string{"early", "in time"}, texts)
+		} else {
+			assert.Equal(t, []string{"in time"}, texts)
+		}
+		assert.Nil(t, gw.outsideHoursTimer)
+	}
+}
+
+func TestFlushOutsideActiveHours(t *testing.T) {
+	r := maketestRouterWithMap(testconfigActiveHours, testBridgeMap)
+	gw := r.Gateways["main"]
+	gw.MyConfig.OutsideHoursBehavior = outsideHoursQueue
+	slack := testBridgerOf(gw, "slack.test")
+
+	// the window starts 10ms from now
+	now := time.Date(2020, 6, 1, 8, 59, 59, 990000000, time.UTC)
+	gw.now = func() time.Time { return now }
+	stop := startRouter(r)
+	defer stop()
+	r.Message <- config.Message{Text: "early", Username: "user", Account: "irc.test", Channel: "#main"}
+
+	waitFor(t, func() bool { return len(slack.messages()) == 1 })
+}
+
+func TestOutsideActiveHoursDigest(t *testing.T) {
+	r := maketestRouterWithMap(testconfigActiveHours, testBridgeMap)
+	gw := r.Gateways["main"]
+	gw.MyConfig.OutsideHoursBehavior = outsideHoursDigest
+	gw.MyConfig.DigestMaxMessages = 2
+	irc := testBridgerOf(gw, "irc.test")
+	slack := testBridgerOf(gw, "slack.test")
+
+	now := time.Date(2020, 6, 1, 8, 0, 0, 0, time.UTC)
+	gw.now = func() time.Time { return now }
+
+	r.relayMessage(config.Message{Text: "early", Username: "alice", Account: "irc.test", Channel: "#main"})
+	r.relayMessage(config.Message{Text: "bob joins", Username: "system", Account: "irc.test", Channel: "#main", Event: config.EventJoinLeave})
+	r.relayMessage(config.Message{Text: "waves", Username: "bob", Account: "slack.test", Channel: "main", Event: config.EventUserAction})
+	r.relayMessage(config.Message{Text: "too late", Username: "carol", Account: "irc.test", Channel: "#main"})
+	r.relayMessage(config.Message{Text: "also too late", Username: "carol", Account: "irc.test", Channel: "#main"})
+	assert.Empty(t, slack.messages())
+	assert.Empty(t, irc.messages())
+	assert.Len(t, gw.outsideHoursQueue, 2)
+	assert.NotNil(t, gw.outsideHoursTimer)
+
+	now = time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	gw.flushOutsideActiveHours()
+
+	digest := "4 messages during quiet hours:\n[irc] alice: early\n[slack] bob: waves\n(+2 more messages)"
+	for _, bridger := range []*testBridger{irc, slack} {
+		sent := bridger.messages()
+		require.Len(t, sent, 1)
+		assert.Equal(t, digest, sent[0].Text)
+		assert.Equal(t, "system", sent[0].Username)
+	}
+	assert.Empty(t, gw.outsideHoursQueue)
+	assert.Zero(t, gw.digestOverflow)
+	assert.Nil(t, gw.outsideHoursTimer)
+
+	// nothing was held, there's no digest
+	gw.flushOutsideActiveHours()
+	assert.Len(t, slack.messages(), 1)
+}
@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// defaultArchiveFormat is the format of the lines of an ArchiveFile without ArchiveFormat.
+const defaultArchiveFormat = "{TIMESTAMP} [{GATEWAY}] {CHANNEL} <{NICK}> {TEXT}"
+
+// archive appends the messages relayed by a gateway to a file. When the file would get
+// larger than maxSize bytes it is rotated to file.1 first.
+type archive struct {
+	sync.Mutex
+
+	file    string
+	format  string
+	maxSize int64
+}
+
+func newArchive(file, format string, maxSize int64) *archive {
+	if format == "" {
+		format = defaultArchiveFormat
+	}
+	return &archive{file: file, format: format, maxSize: maxSize}
+}
+
+// line returns msg of gateway formatted as an archive line.
+func (a *archive) line(gateway string, msg *config.Message) string {
+	line := a.format
+	line = strings.Replace(line, "{TIMESTAMP}", msg.Timestamp.Format(time.RFC3339), -1)
+	line = strings.Replace(line, "{GATEWAY}", gateway, -1)
+	line = strings.Replace(line, "{ACCOUNT}", msg.Account, -1)
+	line = strings.Replace(line, "{CHANNEL}", msg.Channel, -1)
+	line = strings.Replace(line, "{NICK}", msg.Username, -1)
+	line = strings.Replace(line, "{TEXT}", msg.Text, -1)
+	return line + "\n"
+}
+
+// Write appends line to the archive file, rotating it if needed.
+func (a *archive) Write(line string) error {
+	a.Lock()
+	defer a.Unlock()
+	if a.maxSize > 0 {
+		if info, err := os.Stat(a.file); err == nil && info.Size() > 0 && info.Size()+int64(len(line)) > a.maxSize {
+			if err := os.Rename(a.file, a.file+".1"); err != nil {
+				return err
+			}
+		}
+	}
+	f, err := os.OpenFile(a.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// archiveMessage appends msg to the ArchiveFile of gw. Only actual messages are archived,
+// not events like joins or typing notifications.
+func (gw *Gateway) archiveMessage(msg *config.Message) {
+	if gw.archive == nil || msg.Event != "" && msg.Event != config.EventUserAction {
+		return
+	}
+	if err := gw.archive.Write(gw.archive.line(gw.Name, msg)); err != nil {
+		gw.logger.Errorf("Failed to write ArchiveFile %s: %s", gw.archive.file, err)
+	}
+}
@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveMessage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matterbridge-archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "archive.log")
+
+	r := maketestRouterWithMap(testconfigUpdate, testBridgeMap)
+	gw := r.Gateways["main"]
+	gw.archive = newArchive(file, "", 0)
+	timestamp := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#main", Timestamp: timestamp})
+	r.relayMessage(config.Message{Text: "alice joins", Username: "system", Account: "irc.test", Channel: "#main", Event: config.EventJoinLeave})
+	r.relayMessage(config.Message{Text: "waves", Username: "bob", Account: "discord.test", Channel: "general", Event: config.EventUserAction, Timestamp: timestamp})
+
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "2020-01-02T03:04:05Z [main] #main <alice> hello\n2020-01-02T03:04:05Z [main] general <bob> waves\n", string(data))
+}
+
+func TestArchiveFormat(t *testing.T) {
+	a := newArchive("", "{ACCOUNT}/{CHANNEL} {NICK}: {TEXT}", 0)
+	msg := &config.Message{Text: "hi", Username: "alice", Account: "irc.test", Channel: "#main"}
+	assert.Equal(t, "irc.test/#main alice: hi\n", a.line("main", msg))
+}
+
+func TestArchiveRotate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matterbridge-archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "archive.log")
+
+	a := newArchive(file, "", 10)
+	for _, line := range []string{"first\n", "second\n", "third\n", "a line longer than the limit\n"} {
+		require.NoError(t, a.Write(line))
+	}
+
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "a line longer than the limit\n", string(data))
+	data, err = ioutil.ReadFile(file + ".1")
+	require.NoError(t, err)
+	assert.Equal(t, "third\n", string(data))
+}
+
+func TestArchiveConcurrentWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matterbridge-archive")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "archive.log")
+
+	a := newArchive(file, "", 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, a.Write("line\n"))
+		}()
+	}
+	wg.Wait()
+
+	data, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("line\n", 50), string(data))
+}
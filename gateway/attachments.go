@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// sendAttachmentsInOrder sends the text of msg and each of its files as separate
+// messages to dest, the text before the files for "text-first" and after them for
+// "files-first". Files are sent in the order of msg.Extra["file"].
+// Returns the ID of the text message.
+func (gw *Gateway) sendAttachmentsInOrder(msg config.Message, dest *bridge.Bridge, order string) (string, error) {
+	text := msg
+	text.Extra = make(map[string][]interface{})
+	for k, v := range msg.Extra {
+		if k != "file" {
+			text.Extra[k] = v
+		}
+	}
+
+	var files []config.Message
+	for _, f := range msg.Extra["file"] {
+		fi := f.(config.FileInfo)
+		// the text is already sent in its own message
+		if fi.Comment == msg.Text {
+			fi.Comment = ""
+		}
+		file := msg
+		file.Text = ""
+		file.Extra = map[string][]interface{}{"file": {fi}}
+		files = append(files, file)
+	}
+
+	switch order {
+	case "text-first":
+		mID, err := gw.deliverMessage(text, dest)
+		if err != nil {
+			return mID, err
+		}
+		return mID, gw.deliverFiles(files, dest)
+	case "files-first":
+		if err := gw.deliverFiles(files, dest); err != nil {
+			return "", err
+		}
+		return gw.deliverMessage(text, dest)
+	default:
+		gw.logger.Errorf("unknown AttachmentOrder %#v for %s, use text-first or files-first", order, dest.Account)
+		return gw.deliverMessage(msg, dest)
+	}
+}
+
+// deliverFiles sends the messages with one file each in files to dest.
+func (gw *Gateway) deliverFiles(files []config.Message, dest *bridge.Bridge) error {
+	for _, file := range files {
+		if _, err := gw.deliverMessage(file, dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
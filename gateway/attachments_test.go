@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachmentOrder(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	dest := gw.Bridges["irc.freenode"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+
+	data := []byte("data")
+	newMsg := func() config.Message {
+		return config.Message{
+			Text: "look", Username: "user", Account: "discord.test", Channel: "general",
+			Extra: map[string][]interface{}{"file": {
+				config.FileInfo{Name: "a.png", Data: &data, Comment: "look"},
+				config.FileInfo{Name: "b.png", Data: &data, Comment: "second"},
+			}},
+		}
+	}
+	// fileNames returns the text or the file name of each message in sent.
+	fileNames := func(sent []config.Message) []string {
+		var res []string
+		for _, msg := range sent {
+			if hasFiles(&msg) {
+				fi := msg.Extra["file"][0].(config.FileInfo)
+				assert.Len(t, msg.Extra["file"], 1)
+				assert.Empty(t, msg.Text)
+				res = append(res, fi.Name+" "+fi.Comment)
+				continue
+			}
+			res = append(res, msg.Text)
+		}
+		return res
+	}
+
+	dest.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"irc.freenode.AttachmentOrder": "text-first"}}
+	r.relayMessage(newMsg())
+	irc := testBridgerOf(gw, "irc.freenode")
+	// the comment of the first file is the text, it's not repeated
+	assert.Equal(t, []string{"look", "a.png ", "b.png second"}, fileNames(irc.messages()))
+
+	dest.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"irc.freenode.AttachmentOrder": "files-first"}}
+	r.relayMessage(newMsg())
+	assert.Equal(t, []string{"a.png ", "b.png second", "look"}, fileNames(irc.messages()[3:]))
+
+	// without AttachmentOrder the bridge gets the message as is
+	sent := testBridgerOf(gw, "slack.test").messages()
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "look", sent[0].Text)
+	assert.Len(t, sent[0].Extra["file"], 2)
+}
@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// defaultBatchSize is the number of messages after which a batch is sent when
+// BatchSize isn't set.
+const defaultBatchSize = 50
+
+// messageBatch collects the messages for a destination channel with BatchWindow
+// enabled until its window elapses or it is full.
+type messageBatch struct {
+	dest  *bridge.Bridge
+	msgs  []config.Message
+	timer *time.Timer
+}
+
+// messageBatches holds the batch of every destination channel with BatchWindow enabled.
+type messageBatches struct {
+	sync.Mutex
+	batches map[string]*messageBatch
+}
+
+func newMessageBatches() *messageBatches {
+	return &messageBatches{batches: make(map[string]*messageBatch)}
+}
+
+// batchMessage adds msg to the batch of channel if dest can send batches and has
+// BatchWindow set. Returns true if msg is batched.
+func (gw *Gateway) batchMessage(msg config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) bool {
+	window := dest.GetInt("BatchWindow")
+	if _, ok := dest.Bridger.(bridge.BatchSender); !ok || window <= 0 {
+		return false
+	}
+	size := dest.GetInt("BatchSize")
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	q := gw.batches
+	q.Lock()
+	b, ok := q.batches[channel.ID]
+	if !ok {
+		b = &messageBatch{dest: dest}
+		b.timer = time.AfterFunc(time.Duration(window)*time.Millisecond, func() {
+			gw.sendBatchLimited(channel.ID, b)
+		})
+		q.batches[channel.ID] = b
+	}
+	b.msgs = append(b.msgs, msg)
+	full := len(b.msgs) >= size
+	q.Unlock()
+	// called from SendMessage, which already holds a send slot
+	if full {
+		gw.sendBatch(channel.ID, b)
+	}
+	return true
+}
+
+// sendBatch sends the messages of b, the batch of the channel with ID, in one call.
+// Nothing is sent if b was sent already. The caller must hold a slot of gw.sendLimit.
+func (gw *Gateway) sendBatch(ID string, b *messageBatch) {
+	q := gw.batches
+	q.Lock()
+	if q.batches[ID] != b {
+		q.Unlock()
+		return
+	}
+	delete(q.batches, ID)
+	q.Unlock()
+	b.timer.Stop()
+	if err := b.dest.Bridger.(bridge.BatchSender).SendBatch(b.msgs); err != nil {
+		gw.logger.Errorf("SendBatch to %s failed: %s", b.dest.Account, err)
+	}
+}
+
+// sendBatchLimited sends b like sendBatch once gw.sendLimit allows it.
+func (gw *Gateway) sendBatchLimited(ID string, b *messageBatch) {
+	gw.sendLimit.acquire()
+	defer gw.sendLimit.release()
+	gw.sendBatch(ID, b)
+}
+
+// flushBatches sends all batched messages of gw.
+func (gw *Gateway) flushBatches() {
+	q := gw.batches
+	q.Lock()
+	batches := make(map[string]*messageBatch, len(q.batches))
+	for ID, b := range q.batches {
+		batches[ID] = b
+	}
+	q.Unlock()
+	for ID, b := range batches {
+		gw.sendBatchLimited(ID, b)
+	}
+}
@@ -0,0 +1,151 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigBatch = []byte(`
+[irc.test]
+server=""
+[api.batch]
+BatchWindow=60000
+BatchSize=3
+[api.fast]
+BatchWindow=100
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="api.batch"
+    channel="api"
+
+    [[gateway.inout]]
+    account="api.fast"
+    channel="api"
+`)
+
+// testBatchBridger is a testBridger that can send batches.
+type testBatchBridger struct {
+	*testBridger
+
+	batches [][]config.Message
+}
+
+func newTestBatchBridger(cfg *bridge.Config) bridge.Bridger {
+	return &testBatchBridger{testBridger: &testBridger{}}
+}
+
+func (b *testBatchBridger) SendBatch(msgs []config.Message) error {
+	b.Lock()
+	defer b.Unlock()
+	b.batches = append(b.batches, msgs)
+	return nil
+}
+
+func (b *testBatchBridger) sentBatches() [][]config.Message {
+	b.Lock()
+	defer b.Unlock()
+	return append([][]config.Message(nil), b.batches...)
+}
+
+func TestBatchWindow(t *testing.T) {
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["api"] = newTestBatchBridger
+
+	r := maketestRouterWithMap(testconfigBatch, bridgeMap)
+	gw := r.Gateways["main"]
+	batch := gw.Bridges["api.batch"].Bridger.(*testBatchBridger)
+	fast := gw.Bridges["api.fast"].Bridger.(*testBatchBridger)
+
+	for _, text := range []string{"one", "two", "three", "four"} {
+		r.relayMessage(config.Message{Text: text, Username: "user", Account: "irc.test", Channel: "#main"})
+	}
+
+	// a full batch is sent right away
+	batches := batch.sentBatches()
+	assert.Len(t, batches, 1)
+	assert.Len(t, batches[0], 3)
+	assert.Equal(t, "one", batches[0][0].Text)
+	assert.Equal(t, "three", batches[0][2].Text)
+	assert.Empty(t, batch.messages())
+
+	// the rest on flush
+	gw.flushBatches()
+	batches = batch.sentBatches()
+	assert.Len(t, batches, 2)
+	assert.Len(t, batches[1], 1)
+	assert.Equal(t, "four", batches[1][0].Text)
+	gw.flushBatches()
+	assert.Len(t, batch.sentBatches(), 2)
+
+	// or when the window elapses
+	waitFor(t, func() bool { return len(fast.sentBatches()) == 1 })
+	assert.Len(t, fast.sentBatches()[0], 4)
+}
+
+var testconfigBatchSendLimit = []byte(`
+[irc.test]
+server=""
+[api.batch]
+BatchWindow=60000
+BatchSize=2
+
+[[gateway]]
+name="main"
+enable=true
+MaxConcurrentSends=1
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="api.batch"
+    channel="api"
+`)
+
+func TestBatchWindowMaxConcurrentSends(t *testing.T) {
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["api"] = newTestBatchBridger
+
+	r := maketestRouterWithMap(testconfigBatchSendLimit, bridgeMap)
+	gw := r.Gateways["main"]
+	batch := gw.Bridges["api.batch"].Bridger.(*testBatchBridger)
+
+	// the full batch is sent while the only send slot is taken by SendMessage
+	done := make(chan struct{})
+	go func() {
+		for _, text := range []string{"one", "two", "three"} {
+			r.relayMessage(config.Message{Text: text, Username: "user", Account: "irc.test", Channel: "#main"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("relayMessage didn't return")
+	}
+	batches := batch.sentBatches()
+	assert.Len(t, batches, 1)
+	assert.Len(t, batches[0], 2)
+
+	gw.flushBatches()
+	assert.Len(t, batch.sentBatches(), 2)
+}
@@ -0,0 +1,42 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// defaultBroadcastNick is the nick of broadcasts of gateways without BroadcastNick.
+const defaultBroadcastNick = "system"
+
+// broadcastEchoWindow is how long messages with the text of a broadcast are ignored,
+// so a broadcast echoed back by one of the bridges isn't relayed again.
+const broadcastEchoWindow = time.Minute
+
+// Broadcast sends text to every out and inout channel of gw from the BroadcastNick of gw.
+// Unlike relayed messages it is also sent during maintenance.
+func (gw *Gateway) Broadcast(text string) {
+	if text == "" {
+		return
+	}
+	gw.announce(text)
+}
+
+// broadcastNick returns the nick broadcasts of gw are sent from.
+func (gw *Gateway) broadcastNick() string {
+	if gw.MyConfig != nil && gw.MyConfig.BroadcastNick != "" {
+		return gw.MyConfig.BroadcastNick
+	}
+	return defaultBroadcastNick
+}
+
+// isBroadcastEcho returns true if msg has the text of a broadcast sent less than
+// broadcastEchoWindow ago.
+func (gw *Gateway) isBroadcastEcho(msg *config.Message) bool {
+	sent, ok := gw.broadcasts.Get(msg.Text)
+	if !ok || gw.now().Sub(sent.(time.Time)) > broadcastEchoWindow {
+		return false
+	}
+	gw.logger.Debugf("ignoring echo of broadcast from %s", msg.Account)
+	return true
+}
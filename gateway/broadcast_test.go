@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigBroadcast = []byte(`
+[irc.test]
+server=""
+[slack.test]
+server=""
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+BroadcastNick="admin"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.out]]
+    account="slack.test"
+    channel="news"
+
+    [[gateway.in]]
+    account="discord.test"
+    channel="input"
+`)
+
+func TestBroadcast(t *testing.T) {
+	r := maketestRouterWithMap(testconfigBroadcast, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	slack := testBridgerOf(gw, "slack.test")
+	discord := testBridgerOf(gw, "discord.test")
+	now := time.Now()
+	gw.now = func() time.Time { return now }
+
+	gw.Broadcast("Server restart at 10:00")
+	for _, b := range []*testBridger{irc, slack} {
+		sent := b.messages()
+		require.Len(t, sent, 1)
+		assert.Equal(t, "Server restart at 10:00", sent[0].Text)
+		assert.Equal(t, "admin", sent[0].Username)
+	}
+	assert.Equal(t, "#main", irc.messages()[0].Channel)
+	assert.Equal(t, "news", slack.messages()[0].Channel)
+	// in channels don't receive anything
+	assert.Empty(t, discord.messages())
+
+	// the broadcast echoed back isn't relayed again
+	r.relayMessage(config.Message{Text: "Server restart at 10:00", Username: "admin", Account: "irc.test", Channel: "#main"})
+	assert.Len(t, slack.messages(), 1)
+
+	// after broadcastEchoWindow the text is relayed like any other message
+	now = now.Add(broadcastEchoWindow + time.Second)
+	r.relayMessage(config.Message{Text: "Server restart at 10:00", Username: "alice", Account: "irc.test", Channel: "#main"})
+	assert.Len(t, slack.messages(), 2)
+}
+
+func TestBroadcastDefaultNick(t *testing.T) {
+	r := maketestRouterWithMap(testconfigUpdate, testBridgeMap)
+	gw := r.Gateways["main"]
+	gw.Broadcast("hello")
+	sent := testBridgerOf(gw, "irc.test").messages()
+	require.Len(t, sent, 1)
+	assert.Equal(t, defaultBroadcastNick, sent[0].Username)
+
+	// empty broadcasts aren't sent
+	gw.Broadcast("")
+	assert.Len(t, testBridgerOf(gw, "irc.test").messages(), 1)
+}
@@ -0,0 +1,33 @@
+package gateway
+
+import (
+	"sort"
+)
+
+// ChannelInfoView is a read-only copy of a channel of a gateway.
+type ChannelInfoView struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Account     string `json:"account"`
+	Direction   string `json:"direction"`
+	SameChannel bool   `json:"samechannel"`
+}
+
+// ChannelSnapshot returns a copy of the channels of gw sorted by ID.
+// It is safe to call while the gateway is running.
+func (gw *Gateway) ChannelSnapshot() []ChannelInfoView {
+	gw.channelsLock.RLock()
+	defer gw.channelsLock.RUnlock()
+	channels := make([]ChannelInfoView, 0, len(gw.Channels))
+	for _, channel := range gw.Channels {
+		channels = append(channels, ChannelInfoView{
+			ID:          channel.ID,
+			Name:        channel.Name,
+			Account:     channel.Account,
+			Direction:   channel.Direction,
+			SameChannel: channel.SameChannel[gw.Name],
+		})
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].ID < channels[j].ID })
+	return channels
+}
@@ -0,0 +1,26 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelSnapshot(t *testing.T) {
+	r := maketestRouterWithMap(testconfig2, testBridgeMap)
+
+	assert.Equal(t, []ChannelInfoView{
+		{ID: "#wimtestingirc.freenode", Name: "#wimtesting", Account: "irc.freenode", Direction: "in"},
+		{ID: "42wim/testroomgitter.42wim", Name: "42wim/testroom", Account: "gitter.42wim", Direction: "in"},
+		{ID: "generaldiscord.test", Name: "general", Account: "discord.test", Direction: "inout"},
+		{ID: "testingslack.test", Name: "testing", Account: "slack.test", Direction: "out"},
+	}, r.Gateways["bridge1"].ChannelSnapshot())
+
+	// changes to the snapshot don't change the gateway
+	gw := r.Gateways["bridge2"]
+	channels := gw.ChannelSnapshot()
+	assert.Len(t, channels, 3)
+	assert.Equal(t, "42wim/testroomgitter.42wim", channels[1].ID)
+	channels[1].Direction = "inout"
+	assert.Equal(t, "out", gw.Channels["42wim/testroomgitter.42wim"].Direction)
+}
@@ -0,0 +1,101 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// coalescer combines consecutive messages of the same user in the same channel
+// which are received within a window into one message.
+type coalescer struct {
+	window  time.Duration
+	pending *config.Message
+	timer   *time.Timer
+	gen     int
+	expired chan<- coalesceTimeout
+}
+
+// coalesceTimeout is sent by the timer of a coalescer when its window elapsed.
+type coalesceTimeout struct {
+	c   *coalescer
+	gen int
+}
+
+func newCoalescer(window time.Duration, expired chan<- coalesceTimeout) *coalescer {
+	return &coalescer{window: window, expired: expired}
+}
+
+// canCoalesce returns true if msg is a plain text message that can be combined with others.
+// Messages with an ID aren't combined, their later edits and deletes couldn't be matched
+// to the combined message.
+func canCoalesce(msg *config.Message) bool {
+	return msg.Event == "" && msg.Text != "" && msg.ID == "" && msg.ParentID == "" && len(msg.Extra) == 0
+}
+
+// add buffers msg and returns the messages that need to be relayed now, in order.
+// A buffered message is returned when the user or channel changes or when a message
+// that can't be combined is received.
+func (c *coalescer) add(msg config.Message) []config.Message {
+	var res []config.Message
+	if c.pending != nil && (!canCoalesce(&msg) ||
+		c.pending.Username != msg.Username || c.pending.Channel != msg.Channel) {
+		res = append(res, c.flush()...)
+	}
+	if !canCoalesce(&msg) {
+		return append(res, msg)
+	}
+	if c.pending != nil {
+		c.pending.Text += "\n" + msg.Text
+		return res
+	}
+	c.pending = &msg
+	c.gen++
+	gen := c.gen
+	c.timer = time.AfterFunc(c.window, func() {
+		c.expired <- coalesceTimeout{c: c, gen: gen}
+	})
+	return res
+}
+
+// expire returns the buffered message if the window of generation gen elapsed.
+func (c *coalescer) expire(gen int) []config.Message {
+	if gen != c.gen {
+		return nil
+	}
+	return c.flush()
+}
+
+// flush returns and clears the buffered message.
+func (c *coalescer) flush() []config.Message {
+	if c.pending == nil {
+		return nil
+	}
+	c.timer.Stop()
+	// invalidate a timer that already fired but hasn't been handled yet
+	c.gen++
+	msg := *c.pending
+	c.pending = nil
+	return []config.Message{msg}
+}
+
+// coalesce passes msg through the coalescer of its account if CoalesceWindow is set
+// and returns the messages that are ready to be relayed.
+func (r *Router) coalesce(msg config.Message) []config.Message {
+	br := r.getBridge(msg.Account)
+	if br == nil {
+		return []config.Message{msg}
+	}
+	c, ok := r.coalescers[msg.Account]
+	if !ok {
+		window := br.GetInt("CoalesceWindow")
+		if window > 0 {
+			c = newCoalescer(time.Duration(window)*time.Millisecond, r.coalesceTimeout)
+		}
+		r.coalescers[msg.Account] = c
+	}
+	if c == nil {
+		return []config.Message{msg}
+	}
+	return c.add(msg)
+}
@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalescerSameUser(t *testing.T) {
+	expired := make(chan coalesceTimeout, 1)
+	c := newCoalescer(time.Hour, expired)
+	assert.Empty(t, c.add(config.Message{Text: "line 1", Username: "user", Channel: "#test"}))
+	assert.Empty(t, c.add(config.Message{Text: "line 2", Username: "user", Channel: "#test"}))
+	msgs := c.flush()
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "line 1\nline 2", msgs[0].Text)
+}
+
+func TestCoalescerFlushTriggers(t *testing.T) {
+	expired := make(chan coalesceTimeout, 1)
+	c := newCoalescer(time.Hour, expired)
+
+	// other user
+	c.add(config.Message{Text: "line 1", Username: "user", Channel: "#test"})
+	msgs := c.add(config.Message{Text: "line 2", Username: "other", Channel: "#test"})
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "line 1", msgs[0].Text)
+
+	// other channel
+	msgs = c.add(config.Message{Text: "line 3", Username: "other", Channel: "#other"})
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "line 2", msgs[0].Text)
+
+	// an event is relayed immediately, after the buffered message
+	msgs = c.add(config.Message{Username: "other", Channel: "#other", Event: config.EventJoinLeave})
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "line 3", msgs[0].Text)
+	assert.Equal(t, config.EventJoinLeave, msgs[1].Event)
+	assert.Nil(t, c.flush())
+
+	// a message with an ID is relayed as is, its edits must match it
+	c.add(config.Message{Text: "line 4", Username: "other", Channel: "#other"})
+	msgs = c.add(config.Message{Text: "line 5", Username: "other", Channel: "#other", ID: "5"})
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "line 4", msgs[0].Text)
+	assert.Equal(t, "5", msgs[1].ID)
+	assert.Equal(t, "line 5", msgs[1].Text)
+	assert.Nil(t, c.flush())
+}
+
+func TestCoalescerWindowElapsed(t *testing.T) {
+	expired := make(chan coalesceTimeout, 1)
+	c := newCoalescer(10*time.Millisecond, expired)
+	c.add(config.Message{Text: "line 1", Username: "user", Channel: "#test"})
+	c.add(config.Message{Text: "line 2", Username: "user", Channel: "#test"})
+
+	select {
+	case timeout := <-expired:
+		msgs := timeout.c.expire(timeout.gen)
+		require.Len(t, msgs, 1)
+		assert.Equal(t, "line 1\nline 2", msgs[0].Text)
+	case <-time.After(time.Second):
+		t.Fatal("window didn't elapse")
+	}
+}
+
+func TestCoalescerStaleTimeout(t *testing.T) {
+	expired := make(chan coalesceTimeout, 1)
+	c := newCoalescer(time.Hour, expired)
+	c.add(config.Message{Text: "line 1", Username: "user", Channel: "#test"})
+	gen := c.gen
+	c.add(config.Message{Text: "line 2", Username: "other", Channel: "#test"})
+	// the timeout of the first message must not flush the message of the other user
+	assert.Nil(t, c.expire(gen))
+	assert.Len(t, c.flush(), 1)
+}
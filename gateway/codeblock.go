@@ -0,0 +1,25 @@
+package gateway
+
+import (
+	"regexp"
+	"strings"
+)
+
+// codeBlockRE matches fenced code blocks with an optional language, eg ```go ... ```.
+var codeBlockRE = regexp.MustCompile("(?s)```([a-zA-Z0-9_+-]*\\n)?(.*?)```")
+
+// convertCodeBlocks returns text with its fenced code blocks converted for mode.
+// "strip-fence" removes the fences, "indent" removes the fences and indents the code
+// with 4 spaces. Other modes (like the default "preserve") keep text as is.
+func convertCodeBlocks(text, mode string) string {
+	if mode != "strip-fence" && mode != "indent" {
+		return text
+	}
+	return codeBlockRE.ReplaceAllStringFunc(text, func(block string) string {
+		code := strings.TrimSuffix(codeBlockRE.FindStringSubmatch(block)[2], "\n")
+		if mode == "indent" {
+			code = "    " + strings.Replace(code, "\n", "\n    ", -1)
+		}
+		return code
+	})
+}
@@ -0,0 +1,48 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertCodeBlocks(t *testing.T) {
+	code := "look:\n```go\nfunc main() {\n\tfmt.Println(\"hi\")\n}\n```\ndone"
+	msgTests := map[string]struct {
+		input  string
+		mode   string
+		output string
+	}{
+		"preserve":         {input: code, mode: "preserve", output: code},
+		"default":          {input: code, mode: "", output: code},
+		"strip-fence":      {input: code, mode: "strip-fence", output: "look:\nfunc main() {\n\tfmt.Println(\"hi\")\n}\ndone"},
+		"indent":           {input: code, mode: "indent", output: "look:\n    func main() {\n    \tfmt.Println(\"hi\")\n    }\ndone"},
+		"no language":      {input: "```\na\nb\n```", mode: "indent", output: "    a\n    b"},
+		"single line":      {input: "run ```make test``` now", mode: "strip-fence", output: "run make test now"},
+		"multiple blocks":  {input: "```\na\n```\nand\n```\nb\n```", mode: "strip-fence", output: "a\nand\nb"},
+		"unclosed fence":   {input: "```\na\nb", mode: "strip-fence", output: "```\na\nb"},
+		"no code":          {input: "plain text", mode: "indent", output: "plain text"},
+		"inline backticks": {input: "use `go test`", mode: "strip-fence", output: "use `go test`"},
+	}
+	for testname, testcase := range msgTests {
+		assert.Equalf(t, testcase.output, convertCodeBlocks(testcase.input, testcase.mode), "case '%s' failed", testname)
+	}
+}
+
+func TestSendMessageCodeBlockHandling(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	dest := gw.Bridges["irc.freenode"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+	dest.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"irc.freenode.CodeBlockHandling": "indent"}}
+
+	r.relayMessage(config.Message{Text: "```sh\nls\npwd\n```", Username: "user", Account: "discord.test", Channel: "general"})
+
+	sent := testBridgerOf(gw, "irc.freenode").messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "    ls\n    pwd", sent[0].Text)
+	// other bridges keep the fences
+	assert.Equal(t, "```sh\nls\npwd\n```", testBridgerOf(gw, "slack.test").messages()[0].Text)
+}
@@ -0,0 +1,57 @@
+package gateway
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+const defaultCommandPrefix = "/"
+
+// splitCommand splits text starting with a command like "/cmd args" into the
+// command and the rest of the text. ok is false if text doesn't start with a command.
+// A command is prefix followed by a word, so paths like "/home/user" aren't commands.
+func (gw *Gateway) splitCommand(text, prefix string) (cmd, rest string, ok bool) {
+	if prefix == "" {
+		prefix = defaultCommandPrefix
+	}
+	re, err := gw.regexps.compile(`^` + regexp.QuoteMeta(prefix) + `\w+(\s+|$)`)
+	if err != nil {
+		gw.logger.Errorf("invalid CommandPrefix %#v: %s", prefix, err)
+		return "", text, false
+	}
+	loc := re.FindStringIndex(text)
+	if loc == nil {
+		return "", text, false
+	}
+	return strings.TrimSpace(text[:loc[1]]), text[loc[1]:], true
+}
+
+// ignoreCommand returns true if msg is a command that isn't relayed because of the
+// DropCommands or StripLeadingCommands of its bridge. With StripLeadingCommands only
+// commands without arguments are ignored, as nothing is left after stripping them.
+func (gw *Gateway) ignoreCommand(msg *config.Message) bool {
+	br := gw.Bridges[msg.Account]
+	if msg.Event != "" || !br.GetBool("DropCommands") && !br.GetBool("StripLeadingCommands") {
+		return false
+	}
+	cmd, rest, ok := gw.splitCommand(msg.Text, br.GetString("CommandPrefix"))
+	if !ok || !br.GetBool("DropCommands") && rest != "" {
+		return false
+	}
+	gw.logger.Debugf("ignoring command %s from %s", cmd, msg.Account)
+	return true
+}
+
+// stripCommand removes the leading command from the text of msg if StripLeadingCommands
+// is enabled on its bridge.
+func (gw *Gateway) stripCommand(msg *config.Message) {
+	br := gw.Bridges[msg.Account]
+	if msg.Event != "" || !br.GetBool("StripLeadingCommands") {
+		return
+	}
+	if _, rest, ok := gw.splitCommand(msg.Text, br.GetString("CommandPrefix")); ok && rest != "" {
+		msg.Text = rest
+	}
+}
@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCommand(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+
+	msgTests := map[string]struct {
+		text   string
+		prefix string
+		cmd    string
+		rest   string
+		ok     bool
+	}{
+		"command with args":    {text: "/roll 2d6", cmd: "/roll", rest: "2d6", ok: true},
+		"command only":         {text: "/help", cmd: "/help", rest: "", ok: true},
+		"command with newline": {text: "/say\nhello", cmd: "/say", rest: "hello", ok: true},
+		"path":                 {text: "/home/user is full", rest: "/home/user is full"},
+		"no command":           {text: "hello /help", rest: "hello /help"},
+		"only prefix":          {text: "/ what", rest: "/ what"},
+		"custom prefix":        {text: "!roll 2d6", prefix: "!", cmd: "!roll", rest: "2d6", ok: true},
+		"other prefix":         {text: "/roll 2d6", prefix: "!", rest: "/roll 2d6"},
+		"regexp prefix":        {text: ".help me", prefix: ".", cmd: ".help", rest: "me", ok: true},
+	}
+	for testname, testcase := range msgTests {
+		cmd, rest, ok := gw.splitCommand(testcase.text, testcase.prefix)
+		assert.Equalf(t, testcase.cmd, cmd, "case '%s' failed", testname)
+		assert.Equalf(t, testcase.rest, rest, "case '%s' failed", testname)
+		assert.Equalf(t, testcase.ok, ok, "case '%s' failed", testname)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	br := gw.Bridges["irc.freenode"]
+	cfg := br.Config
+	defer func() { br.Config = cfg }()
+
+	strip := map[string]interface{}{"irc.freenode.StripLeadingCommands": true}
+	drop := map[string]interface{}{"irc.freenode.DropCommands": true}
+	msgTests := map[string]struct {
+		msg       config.Message
+		overrides map[string]interface{}
+		ignored   bool
+		output    string
+	}{
+		"disabled":          {msg: config.Message{Text: "/roll 2d6"}, output: "/roll 2d6"},
+		"strip":             {msg: config.Message{Text: "/roll 2d6"}, overrides: strip, output: "2d6"},
+		"strip only cmd":    {msg: config.Message{Text: "/help"}, overrides: strip, ignored: true},
+		"strip no command":  {msg: config.Message{Text: "hello"}, overrides: strip, output: "hello"},
+		"drop":              {msg: config.Message{Text: "/roll 2d6"}, overrides: drop, ignored: true},
+		"drop no command":   {msg: config.Message{Text: "hello /roll"}, overrides: drop, output: "hello /roll"},
+		"drop action":       {msg: config.Message{Text: "/roll", Event: config.EventUserAction}, overrides: drop, output: "/roll"},
+		"drop other prefix": {msg: config.Message{Text: "/roll 2d6"}, overrides: map[string]interface{}{"irc.freenode.DropCommands": true, "irc.freenode.CommandPrefix": "!"}, output: "/roll 2d6"},
+	}
+	for testname, testcase := range msgTests {
+		br.Config = &config.TestConfig{Config: cfg, Overrides: testcase.overrides}
+		msg := testcase.msg
+		msg.Account = "irc.freenode"
+		msg.Channel = "#wimtesting"
+		assert.Equalf(t, testcase.ignored, gw.ignoreMessage(&msg), "case '%s' failed", testname)
+		if testcase.ignored {
+			continue
+		}
+		gw.modifyMessage(&msg)
+		assert.Equalf(t, testcase.output, msg.Text, "case '%s' failed", testname)
+	}
+}
@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Connection states of a bridge.
+const (
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateDisconnected = "disconnected"
+)
+
+// BridgeState is the connection state of a bridge.
+type BridgeState struct {
+	Account string    `json:"account"`
+	State   string    `json:"state"`
+	Since   time.Time `json:"since"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// connectionTracker keeps track of the connection state of all bridges.
+type connectionTracker struct {
+	sync.RWMutex
+
+	states map[string]BridgeState
+}
+
+func newConnectionTracker() *connectionTracker {
+	return &connectionTracker{states: make(map[string]BridgeState)}
+}
+
+// set changes the state of account. err is the reason of a disconnect and can be nil.
+func (t *connectionTracker) set(account, state string, err error) {
+	t.Lock()
+	defer t.Unlock()
+	s := BridgeState{Account: account, State: state, Since: time.Now()}
+	if old, ok := t.states[account]; ok && old.State == state {
+		s.Since = old.Since
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	t.states[account] = s
+}
+
+// get returns the state of account.
+func (t *connectionTracker) get(account string) (BridgeState, bool) {
+	t.RLock()
+	defer t.RUnlock()
+	s, ok := t.states[account]
+	return s, ok
+}
+
+// all returns the states of all bridges sorted by account.
+func (t *connectionTracker) all() []BridgeState {
+	t.RLock()
+	defer t.RUnlock()
+	states := make([]BridgeState, 0, len(t.states))
+	for _, s := range t.states {
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Account < states[j].Account })
+	return states
+}
+
+// allConnected returns true if there is at least one bridge and all bridges are connected.
+func (t *connectionTracker) allConnected() bool {
+	t.RLock()
+	defer t.RUnlock()
+	if len(t.states) == 0 {
+		return false
+	}
+	for _, s := range t.states {
+		if s.State != StateConnected {
+			return false
+		}
+	}
+	return true
+}
+
+// BridgeStates returns the connection state of all bridges of the router.
+func (r *Router) BridgeStates() []BridgeState {
+	return r.connections.all()
+}
@@ -0,0 +1,54 @@
+package gateway
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// counter is a monotonically increasing message counter. When a file is specified
+// the value is persisted to it so the numbering survives restarts.
+type counter struct {
+	sync.Mutex
+
+	value uint64
+	file  string
+}
+
+// newCounter creates a counter, restoring the last value from file if it exists.
+func newCounter(file string) (*counter, error) {
+	c := &counter{file: file}
+	if file == "" {
+		return c, nil
+	}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	c.value, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return c, err
+}
+
+// Increment increases the counter by one and returns the new value.
+// The value is still increased when writing it to the counter file fails.
+func (c *counter) Increment() (uint64, error) {
+	c.Lock()
+	defer c.Unlock()
+	c.value++
+	if c.file == "" {
+		return c.value, nil
+	}
+	return c.value, ioutil.WriteFile(c.file, []byte(strconv.FormatUint(c.value, 10)), 0600)
+}
+
+// Value returns the current value of the counter.
+func (c *counter) Value() uint64 {
+	c.Lock()
+	defer c.Unlock()
+	return c.value
+}
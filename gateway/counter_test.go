@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounterIncrement(t *testing.T) {
+	c, err := newCounter("")
+	require.NoError(t, err)
+	for i := uint64(1); i <= 3; i++ {
+		value, err := c.Increment()
+		assert.NoError(t, err)
+		assert.Equal(t, i, value)
+	}
+	assert.Equal(t, uint64(3), c.Value())
+}
+
+func TestCounterConcurrentIncrement(t *testing.T) {
+	c, err := newCounter("")
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = c.Increment()
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, uint64(100), c.Value())
+}
+
+func TestCounterPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matterbridge-counter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "count")
+
+	c, err := newCounter(file)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), c.Value())
+	_, _ = c.Increment()
+	_, err = c.Increment()
+	require.NoError(t, err)
+
+	// simulate a restart
+	c, err = newCounter(file)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), c.Value())
+	value, err := c.Increment()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), value)
+}
@@ -0,0 +1,136 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+var emojiCodeRE = regexp.MustCompile(`:[a-zA-Z0-9_+-]+:`)
+
+// customEmojiTokenRE matches the custom emoji tokens of discord, <:name:id> and the
+// animated <a:name:id>.
+var customEmojiTokenRE = regexp.MustCompile(`<(a?):([a-zA-Z0-9_~-]+):([0-9]+)>`)
+
+// The values of CustomEmojiHandling.
+const (
+	customEmojiStrip = "strip"
+	customEmojiName  = "name"
+	customEmojiURL   = "url"
+)
+
+// emojiMapCache keeps the CustomEmojiFile mappings around so they only get read
+// again when the modification time of the file changes.
+type emojiMapCache struct {
+	sync.Mutex
+
+	maps map[string]*cachedEmojiMap
+}
+
+type cachedEmojiMap struct {
+	modTime time.Time
+	codes   map[string]string
+}
+
+func newEmojiMapCache() *emojiMapCache {
+	return &emojiMapCache{maps: make(map[string]*cachedEmojiMap)}
+}
+
+// get returns the mapping of shortcode (without colons) to replacement in filename.
+func (ec *emojiMapCache) get(filename string) (map[string]string, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	ec.Lock()
+	defer ec.Unlock()
+	if cached, ok := ec.maps[filename]; ok && cached.modTime.Equal(fi.ModTime()) {
+		return cached.codes, nil
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	// both "partyparrot" and ":partyparrot:" are accepted as shortcode
+	codes := make(map[string]string, len(m))
+	for code, replacement := range m {
+		codes[strings.Trim(code, ":")] = replacement
+	}
+	ec.maps[filename] = &cachedEmojiMap{modTime: fi.ModTime(), codes: codes}
+	return codes, nil
+}
+
+// replaceCustomEmoji replaces the :shortcodes: in the text of msg found in the
+// CustomEmojiFile of the source bridge. Unknown shortcodes are kept for the
+// emoji library.
+func (gw *Gateway) replaceCustomEmoji(msg *config.Message) {
+	br := gw.Bridges[msg.Account]
+	if br == nil {
+		return
+	}
+	filename := br.GetString("CustomEmojiFile")
+	if filename == "" {
+		return
+	}
+	codes, err := gw.emojiMaps.get(filename)
+	if err != nil {
+		gw.logger.Errorf("CustomEmojiFile %s failed: %s", filename, err)
+		return
+	}
+	msg.Text = emojiCodeRE.ReplaceAllStringFunc(msg.Text, func(code string) string {
+		if replacement, ok := codes[strings.Trim(code, ":")]; ok {
+			return replacement
+		}
+		return code
+	})
+}
+
+// customEmojiImageURL returns the URL of the image of the custom emoji id.
+func customEmojiImageURL(id string, animated bool) string {
+	if animated {
+		return "https://cdn.discordapp.com/emojis/" + id + ".gif"
+	}
+	return "https://cdn.discordapp.com/emojis/" + id + ".png"
+}
+
+// handleCustomEmoji converts the custom emoji tokens in the text of msg according to the
+// CustomEmojiHandling of the source bridge: removed with "strip", replaced with :name:
+// with "name" or with the URL of the image with "url".
+func (gw *Gateway) handleCustomEmoji(msg *config.Message) {
+	br := gw.Bridges[msg.Account]
+	if br == nil {
+		return
+	}
+	handling := br.GetString("CustomEmojiHandling")
+	switch handling {
+	case "":
+		return
+	case customEmojiStrip, customEmojiName, customEmojiURL:
+	default:
+		gw.logger.Errorf("unknown CustomEmojiHandling %q for %s", handling, msg.Account)
+		return
+	}
+	msg.Text = customEmojiTokenRE.ReplaceAllStringFunc(msg.Text, func(token string) string {
+		match := customEmojiTokenRE.FindStringSubmatch(token)
+		switch handling {
+		case customEmojiName:
+			return ":" + match[2] + ":"
+		case customEmojiURL:
+			return customEmojiImageURL(match[3], match[1] == "a")
+		}
+		return ""
+	})
+	if handling == customEmojiStrip {
+		msg.Text = strings.TrimSpace(msg.Text)
+	}
+}
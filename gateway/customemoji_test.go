@@ -0,0 +1,91 @@
+package gateway
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/matterbridge/emoji"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomEmoji(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matterbridge-emoji")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "emoji.json")
+	writeScript(t, filename, `{"partyparrot": "https://example.com/partyparrot.gif", ":smile:": ":-)"}`, time.Now())
+
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	br := gw.Bridges["irc.freenode"]
+	cfg := br.Config
+	defer func() { br.Config = cfg }()
+	br.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"irc.freenode.CustomEmojiFile": filename}}
+
+	msgTests := map[string]struct {
+		input  string
+		output string
+	}{
+		"custom":       {input: "look :partyparrot:", output: "look https://example.com/partyparrot.gif"},
+		"overrides":    {input: "hi :smile:", output: "hi :-)"},
+		"library":      {input: "yay :tada:", output: emoji.Sprint("yay :tada:")},
+		"mixed":        {input: ":partyparrot::tada:", output: emoji.Sprint("https://example.com/partyparrot.gif:tada:")},
+		"unknown":      {input: "a :nosuchemoji: b", output: "a :nosuchemoji: b"},
+		"not a code":   {input: "at 12:30:00", output: "at 12:30:00"},
+		"no shortcode": {input: "partyparrot", output: "partyparrot"},
+	}
+	for testname, testcase := range msgTests {
+		msg := &config.Message{Text: testcase.input, Account: "irc.freenode"}
+		gw.modifyMessage(msg)
+		assert.Equalf(t, testcase.output, msg.Text, "case '%s' failed", testname)
+	}
+
+	// the file is read again after it changed
+	writeScript(t, filename, `{"partyparrot": "(parrot)"}`, time.Now().Add(time.Minute))
+	msg := &config.Message{Text: ":partyparrot: :smile:", Account: "irc.freenode"}
+	gw.modifyMessage(msg)
+	assert.Equal(t, emoji.Sprint("(parrot) :smile:"), msg.Text)
+
+	// an invalid file falls back to the library
+	writeScript(t, filename, `not json`, time.Now().Add(2*time.Minute))
+	msg = &config.Message{Text: ":smile:", Account: "irc.freenode"}
+	gw.modifyMessage(msg)
+	assert.Equal(t, emoji.Sprint(":smile:"), msg.Text)
+}
+
+func TestCustomEmojiHandling(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	br := gw.Bridges["irc.freenode"]
+	cfg := br.Config
+	defer func() { br.Config = cfg }()
+
+	const input = "nice <:partyparrot:123456> and <a:dance:789>"
+	for handling, want := range map[string]string{
+		"":      input,
+		"strip": "nice  and",
+		"name":  "nice :partyparrot: and :dance:",
+		"url":   "nice https://cdn.discordapp.com/emojis/123456.png and https://cdn.discordapp.com/emojis/789.gif",
+		"bogus": input,
+	} {
+		br.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"irc.freenode.CustomEmojiHandling": handling}}
+		msg := &config.Message{Text: input, Account: "irc.freenode"}
+		gw.modifyMessage(msg)
+		assert.Equalf(t, want, msg.Text, "CustomEmojiHandling %q failed", handling)
+	}
+
+	// a message with only custom emoji is empty when stripped
+	br.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"irc.freenode.CustomEmojiHandling": "strip"}}
+	msg := &config.Message{Text: "<:partyparrot:123456>", Account: "irc.freenode"}
+	gw.modifyMessage(msg)
+	assert.Equal(t, "", msg.Text)
+	// regular emoji and text are unchanged
+	msg = &config.Message{Text: "<3 :<", Account: "irc.freenode"}
+	gw.modifyMessage(msg)
+	assert.Equal(t, "<3 :<", msg.Text)
+}
@@ -0,0 +1,22 @@
+package gateway
+
+import (
+	"sync/atomic"
+)
+
+// debugSampler decides which of a stream of debug lines are logged.
+// It has to be allocated on its own to keep count 64-bit aligned for atomic.
+type debugSampler struct {
+	count uint64
+}
+
+// sample returns true if the next line needs to be logged to log a fraction rate
+// of all lines. The lines are sampled evenly and deterministically, eg every
+// fourth line for a rate of 0.25. All lines are logged for a rate <= 0 or >= 1.
+func (s *debugSampler) sample(rate float64) bool {
+	if rate <= 0 || rate >= 1 {
+		return true
+	}
+	n := atomic.AddUint64(&s.count, 1)
+	return uint64(float64(n)*rate) != uint64(float64(n-1)*rate)
+}
@@ -0,0 +1,39 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugSampler(t *testing.T) {
+	rateTests := map[string]struct {
+		rate   float64
+		logged int
+	}{
+		"disabled":   {rate: 0, logged: 1000},
+		"all":        {rate: 1, logged: 1000},
+		"half":       {rate: 0.5, logged: 500},
+		"tenth":      {rate: 0.1, logged: 100},
+		"third":      {rate: 1.0 / 3, logged: 333},
+		"thousandth": {rate: 0.001, logged: 1},
+	}
+	for testname, testcase := range rateTests {
+		s := &debugSampler{}
+		logged := 0
+		for i := 0; i < 1000; i++ {
+			if s.sample(testcase.rate) {
+				logged++
+			}
+		}
+		assert.InDeltaf(t, testcase.logged, logged, 1, "case '%s' failed", testname)
+	}
+
+	// lines are sampled evenly
+	s := &debugSampler{}
+	var sampled []bool
+	for i := 0; i < 8; i++ {
+		sampled = append(sampled, s.sample(0.25))
+	}
+	assert.Equal(t, []bool{false, false, false, true, false, false, false, true}, sampled)
+}
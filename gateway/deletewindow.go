@@ -0,0 +1,26 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// deleteExpired returns true if the delete rmsg needs to be ignored for channel on dest
+// because the deleted message was sent there longer than DeleteWindow ago.
+func (gw *Gateway) deleteExpired(rmsg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) bool {
+	window := dest.GetInt("DeleteWindow")
+	if rmsg.Event != config.EventMsgDelete || window <= 0 {
+		return false
+	}
+	id := gw.getDestBrMsgID(rmsg.Protocol+" "+rmsg.ID, dest, channel)
+	if id == nil || id.SentAt.IsZero() {
+		return false
+	}
+	if age := gw.now().Sub(id.SentAt); age > time.Duration(window)*time.Second {
+		gw.logger.Infof("ignoring delete of message %s on %s, it was sent %s ago", rmsg.ID, dest.Account, age.Round(time.Second))
+		return true
+	}
+	return false
+}
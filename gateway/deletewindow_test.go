@@ -0,0 +1,46 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteWindow(t *testing.T) {
+	r := maketestRouterWithMap(testconfigUpdate, testBridgeMap)
+	gw := r.Gateways["main"]
+	br := gw.Bridges["discord.test"]
+	cfg := br.Config
+	defer func() { br.Config = cfg }()
+	br.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"discord.test.DeleteWindow": 60}}
+	discord := testBridgerOf(gw, "discord.test")
+	irc := testBridgerOf(gw, "irc.test")
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	gw.now = func() time.Time { return now }
+
+	r.relayMessage(config.Message{Text: "recent", Username: "alice", Account: "api.test", Channel: "api", Gateway: "main", ID: "1"})
+	r.relayMessage(config.Message{Text: "old", Username: "alice", Account: "api.test", Channel: "api", Gateway: "main", ID: "2"})
+	require.Len(t, discord.messages(), 2)
+
+	// in the window
+	now = now.Add(30 * time.Second)
+	r.relayMessage(config.Message{Account: "api.test", Channel: "api", Gateway: "main", ID: "1", Event: config.EventMsgDelete, Text: config.EventMsgDelete})
+	sent := discord.messages()
+	require.Len(t, sent, 3)
+	assert.Equal(t, config.EventMsgDelete, sent[2].Event)
+	assert.Equal(t, "1", sent[2].ID)
+
+	// out of the window
+	now = now.Add(time.Minute)
+	r.relayMessage(config.Message{Account: "api.test", Channel: "api", Gateway: "main", ID: "2", Event: config.EventMsgDelete, Text: config.EventMsgDelete})
+	assert.Len(t, discord.messages(), 3)
+
+	// destinations without DeleteWindow delete all messages
+	sent = irc.messages()
+	require.Len(t, sent, 4)
+	assert.Equal(t, config.EventMsgDelete, sent[3].Event)
+	assert.Equal(t, "2", sent[3].ID)
+}
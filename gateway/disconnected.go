@@ -0,0 +1,103 @@
+package gateway
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// The values of DisconnectedBehavior.
+const (
+	disconnectedQueue = "queue"
+	disconnectedDrop  = "drop"
+	disconnectedError = "error"
+)
+
+// heldQueueSize is the maximum number of messages held for a disconnected bridge, the
+// oldest message is dropped when more messages arrive.
+const heldQueueSize = 1000
+
+// heldMessages are the messages held for disconnected bridges with DisconnectedBehavior
+// "queue", by account.
+type heldMessages struct {
+	sync.Mutex
+	jobs map[string][]sendJob
+}
+
+func newHeldMessages() *heldMessages {
+	return &heldMessages{jobs: make(map[string][]sendJob)}
+}
+
+// isDisconnected returns true if dest isn't connected. Bridges without a known state,
+// eg before the router started, are considered connected.
+func (gw *Gateway) isDisconnected(dest *bridge.Bridge) bool {
+	state, ok := gw.Router.connections.get(dest.Account)
+	return ok && state.State != StateConnected
+}
+
+// handleDisconnected handles rmsg for channel according to the DisconnectedBehavior of
+// dest if dest is disconnected: the message is held until dest is connected again with
+// "queue", dropped with "drop" or fails with "error". Typing is always dropped. msg is
+// what rmsg became with prepareMessage, held messages are sent as is. Returns true if rmsg is handled
+// and mustn't be sent now.
+func (gw *Gateway) handleDisconnected(rmsg *config.Message, msg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo, parentID string) (bool, error) {
+	behavior := dest.GetString("DisconnectedBehavior")
+	if behavior == "" || !gw.isDisconnected(dest) {
+		return false, nil
+	}
+	// typing is outdated by the time dest is back and not worth an error
+	if rmsg.Event == config.EventUserTyping {
+		return true, nil
+	}
+	switch behavior {
+	case disconnectedQueue:
+		prepared := *msg
+		gw.holdMessage(sendJob{msg: *rmsg, prepared: &prepared, dest: dest, channel: channel, parentID: parentID})
+		return true, nil
+	case disconnectedDrop:
+		gw.logger.Debugf("%s is disconnected, dropping message to %s", dest.Account, channel.Name)
+		return true, nil
+	case disconnectedError:
+		return true, fmt.Errorf("%s is disconnected", dest.Account)
+	}
+	gw.logger.Errorf("unknown DisconnectedBehavior %q of %s", behavior, dest.Account)
+	return false, nil
+}
+
+// holdMessage holds job until its bridge is connected again.
+func (gw *Gateway) holdMessage(job sendJob) {
+	h := gw.held
+	h.Lock()
+	defer h.Unlock()
+	jobs := h.jobs[job.dest.Account]
+	if len(jobs) >= heldQueueSize {
+		gw.logger.Warnf("too many messages held for disconnected %s, dropping the oldest", job.dest.Account)
+		jobs = jobs[1:]
+	}
+	h.jobs[job.dest.Account] = append(jobs, job)
+}
+
+// releaseHeld sends the messages held for account from the send queues of their channels.
+func (gw *Gateway) releaseHeld(account string) {
+	h := gw.held
+	h.Lock()
+	jobs := h.jobs[account]
+	delete(h.jobs, account)
+	h.Unlock()
+	if len(jobs) > 0 {
+		gw.logger.Infof("%s is connected again, sending %d held message(s)", account, len(jobs))
+	}
+	for _, job := range jobs {
+		gw.enqueue(job)
+	}
+}
+
+// bridgeConnected marks account as connected and sends the messages held for it.
+func (r *Router) bridgeConnected(account string) {
+	r.connections.set(account, StateConnected, nil)
+	for _, gw := range r.Gateways {
+		gw.releaseHeld(account)
+	}
+}
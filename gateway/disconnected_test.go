@@ -0,0 +1,128 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigDisconnected = []byte(`
+[mattermost.test]
+server=""
+[irc.test]
+server=""
+DisconnectedBehavior="queue"
+[slack.test]
+server=""
+DisconnectedBehavior="drop"
+[discord.test]
+server=""
+DisconnectedBehavior="error"
+[telegram.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+deadlettergateway="deadletter"
+
+    [[gateway.inout]]
+    account="mattermost.test"
+    channel="town-square"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+
+[[gateway]]
+name="deadletter"
+enable=true
+
+    [[gateway.out]]
+    account="telegram.test"
+    channel="failures"
+`)
+
+func TestDisconnectedBehavior(t *testing.T) {
+	r := maketestRouterWithMap(testconfigDisconnected, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	slack := testBridgerOf(gw, "slack.test")
+	discord := testBridgerOf(gw, "discord.test")
+	telegram := testBridgerOf(r.Gateways["deadletter"], "telegram.test")
+
+	for _, account := range []string{"mattermost.test", "irc.test", "slack.test", "discord.test"} {
+		r.connections.set(account, StateDisconnected, nil)
+	}
+	r.connections.set("mattermost.test", StateConnected, nil)
+
+	r.relayMessage(config.Message{Text: "one", Username: "user", Account: "mattermost.test", Channel: "town-square", ID: "1"})
+	r.relayMessage(config.Message{Text: "two", Username: "user", Account: "mattermost.test", Channel: "town-square", ID: "2"})
+	r.relayMessage(config.Message{Event: config.EventUserTyping, Username: "user", Account: "mattermost.test", Channel: "town-square"})
+
+	// queue holds the messages
+	assert.Empty(t, irc.messages())
+	// drop drops them
+	assert.Empty(t, slack.messages())
+	// error fails them, they end up in the dead letter gateway
+	assert.Empty(t, discord.messages())
+	sent := telegram.messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "one", sent[0].Text)
+	assert.Equal(t, "discord.test is disconnected", sent[0].Extra[config.ExtraDeadLetter][0].(config.DeadLetter).Error)
+
+	// the held messages are sent once the bridge is connected again, without the typing
+	r.bridgeConnected("irc.test")
+	r.bridgeConnected("slack.test")
+	gw.flushSendQueues()
+	sent = irc.messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "one", sent[0].Text)
+	assert.Equal(t, "two", sent[1].Text)
+	assert.Empty(t, slack.messages())
+
+	// the IDs of the held messages are recorded, so edits are sent as edits
+	r.relayMessage(config.Message{Text: "two!", Username: "user", Account: "mattermost.test", Channel: "town-square", ID: "2"})
+	sent = irc.messages()
+	require.Len(t, sent, 3)
+	assert.Equal(t, "2", sent[2].ID)
+
+	// connected bridges are sent to right away
+	r.relayMessage(config.Message{Text: "three", Username: "user", Account: "mattermost.test", Channel: "town-square"})
+	sent = slack.messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "three", sent[1].Text)
+}
+
+func TestDisconnectedQueuePreparedOnce(t *testing.T) {
+	r := maketestRouterWithMap(testconfigDisconnected, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := gw.Bridges["irc.test"]
+	ircCfg := irc.Config
+	defer func() { irc.Config = ircCfg }()
+	irc.Config = &config.TestConfig{Config: ircCfg, Overrides: map[string]interface{}{
+		"irc.test.EditDisplay": "strike-new",
+	}}
+
+	r.relayMessage(config.Message{Text: "old", Username: "user", Account: "mattermost.test", Channel: "town-square", ID: "1"})
+	r.connections.set("irc.test", StateDisconnected, nil)
+	r.relayMessage(config.Message{Text: "new", Username: "user", Account: "mattermost.test", Channel: "town-square", ID: "1"})
+	require.Len(t, testBridgerOf(gw, "irc.test").messages(), 1)
+
+	// the held edit is sent as it was prepared, striking through the old text once
+	r.bridgeConnected("irc.test")
+	gw.flushSendQueues()
+	sent := testBridgerOf(gw, "irc.test").messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "~~old~~ new", sent[1].Text)
+}
@@ -0,0 +1,66 @@
+package gateway
+
+import (
+	"strings"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// noEditProtocols are the protocols that can't edit or delete the messages they sent.
+// Edits and deletes are sent to them as a notice with EditNoticeFormat and
+// DeleteNoticeFormat.
+var noEditProtocols = map[string]bool{
+	"irc":     true,
+	"keybase": true,
+	"msteams": true,
+	"sshchat": true,
+	"steam":   true,
+}
+
+// formatNotice replaces {NICK} and {TEXT} in format.
+func formatNotice(format, nick, text string) string {
+	format = strings.Replace(format, "{NICK}", nick, -1)
+	// last, so tokens in the text itself aren't replaced
+	return strings.Replace(format, "{TEXT}", text, -1)
+}
+
+// sendsEditNotices returns true if edits or deletes are sent as a notice to dest.
+func sendsEditNotices(dest *bridge.Bridge) bool {
+	return noEditProtocols[dest.Protocol] &&
+		(dest.GetString("EditNoticeFormat") != "" || dest.GetString("DeleteNoticeFormat") != "")
+}
+
+// modifyEditNotice turns msg, prepared for dest from the edit or delete rmsg, into a new
+// message with the EditNoticeFormat or DeleteNoticeFormat of dest if dest can't edit or
+// delete messages. Without the format the edit is sent as is and the delete ignored.
+func (gw *Gateway) modifyEditNotice(rmsg *config.Message, msg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) {
+	if rmsg.ID == "" || !noEditProtocols[dest.Protocol] {
+		return
+	}
+	// only messages sent to channel before are edited or deleted
+	id := gw.getDestBrMsgID(rmsg.Protocol+" "+rmsg.ID, dest, channel)
+	if id == nil {
+		return
+	}
+	switch {
+	case rmsg.Event == config.EventMsgDelete:
+		format := dest.GetString("DeleteNoticeFormat")
+		if format == "" {
+			return
+		}
+		msg.Text = formatNotice(format, id.Username, id.Text)
+		msg.Username = "system"
+		msg.Event = ""
+	case rmsg.Event == "" || rmsg.Event == config.EventUserAction:
+		format := dest.GetString("EditNoticeFormat")
+		if format == "" {
+			return
+		}
+		msg.Text = formatNotice(format, rmsg.Username, msg.Text)
+	default:
+		return
+	}
+	// this is a new message, not an edit of the original
+	msg.ID = ""
+}
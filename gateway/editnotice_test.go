@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigEditNotice = []byte(`
+[slack.test]
+server=""
+[irc.test]
+server=""
+EditNoticeFormat="{NICK} edited: {TEXT}"
+DeleteNoticeFormat="[{NICK} deleted \"{TEXT}\"]"
+[discord.test]
+server=""
+EditNoticeFormat="{NICK} edited: {TEXT}"
+DeleteNoticeFormat="{NICK} deleted: {TEXT}"
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+`)
+
+func TestEditNoticeFormat(t *testing.T) {
+	noedit := &testNoEditBridger{testBridger: &testBridger{}}
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["irc"] = func(cfg *bridge.Config) bridge.Bridger { return noedit }
+
+	r := maketestRouterWithMap(testconfigEditNotice, bridgeMap)
+	gw := r.Gateways["main"]
+	discord := testBridgerOf(gw, "discord.test")
+
+	r.relayMessage(config.Message{Text: "helo", Username: "alice", Account: "slack.test", Channel: "main", ID: "1"})
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "slack.test", Channel: "main", ID: "1"})
+	r.relayMessage(config.Message{Text: "bye", Username: "alice", Account: "slack.test", Channel: "main", ID: "2"})
+	r.relayMessage(config.Message{Text: config.EventMsgDelete, Event: config.EventMsgDelete, Account: "slack.test", Channel: "main", ID: "1"})
+	// deletes of unknown messages aren't turned into a notice
+	r.relayMessage(config.Message{Text: config.EventMsgDelete, Event: config.EventMsgDelete, Account: "slack.test", Channel: "main", ID: "3"})
+
+	sent := noedit.messages()
+	require.Len(t, sent, 5)
+	assert.Equal(t, "helo", sent[0].Text)
+	assert.Equal(t, "alice edited: hello", sent[1].Text)
+	assert.Equal(t, "", sent[1].ID)
+	assert.Equal(t, "bye", sent[2].Text)
+	assert.Equal(t, `[alice deleted "hello"]`, sent[3].Text)
+	assert.Equal(t, "", sent[3].Event)
+	assert.Equal(t, "system", sent[3].Username)
+	assert.Equal(t, config.EventMsgDelete, sent[4].Event)
+
+	// discord edits and deletes natively
+	sent = discord.messages()
+	require.Len(t, sent, 5)
+	assert.Equal(t, "hello", sent[1].Text)
+	assert.Equal(t, "1", sent[1].ID)
+	assert.Equal(t, config.EventMsgDelete, sent[3].Event)
+}
@@ -0,0 +1,42 @@
+package gateway
+
+import (
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// The EditedToEmptyBehavior values other than the default "ignore".
+const (
+	emptyEditDelete  = "delete"
+	emptyEditForward = "forward-empty"
+)
+
+// isEditedToEmpty returns true if msg is an edit of a relayed message to an empty text,
+// which some platforms use as a soft delete.
+func (gw *Gateway) isEditedToEmpty(msg *config.Message) bool {
+	if msg.ID == "" || msg.Text != "" || msg.Event != "" && msg.Event != config.EventUserAction || hasFiles(msg) {
+		return false
+	}
+	_, ok := gw.Messages.Get(msg.Protocol + " " + msg.ID)
+	return ok
+}
+
+// handleEditedToEmpty returns the message the edit to empty rmsg is sent as to dest
+// according to its EditedToEmptyBehavior: a delete, the empty edit itself, or nothing
+// (false) by default.
+func (gw *Gateway) handleEditedToEmpty(rmsg *config.Message, dest *bridge.Bridge) (*config.Message, bool) {
+	if !gw.isEditedToEmpty(rmsg) {
+		return rmsg, true
+	}
+	switch dest.GetString("EditedToEmptyBehavior") {
+	case emptyEditDelete:
+		del := *rmsg
+		del.Event = config.EventMsgDelete
+		del.Text = config.EventMsgDelete
+		return &del, true
+	case emptyEditForward:
+		return rmsg, true
+	}
+	gw.logger.Debugf("ignoring edit of %s to an empty message for %s", rmsg.ID, dest.Account)
+	return rmsg, false
+}
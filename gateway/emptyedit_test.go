@@ -0,0 +1,71 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigEmptyEdit = []byte(`
+[slack.test]
+server=""
+[discord.test]
+server=""
+EditedToEmptyBehavior="delete"
+[telegram.test]
+server=""
+EditedToEmptyBehavior="forward-empty"
+[mattermost.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="telegram.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="mattermost.test"
+    channel="main"
+`)
+
+func TestEditedToEmptyBehavior(t *testing.T) {
+	r := maketestRouterWithMap(testconfigEmptyEdit, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "slack.test", Channel: "main", ID: "m1"})
+	r.relayMessage(config.Message{Text: "", Username: "alice", Account: "slack.test", Channel: "main", ID: "m1"})
+	// empty messages that aren't an edit are still ignored
+	r.relayMessage(config.Message{Text: "", Username: "alice", Account: "slack.test", Channel: "main", ID: "m2"})
+
+	discord := testBridgerOf(gw, "discord.test").messages()
+	require.Len(t, discord, 2)
+	assert.Equal(t, config.EventMsgDelete, discord[1].Event)
+	assert.Equal(t, "1", discord[1].ID)
+
+	telegram := testBridgerOf(gw, "telegram.test").messages()
+	require.Len(t, telegram, 2)
+	assert.Equal(t, "", telegram[1].Event)
+	assert.Equal(t, "", telegram[1].Text)
+	assert.Equal(t, "1", telegram[1].ID)
+
+	// ignored by default, later edits still find the message
+	assert.Len(t, testBridgerOf(gw, "mattermost.test").messages(), 1)
+	r.relayMessage(config.Message{Text: "hello again", Username: "alice", Account: "slack.test", Channel: "main", ID: "m1"})
+	mattermost := testBridgerOf(gw, "mattermost.test").messages()
+	require.Len(t, mattermost, 2)
+	assert.Equal(t, "1", mattermost[1].ID)
+	assert.Equal(t, "hello again", mattermost[1].Text)
+}
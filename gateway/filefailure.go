@@ -0,0 +1,32 @@
+package gateway
+
+import (
+	"fmt"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// fileFailureNotice returns the text sent for a file that couldn't be forwarded.
+func fileFailureNotice(name string) string {
+	return fmt.Sprintf("(file %s could not be forwarded)", name)
+}
+
+// notifyFileFailure sends a notice for every file of msg to dest when NotifyFileFailure
+// is enabled on dest, sending msg failed with sendErr.
+func (gw *Gateway) notifyFileFailure(msg config.Message, dest *bridge.Bridge, sendErr error) {
+	if !hasFiles(&msg) || !dest.GetBool("NotifyFileFailure") {
+		return
+	}
+	for _, f := range msg.Extra["file"] {
+		fi := f.(config.FileInfo)
+		gw.logger.Debugf("file %s could not be forwarded to %s: %s", fi.Name, dest.Account, sendErr)
+		notice := msg
+		notice.Text = fileFailureNotice(fi.Name)
+		notice.Extra = nil
+		notice.ID = ""
+		if _, err := gw.send(dest, notice); err != nil {
+			gw.logger.Errorf("sending file failure notice to %s failed: %s", dest.Account, err)
+		}
+	}
+}
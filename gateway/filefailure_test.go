@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fileFailBridger fails to send messages with files.
+type fileFailBridger struct {
+	testBridger
+}
+
+func (b *fileFailBridger) Send(msg config.Message) (string, error) {
+	if hasFiles(&msg) {
+		return "", errors.New("upload failed")
+	}
+	return b.testBridger.Send(msg)
+}
+
+func TestNotifyFileFailure(t *testing.T) {
+	discord := &fileFailBridger{}
+	irc := &fileFailBridger{}
+	r := maketestRouterWithMap(testconfigUpdate, map[string]bridge.Factory{
+		"api":     newTestBridger,
+		"discord": func(*bridge.Config) bridge.Bridger { return discord },
+		"irc":     func(*bridge.Config) bridge.Bridger { return irc },
+	})
+	gw := r.Gateways["main"]
+	br := gw.Bridges["discord.test"]
+	cfg := br.Config
+	defer func() { br.Config = cfg }()
+	br.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"discord.test.NotifyFileFailure": true}}
+
+	data := []byte("data")
+	r.relayMessage(config.Message{
+		Text: "pictures", Username: "alice", Account: "api.test", Channel: "api", Gateway: "main",
+		Extra: map[string][]interface{}{"file": {
+			config.FileInfo{Name: "cat.png", Data: &data},
+			config.FileInfo{Name: "dog.png", Data: &data},
+		}},
+	})
+
+	sent := discord.messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "(file cat.png could not be forwarded)", sent[0].Text)
+	assert.Equal(t, "(file dog.png could not be forwarded)", sent[1].Text)
+	assert.Nil(t, sent[0].Extra)
+	assert.Equal(t, "general", sent[0].Channel)
+
+	// irc doesn't have NotifyFileFailure
+	assert.Empty(t, irc.messages())
+
+	// messages without files don't get a notice
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "api.test", Channel: "api", Gateway: "main"})
+	sent = discord.messages()
+	require.Len(t, sent, 3)
+	assert.Equal(t, "hello", sent[2].Text)
+}
@@ -0,0 +1,184 @@
+package gateway
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// fileType returns the MIME type of fi, based on the extension of its name or on
+// its data when the extension is unknown.
+func fileType(fi *config.FileInfo) string {
+	t := mime.TypeByExtension(filepath.Ext(fi.Name))
+	if t == "" && fi.Data != nil {
+		t = http.DetectContentType(*fi.Data)
+	}
+	if t == "" {
+		t = "application/octet-stream"
+	}
+	// remove parameters like charset
+	if i := strings.Index(t, ";"); i >= 0 {
+		t = t[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+// matchFileType returns true if the MIME type t matches one of patterns, eg
+// "image/png" or "image/*".
+func matchFileType(t string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if pattern == t || pattern == "*/*" ||
+			strings.HasSuffix(pattern, "/*") && strings.HasPrefix(t, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterFileTypes removes the files of msg that dest doesn't want according to its
+// AllowedFileTypes and BlockedFileTypes. The Extra of msg is replaced instead of
+// changed, it's shared with the messages sent to other bridges.
+func (gw *Gateway) filterFileTypes(msg *config.Message, dest *bridge.Bridge) {
+	allowed := dest.GetStringSlice("AllowedFileTypes")
+	blocked := dest.GetStringSlice("BlockedFileTypes")
+	if !hasFiles(msg) || len(allowed) == 0 && len(blocked) == 0 {
+		return
+	}
+	var files []interface{}
+	for _, f := range msg.Extra["file"] {
+		fi := f.(config.FileInfo)
+		t := fileType(&fi)
+		if len(allowed) > 0 && !matchFileType(t, allowed) || matchFileType(t, blocked) {
+			gw.logger.Debugf("not sending file %s (%s) to %s", fi.Name, t, dest.Account)
+			continue
+		}
+		files = append(files, f)
+	}
+	extra := make(map[string][]interface{}, len(msg.Extra))
+	for k, v := range msg.Extra {
+		extra[k] = v
+	}
+	if len(files) > 0 {
+		extra["file"] = files
+	} else {
+		delete(extra, "file")
+	}
+	msg.Extra = extra
+}
+
+// forwardFiles returns false if the ForwardFiles option of channel disables files.
+func forwardFiles(channel *config.ChannelInfo) bool {
+	return channel.Options.ForwardFiles == nil || *channel.Options.ForwardFiles
+}
+
+// dropFiles removes all files of msg if channel doesn't want them, its text is still sent.
+func (gw *Gateway) dropFiles(msg *config.Message, channel *config.ChannelInfo) {
+	if !hasFiles(msg) || forwardFiles(channel) {
+		return
+	}
+	gw.logger.Debugf("not sending %d files to %s (ForwardFiles)", len(msg.Extra["file"]), channel.ID)
+	extra := make(map[string][]interface{}, len(msg.Extra))
+	for k, v := range msg.Extra {
+		if k != "file" {
+			extra[k] = v
+		}
+	}
+	msg.Extra = extra
+}
+
+// sentFileKey is the key of a file sent to a channel in the cache of AttachmentDedupWindow.
+type sentFileKey struct {
+	channel string
+	name    string
+	size    int64
+}
+
+// fileSize returns the size of fi, from its data if it doesn't know it.
+func fileSize(fi *config.FileInfo) int64 {
+	if fi.Size == 0 && fi.Data != nil {
+		return int64(len(*fi.Data))
+	}
+	return fi.Size
+}
+
+// dedupFiles removes the files of msg with the same name and size as a file sent to
+// channel less than the AttachmentDedupWindow (in seconds) of dest ago. The files are
+// recorded by rememberSentFiles once they're sent.
+func (gw *Gateway) dedupFiles(msg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) {
+	window := time.Duration(dest.GetInt("AttachmentDedupWindow")) * time.Second
+	if !hasFiles(msg) || window <= 0 {
+		return
+	}
+	now := gw.now()
+	var files []interface{}
+	for _, f := range msg.Extra["file"] {
+		fi := f.(config.FileInfo)
+		key := sentFileKey{channel: channel.ID, name: fi.Name, size: fileSize(&fi)}
+		if v, ok := gw.sentFiles.Get(key); ok && now.Sub(v.(time.Time)) < window {
+			gw.logger.Debugf("not sending file %s to %s again (AttachmentDedupWindow)", fi.Name, channel.ID)
+			continue
+		}
+		files = append(files, f)
+	}
+	if len(files) == len(msg.Extra["file"]) {
+		return
+	}
+	extra := make(map[string][]interface{}, len(msg.Extra))
+	for k, v := range msg.Extra {
+		extra[k] = v
+	}
+	if len(files) > 0 {
+		extra["file"] = files
+	} else {
+		delete(extra, "file")
+	}
+	msg.Extra = extra
+}
+
+// rememberSentFiles records the files of msg as sent to channel, for dedupFiles.
+func (gw *Gateway) rememberSentFiles(msg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) {
+	if !hasFiles(msg) || dest.GetInt("AttachmentDedupWindow") <= 0 {
+		return
+	}
+	now := gw.now()
+	for _, f := range msg.Extra["file"] {
+		fi := f.(config.FileInfo)
+		gw.sentFiles.Add(sentFileKey{channel: channel.ID, name: fi.Name, size: fileSize(&fi)}, now)
+	}
+}
+
+// limitAttachments drops the files of msg after the first MaxAttachments of dest. With
+// MaxAttachmentsNotice a notice like "(+2 more files)" is added to the text instead.
+func (gw *Gateway) limitAttachments(msg *config.Message, dest *bridge.Bridge) {
+	max := dest.GetInt("MaxAttachments")
+	if max <= 0 || len(msg.Extra["file"]) <= max {
+		return
+	}
+	dropped := len(msg.Extra["file"]) - max
+	gw.logger.Debugf("not sending %d files of %d to %s", dropped, len(msg.Extra["file"]), dest.Account)
+	extra := make(map[string][]interface{}, len(msg.Extra))
+	for k, v := range msg.Extra {
+		extra[k] = v
+	}
+	extra["file"] = msg.Extra["file"][:max:max]
+	msg.Extra = extra
+	if !dest.GetBool("MaxAttachmentsNotice") {
+		return
+	}
+	notice := fmt.Sprintf("(+%d more files)", dropped)
+	if dropped == 1 {
+		notice = "(+1 more file)"
+	}
+	if msg.Text == "" {
+		msg.Text = notice
+	} else {
+		msg.Text += "\n" + notice
+	}
+}
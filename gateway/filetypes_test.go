@@ -0,0 +1,280 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileType(t *testing.T) {
+	pdf := []byte("%PDF-1.4 data")
+	binary := []byte{0x4d, 0x5a, 0x90, 0x00, 0x03}
+	msgTests := map[string]struct {
+		input  config.FileInfo
+		output string
+	}{
+		"extension":           {input: config.FileInfo{Name: "cat.PNG"}, output: "image/png"},
+		"extension with data": {input: config.FileInfo{Name: "doc.pdf", Data: &binary}, output: "application/pdf"},
+		"content":             {input: config.FileInfo{Name: "doc", Data: &pdf}, output: "application/pdf"},
+		"binary content":      {input: config.FileInfo{Name: "setup", Data: &binary}, output: "application/octet-stream"},
+		"text content":        {input: config.FileInfo{Name: "notes", Data: &[]byte{'h', 'i'}}, output: "text/plain"},
+		"nothing":             {input: config.FileInfo{Name: "unknown"}, output: "application/octet-stream"},
+	}
+	for testname, testcase := range msgTests {
+		assert.Equalf(t, testcase.output, fileType(&testcase.input), "case '%s' failed", testname)
+	}
+}
+
+func TestMatchFileType(t *testing.T) {
+	assert.True(t, matchFileType("image/png", []string{"image/png"}))
+	assert.True(t, matchFileType("image/png", []string{"Image/*"}))
+	assert.True(t, matchFileType("image/png", []string{"*/*"}))
+	assert.False(t, matchFileType("image/png", []string{"image/gif", "video/*"}))
+	assert.False(t, matchFileType("imagery/png", []string{"image/*"}))
+	assert.False(t, matchFileType("image/png", nil))
+}
+
+func TestSendMessageFileTypes(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	irc := gw.Bridges["irc.freenode"]
+	slack := gw.Bridges["slack.test"]
+	ircCfg, slackCfg := irc.Config, slack.Config
+	defer func() { irc.Config, slack.Config = ircCfg, slackCfg }()
+	irc.Config = &config.TestConfig{Config: ircCfg, Overrides: map[string]interface{}{
+		"irc.freenode.BlockedFileTypes": []string{"application/octet-stream"},
+	}}
+	slack.Config = &config.TestConfig{Config: slackCfg, Overrides: map[string]interface{}{
+		"slack.test.AllowedFileTypes": []string{"image/*"},
+	}}
+
+	binary := []byte{0x4d, 0x5a, 0x90, 0x00, 0x03}
+	newMsg := func(text string) config.Message {
+		return config.Message{
+			Text: text, Username: "user", Account: "discord.test", Channel: "general",
+			Extra: map[string][]interface{}{"file": {
+				config.FileInfo{Name: "cat.png"},
+				config.FileInfo{Name: "setup", Data: &binary},
+				config.FileInfo{Name: "doc.pdf"},
+			}},
+		}
+	}
+	fileNames := func(msg config.Message) []string {
+		var names []string
+		for _, f := range msg.Extra["file"] {
+			names = append(names, f.(config.FileInfo).Name)
+		}
+		return names
+	}
+
+	r.relayMessage(newMsg("files"))
+	sent := testBridgerOf(gw, "irc.freenode").messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "files", sent[0].Text)
+	assert.Equal(t, []string{"cat.png", "doc.pdf"}, fileNames(sent[0]))
+	sent = testBridgerOf(gw, "slack.test").messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, []string{"cat.png"}, fileNames(sent[0]))
+	// bridges without a filter get all files
+	assert.Len(t, testBridgerOf(gw, "gitter.42wim").messages()[0].Extra["file"], 3)
+
+	// a message with only blocked files isn't sent, the text is kept otherwise
+	msg := newMsg("")
+	msg.Extra["file"] = msg.Extra["file"][1:2]
+	r.relayMessage(msg)
+	assert.Len(t, testBridgerOf(gw, "irc.freenode").messages(), 1)
+	msg = newMsg("only text")
+	msg.Extra["file"] = msg.Extra["file"][1:2]
+	r.relayMessage(msg)
+	sent = testBridgerOf(gw, "irc.freenode").messages()
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "only text", sent[1].Text)
+	assert.False(t, hasFiles(&sent[1]))
+}
+
+func TestSendMessageMaxAttachments(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	irc := gw.Bridges["irc.freenode"]
+	slack := gw.Bridges["slack.test"]
+	ircCfg, slackCfg := irc.Config, slack.Config
+	defer func() { irc.Config, slack.Config = ircCfg, slackCfg }()
+	irc.Config = &config.TestConfig{Config: ircCfg, Overrides: map[string]interface{}{
+		"irc.freenode.MaxAttachments": 1,
+	}}
+	slack.Config = &config.TestConfig{Config: slackCfg, Overrides: map[string]interface{}{
+		"slack.test.MaxAttachments":       2,
+		"slack.test.MaxAttachmentsNotice": true,
+	}}
+
+	newMsg := func(text string, names ...string) config.Message {
+		var files []interface{}
+		for _, name := range names {
+			files = append(files, config.FileInfo{Name: name})
+		}
+		return config.Message{
+			Text: text, Username: "user", Account: "discord.test", Channel: "general",
+			Extra: map[string][]interface{}{"file": files},
+		}
+	}
+	fileNames := func(msg config.Message) []string {
+		var names []string
+		for _, f := range msg.Extra["file"] {
+			names = append(names, f.(config.FileInfo).Name)
+		}
+		return names
+	}
+
+	r.relayMessage(newMsg("files", "a.png", "b.png", "c.png", "d.png"))
+	sent := testBridgerOf(gw, "irc.freenode").messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "files", sent[0].Text)
+	assert.Equal(t, []string{"a.png"}, fileNames(sent[0]))
+	sent = testBridgerOf(gw, "slack.test").messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "files\n(+2 more files)", sent[0].Text)
+	assert.Equal(t, []string{"a.png", "b.png"}, fileNames(sent[0]))
+	// bridges without a limit get all files
+	assert.Len(t, testBridgerOf(gw, "gitter.42wim").messages()[0].Extra["file"], 4)
+
+	// without text the notice is the text
+	r.relayMessage(newMsg("", "a.png", "b.png", "c.png"))
+	sent = testBridgerOf(gw, "slack.test").messages()
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "(+1 more file)", sent[1].Text)
+
+	// messages within the limit are unchanged
+	r.relayMessage(newMsg("two", "a.png", "b.png"))
+	sent = testBridgerOf(gw, "slack.test").messages()
+	assert.Len(t, sent, 3)
+	assert.Equal(t, "two", sent[2].Text)
+	assert.Equal(t, []string{"a.png", "b.png"}, fileNames(sent[2]))
+}
+
+var testconfigForwardFiles = []byte(`
+[discord.test]
+server=""
+[slack.test]
+server=""
+[irc.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="general"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="files"
+
+        [gateway.inout.options]
+        forwardfiles=true
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="nofiles"
+
+        [gateway.inout.options]
+        forwardfiles=false
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+`)
+
+func TestForwardFilesChannelOption(t *testing.T) {
+	r := maketestRouterWithMap(testconfigForwardFiles, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	file := func(text string) config.Message {
+		return config.Message{
+			Text: text, Username: "user", Account: "discord.test", Channel: "general",
+			Extra: map[string][]interface{}{"file": {config.FileInfo{Name: "cat.png"}}},
+		}
+	}
+	r.relayMessage(file("with text"))
+	r.relayMessage(file(""))
+
+	sent := map[string][]config.Message{}
+	for _, account := range []string{"slack.test", "irc.test"} {
+		for _, msg := range testBridgerOf(gw, account).messages() {
+			sent[msg.Channel] = append(sent[msg.Channel], msg)
+		}
+	}
+	// channels without the option get the files
+	for _, channel := range []string{"files", "#main"} {
+		assert.Len(t, sent[channel], 2, channel)
+		for _, msg := range sent[channel] {
+			assert.Len(t, msg.Extra["file"], 1, channel)
+		}
+	}
+	// only the text is sent to nofiles, messages without text aren't sent at all
+	assert.Len(t, sent["nofiles"], 1)
+	assert.Equal(t, "with text", sent["nofiles"][0].Text)
+	assert.Empty(t, sent["nofiles"][0].Extra["file"])
+}
+
+func TestAttachmentDedupWindow(t *testing.T) {
+	r := maketestRouterWithMap(testconfigForwardFiles, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := gw.Bridges["irc.test"]
+	ircCfg := irc.Config
+	defer func() { irc.Config = ircCfg }()
+	irc.Config = &config.TestConfig{Config: ircCfg, Overrides: map[string]interface{}{
+		"irc.test.AttachmentDedupWindow": 60,
+	}}
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	gw.now = func() time.Time { return now }
+
+	logo := []byte("logo")
+	files := func(text string, files ...config.FileInfo) config.Message {
+		extra := map[string][]interface{}{}
+		for _, fi := range files {
+			extra["file"] = append(extra["file"], fi)
+		}
+		return config.Message{Text: text, Username: "user", Account: "discord.test", Channel: "general", Extra: extra}
+	}
+	fileNames := func(msg config.Message) []string {
+		var names []string
+		for _, f := range msg.Extra["file"] {
+			names = append(names, f.(config.FileInfo).Name)
+		}
+		return names
+	}
+
+	r.relayMessage(files("first", config.FileInfo{Name: "logo.png", Data: &logo}, config.FileInfo{Name: "cat.png", Size: 10}))
+	r.relayMessage(files("second", config.FileInfo{Name: "logo.png", Size: 4}, config.FileInfo{Name: "cat.png", Size: 20}))
+	// a message with only duplicates isn't sent
+	r.relayMessage(files("", config.FileInfo{Name: "logo.png", Size: 4}))
+	now = now.Add(time.Minute)
+	r.relayMessage(files("expired", config.FileInfo{Name: "logo.png", Size: 4}))
+
+	sent := testBridgerOf(gw, "irc.test").messages()
+	require.Len(t, sent, 3)
+	assert.Equal(t, []string{"logo.png", "cat.png"}, fileNames(sent[0]))
+	assert.Equal(t, "second", sent[1].Text)
+	assert.Equal(t, []string{"cat.png"}, fileNames(sent[1]))
+	assert.Equal(t, []string{"logo.png"}, fileNames(sent[2]))
+	// a file that failed to send isn't a duplicate of the next one
+	testBridgerOf(gw, "irc.test").sendErr = errors.New("irc is down")
+	r.relayMessage(files("failed", config.FileInfo{Name: "dog.png", Size: 5}))
+	testBridgerOf(gw, "irc.test").sendErr = nil
+	r.relayMessage(files("retried", config.FileInfo{Name: "dog.png", Size: 5}))
+	sent = testBridgerOf(gw, "irc.test").messages()
+	require.Len(t, sent, 4)
+	assert.Equal(t, []string{"dog.png"}, fileNames(sent[3]))
+	// bridges without the window get every file
+	for _, msg := range testBridgerOf(gw, "slack.test").messages() {
+		if msg.Channel == "files" {
+			assert.NotEmpty(t, msg.Extra["file"])
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"sort"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// delayedMessage is a message held for the ForwardDelay of its bridge, so it can still be
+// dropped when it's deleted right away.
+type delayedMessage struct {
+	key   string
+	msg   config.Message
+	held  time.Time
+	timer *time.Timer
+}
+
+// delayForward holds msg for the ForwardDelay (in milliseconds) of its bridge. Returns true
+// if msg is held or handled: a delete of a held message drops both, an edit replaces the
+// held message. Messages without ID can't be deleted and aren't held.
+func (r *Router) delayForward(msg *config.Message) bool {
+	br := r.getBridge(msg.Account)
+	delay := time.Duration(br.GetInt("ForwardDelay")) * time.Millisecond
+	if delay <= 0 || msg.ID == "" {
+		return false
+	}
+	key := msg.Account + " " + msg.ID
+	if d, ok := r.delayed[key]; ok {
+		switch msg.Event {
+		case config.EventMsgDelete:
+			r.logger.Debugf("not forwarding message %s from %s, it was deleted within ForwardDelay", msg.ID, msg.Account)
+			d.timer.Stop()
+			delete(r.delayed, key)
+			return true
+		case "", config.EventUserAction:
+			d.msg = *msg
+			return true
+		}
+		return false
+	}
+	if msg.Event != "" && msg.Event != config.EventUserAction {
+		return false
+	}
+	d := &delayedMessage{key: key, msg: *msg, held: time.Now()}
+	d.timer = time.AfterFunc(delay, func() {
+		r.delayedExpired <- d
+	})
+	r.delayed[key] = d
+	return true
+}
+
+// forwardDelayed relays the message of d when its ForwardDelay elapsed.
+func (r *Router) forwardDelayed(d *delayedMessage) {
+	if r.delayed[d.key] != d {
+		return
+	}
+	delete(r.delayed, d.key)
+	d.timer.Stop()
+	r.forwardMessage(d.msg)
+}
+
+// flushDelayed relays all messages held for ForwardDelay in the order they were received.
+func (r *Router) flushDelayed() {
+	held := make([]*delayedMessage, 0, len(r.delayed))
+	for _, d := range r.delayed {
+		held = append(held, d)
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i].held.Before(held[j].held) })
+	for _, d := range held {
+		r.forwardDelayed(d)
+	}
+}
@@ -0,0 +1,96 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigForwardDelay = []byte(`
+[slack.test]
+server=""
+ForwardDelay=50
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+`)
+
+// waitDelayed returns the next message of r whose ForwardDelay elapsed.
+func waitDelayed(t *testing.T, r *Router) *delayedMessage {
+	t.Helper()
+	select {
+	case d := <-r.delayedExpired:
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("ForwardDelay never elapsed")
+	}
+	return nil
+}
+
+func TestForwardDelay(t *testing.T) {
+	r := maketestRouterWithMap(testconfigForwardDelay, testBridgeMap)
+	gw := r.Gateways["main"]
+	discord := testBridgerOf(gw, "discord.test")
+	deleted := func(id string) config.Message {
+		return config.Message{ID: id, Text: config.EventMsgDelete, Event: config.EventMsgDelete, Account: "slack.test", Channel: "main"}
+	}
+
+	// a quick delete cancels forwarding, the delete isn't relayed either
+	r.relayMessage(config.Message{ID: "m1", Text: "oops", Username: "alice", Account: "slack.test", Channel: "main"})
+	r.relayMessage(deleted("m1"))
+	assert.Empty(t, discord.messages())
+	assert.Empty(t, r.delayed)
+
+	// an edit within the delay replaces the held message
+	start := time.Now()
+	r.relayMessage(config.Message{ID: "m2", Text: "helo", Username: "alice", Account: "slack.test", Channel: "main"})
+	r.relayMessage(config.Message{ID: "m2", Text: "hello", Username: "alice", Account: "slack.test", Channel: "main"})
+	assert.Empty(t, discord.messages())
+	r.forwardDelayed(waitDelayed(t, r))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond, "message forwarded before ForwardDelay")
+	sent := discord.messages()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "hello", sent[0].Text)
+
+	// a slow delete is relayed as usual
+	r.relayMessage(deleted("m2"))
+	sent = discord.messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, config.EventMsgDelete, sent[1].Event)
+	assert.Equal(t, "1", sent[1].ID)
+
+	// messages of bridges without ForwardDelay aren't held
+	r.relayMessage(config.Message{ID: "d1", Text: "hi", Username: "bob", Account: "discord.test", Channel: "main"})
+	assert.Len(t, testBridgerOf(gw, "slack.test").messages(), 1)
+}
+
+func TestFlushDelayed(t *testing.T) {
+	r := maketestRouterWithMap(testconfigForwardDelay, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	for _, id := range []string{"m1", "m2", "m3"} {
+		r.relayMessage(config.Message{ID: id, Text: id, Username: "alice", Account: "slack.test", Channel: "main"})
+	}
+	r.flushDelayed()
+
+	var texts []string
+	for _, msg := range testBridgerOf(gw, "discord.test").messages() {
+		texts = append(texts, msg.Text)
+	}
+	assert.Equal(t, []string{"m1", "m2", "m3"}, texts)
+	assert.Empty(t, r.delayed)
+}
@@ -1,19 +1,22 @@
 package gateway
 
 import (
-	"io/ioutil"
+	"crypto/md5" //nolint:gosec
+	"fmt"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/42wim/matterbridge/bridge"
 	"github.com/42wim/matterbridge/bridge/config"
-	"github.com/42wim/matterbridge/internal"
 	"github.com/d5/tengo/v2"
-	"github.com/d5/tengo/v2/stdlib"
 	lru "github.com/hashicorp/golang-lru"
-	"github.com/matterbridge/emoji"
 	"github.com/sirupsen/logrus"
 )
 
@@ -27,15 +30,77 @@ type Gateway struct {
 	ChannelOptions map[string]config.ChannelOptions
 	Message        chan config.Message
 	Name           string
-	Messages       *lru.Cache
+	Messages       MessageStore
 
-	logger *logrus.Entry
+	logger     *logrus.Entry
+	counter    *counter
+	archive    *archive
+	modifiers  []MessageModifier
+	scripts    *scriptCache
+	regexps    *regexCache
+	emojiMaps  *emojiMapCache
+	locations  *locationCache
+	uploads    *lru.Cache
+	linkTitles *lru.Cache // titles fetched for UnfurlLinks
+	repeats    *lru.Cache // last forward of texts for RepeatSuppressInterval
+	userNicks  *lru.Cache // nicks of the senders by user ID for ResolveMentions
+	broadcasts *lru.Cache // times the texts of the announcements were sent
+	readables  *lru.Cache // messages relayed, to show their ShowReadReceipts
+	seen       *lru.Cache // readers of the messages by canonical key
+	sentFiles  *lru.Cache // times files were sent to channels for AttachmentDedupWindow
+	closed     chan struct{}
+	disabled   int32 // set by SetEnabled, accessed atomically
+
+	maintenanceLock sync.RWMutex
+	maintenance     maintenance
+
+	sendQueues *sendQueues
+	held       *heldMessages
+	sendLimit  sendLimiter
+	smoother   *smoother
+	batches    *messageBatches
+	observers  observers
+
+	translations *translations
+	lastNicks    *lastNicks
+	spam         *spamFilter
+
+	languageDetector languageDetector
+
+	sendLogSampler *debugSampler
+
+	// channelsLock protects Channels while it is read by ChannelSnapshot.
+	channelsLock sync.RWMutex
+
+	joinLeaveBatches map[string]*joinLeaveBatch
+
+	reactionBatches map[string]*reactionBatch
+	reactionTotals  *lru.Cache
+
+	pendingTyping map[string]*pendingTyping
+
+	receiptUpdates map[string]*readReceiptUpdate
+
+	memberCounts map[memberCountKey]int
+
+	now               func() time.Time
+	activeHours       *activeHours
+	outsideHoursQueue []config.Message
+	outsideHoursTimer *time.Timer
+	digestOverflow    int // messages that didn't fit in the digest of quiet hours
 }
 
 type BrMsgID struct {
 	br        *bridge.Bridge
 	ID        string
 	ChannelID string
+	// Text is the text of the message that was sent, used by EditDisplay.
+	Text string
+	// Username is the sender of the message that was sent, used by ReplyStyle and
+	// ReactionNotice.
+	Username string
+	// SentAt is when the message was sent, used by DeleteWindow.
+	SentAt time.Time
 }
 
 const apiProtocol = "api"
@@ -43,40 +108,91 @@ const apiProtocol = "api"
 // New creates a new Gateway object associated with the specified router and
 // following the given configuration.
 func New(rootLogger *logrus.Logger, cfg *config.Gateway, r *Router) *Gateway {
+	return NewWithMessageStore(rootLogger, cfg, r, NewLRUMessageStore(defaultMessageStoreSize))
+}
+
+// NewWithMessageStore creates a new Gateway object like New, which uses store to
+// keep track of the IDs of the messages it sent.
+func NewWithMessageStore(rootLogger *logrus.Logger, cfg *config.Gateway, r *Router, store MessageStore) *Gateway {
 	logger := rootLogger.WithFields(logrus.Fields{"prefix": "gateway"})
 
-	cache, _ := lru.New(5000)
+	uploads, _ := lru.New(1000)
+	linkTitles, _ := lru.New(100)
+	reactionTotals, _ := lru.New(1000)
+	repeats, _ := lru.New(1000)
+	userNicks, _ := lru.New(5000)
+	broadcasts, _ := lru.New(100)
+	readables, _ := lru.New(1000)
+	seen, _ := lru.New(1000)
+	sentFiles, _ := lru.New(1000)
 	gw := &Gateway{
-		Channels: make(map[string]*config.ChannelInfo),
-		Message:  r.Message,
-		Router:   r,
-		Bridges:  make(map[string]*bridge.Bridge),
-		Config:   r.Config,
-		Messages: cache,
-		logger:   logger,
+		Channels:         make(map[string]*config.ChannelInfo),
+		Message:          r.Message,
+		Router:           r,
+		Bridges:          make(map[string]*bridge.Bridge),
+		Config:           r.Config,
+		Messages:         store,
+		logger:           logger,
+		scripts:          newScriptCache(),
+		regexps:          newRegexCache(),
+		emojiMaps:        newEmojiMapCache(),
+		locations:        newLocationCache(),
+		uploads:          uploads,
+		linkTitles:       linkTitles,
+		repeats:          repeats,
+		userNicks:        userNicks,
+		broadcasts:       broadcasts,
+		readables:        readables,
+		seen:             seen,
+		sentFiles:        sentFiles,
+		sendQueues:       newSendQueues(),
+		held:             newHeldMessages(),
+		sendLimit:        newSendLimiter(cfg.MaxConcurrentSends),
+		smoother:         newSmoother(),
+		batches:          newMessageBatches(),
+		translations:     newTranslations(),
+		spam:             newSpamFilter(),
+		lastNicks:        newLastNicks(),
+		sendLogSampler:   &debugSampler{},
+		joinLeaveBatches: make(map[string]*joinLeaveBatch),
+		reactionBatches:  make(map[string]*reactionBatch),
+		reactionTotals:   reactionTotals,
+		pendingTyping:    make(map[string]*pendingTyping),
+		receiptUpdates:   make(map[string]*readReceiptUpdate),
+		memberCounts:     make(map[memberCountKey]int),
+		closed:           make(chan struct{}),
+		now:              time.Now,
 	}
+	data := gw.BridgeValues().General.TengoScriptData
+	gw.AddModifier(&tengoModifier{name: "TengoModifyMessage", filename: gw.BridgeValues().General.TengoModifyMessage, data: data, scripts: gw.scripts})
+	gw.AddModifier(&tengoModifier{name: "Tengo.Message", filename: gw.BridgeValues().Tengo.Message, data: data, scripts: gw.scripts})
+	gw.AddModifier(MessageModifierFunc(gw.prefixUserFlags))
 	if err := gw.AddConfig(cfg); err != nil {
 		logger.Errorf("Failed to add configuration to gateway: %#v", err)
 	}
+	var err error
+	if gw.counter, err = newCounter(cfg.CountFile); err != nil {
+		logger.Errorf("Failed to read CountFile %s of gateway %s: %s", cfg.CountFile, gw.Name, err)
+	}
+	if cfg.ArchiveFile != "" {
+		gw.archive = newArchive(cfg.ArchiveFile, cfg.ArchiveFormat, cfg.ArchiveMaxSize)
+	}
+	if gw.activeHours, err = parseActiveHours(cfg.ActiveHours); err != nil {
+		logger.Errorf("Failed to parse ActiveHours of gateway %s, relaying all the time: %s", gw.Name, err)
+	}
 	return gw
 }
 
 // FindCanonicalMsgID returns the ID under which a message was stored in the cache.
 func (gw *Gateway) FindCanonicalMsgID(protocol string, mID string) string {
 	ID := protocol + " " + mID
-	if gw.Messages.Contains(ID) {
+	if _, ok := gw.Messages.Get(ID); ok {
 		return mID
 	}
 
 	// If not keyed, iterate through cache for downstream, and infer upstream.
-	for _, mid := range gw.Messages.Keys() {
-		v, _ := gw.Messages.Peek(mid)
-		ids := v.([]*BrMsgID)
-		for _, downstreamMsgObj := range ids {
-			if ID == downstreamMsgObj.ID {
-				return strings.Replace(mid.(string), protocol+" ", "", 1)
-			}
-		}
+	if mid := gw.Messages.FindCanonical(ID); mid != "" {
+		return strings.Replace(mid, protocol+" ", "", 1)
 	}
 	return ""
 }
@@ -94,11 +210,16 @@ func (gw *Gateway) AddBridge(cfg *config.Bridge) error {
 			Remote: gw.Message,
 			Bridge: br,
 		}
-		// add the actual bridger for this protocol to this bridge using the bridgeMap
-		if _, ok := gw.Router.BridgeMap[br.Protocol]; !ok {
-			gw.logger.Fatalf("Incorrect protocol %s specified in gateway configuration %s, exiting.", br.Protocol, cfg.Account)
+		// add the actual bridger for this protocol to this bridge using the bridgeMap,
+		// webhooks are sent by the gateway itself, see sendWebhook
+		if br.Protocol == webhookProtocol {
+			br.Bridger = webhookBridger{}
+		} else {
+			if _, ok := gw.Router.BridgeMap[br.Protocol]; !ok {
+				gw.logger.Fatalf("Incorrect protocol %s specified in gateway configuration %s, exiting.", br.Protocol, cfg.Account)
+			}
+			br.Bridger = gw.Router.BridgeMap[br.Protocol](brconfig)
 		}
-		br.Bridger = gw.Router.BridgeMap[br.Protocol](brconfig)
 	}
 	gw.mapChannelsToBridge(br)
 	gw.Bridges[cfg.Account] = br
@@ -143,23 +264,33 @@ func (gw *Gateway) mapChannelsToBridge(br *bridge.Bridge) {
 	}
 }
 
+// reconnectDelay is the time between disconnecting and reconnecting a bridge, and
+// reconnectRetryDelay the time before trying again after a failed reconnect.
+var (
+	reconnectDelay      = time.Second * 5
+	reconnectRetryDelay = time.Second * 60
+)
+
 func (gw *Gateway) reconnectBridge(br *bridge.Bridge) {
 	if err := br.Disconnect(); err != nil {
 		gw.logger.Errorf("Disconnect() %s failed: %s", br.Account, err)
 	}
-	time.Sleep(time.Second * 5)
+	time.Sleep(reconnectDelay)
 RECONNECT:
 	gw.logger.Infof("Reconnecting %s", br.Account)
+	gw.Router.connections.set(br.Account, StateConnecting, nil)
 	err := br.Connect()
 	if err != nil {
-		gw.logger.Errorf("Reconnection failed: %s. Trying again in 60 seconds", err)
-		time.Sleep(time.Second * 60)
+		gw.logger.Errorf("Reconnection failed: %s. Trying again in %s", err, reconnectRetryDelay)
+		gw.Router.connections.set(br.Account, StateDisconnected, err)
+		time.Sleep(reconnectRetryDelay)
 		goto RECONNECT
 	}
 	br.Joined = make(map[string]bool)
 	if err := br.JoinChannels(); err != nil {
 		gw.logger.Errorf("JoinChannels() %s failed: %s", br.Account, err)
 	}
+	gw.Router.bridgeConnected(br.Account)
 }
 
 func (gw *Gateway) mapChannelConfig(cfg []config.Bridge, direction string) {
@@ -202,73 +333,108 @@ func (gw *Gateway) mapChannelConfig(cfg []config.Bridge, direction string) {
 }
 
 func (gw *Gateway) mapChannels() error {
+	gw.channelsLock.Lock()
+	defer gw.channelsLock.Unlock()
 	gw.mapChannelConfig(gw.MyConfig.In, "in")
 	gw.mapChannelConfig(gw.MyConfig.Out, "out")
 	gw.mapChannelConfig(gw.MyConfig.InOut, "inout")
 	return nil
 }
 
+// SetEnabled enables or disables gw at runtime. A disabled gateway keeps its bridges
+// connected and receiving, but doesn't send messages anywhere.
+func (gw *Gateway) SetEnabled(enabled bool) {
+	var disabled int32
+	if !enabled {
+		disabled = 1
+	}
+	atomic.StoreInt32(&gw.disabled, disabled)
+}
+
+// Enabled returns false if gw has been disabled with SetEnabled.
+func (gw *Gateway) Enabled() bool {
+	return atomic.LoadInt32(&gw.disabled) == 0
+}
+
 func (gw *Gateway) getDestChannel(msg *config.Message, dest bridge.Bridge) []config.ChannelInfo {
+	if !gw.Enabled() || gw.InMaintenance() || !acceptsLanguage(msg, &dest) {
+		return nil
+	}
 	var channels []config.ChannelInfo
+	for _, channel := range computeDestinations(gw.Name, msg, gw.Channels, dest) {
+		if gw.acceptsAttachments(msg, &dest, &channel) {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
+// computeDestinations returns the channels of dest in channels, the channels of gateway,
+// to which msg needs to be sent.
+func computeDestinations(gateway string, msg *config.Message, channels map[string]*config.ChannelInfo, dest bridge.Bridge) []config.ChannelInfo {
+	var dests []config.ChannelInfo
+	validGatewayDest := msg.Gateway == gateway
 
 	// for messages received from the api check that the gateway is the specified one
-	if msg.Protocol == apiProtocol && gw.Name != msg.Gateway {
-		return channels
+	if msg.Protocol == apiProtocol && !validGatewayDest {
+		return dests
 	}
 
-	// discord join/leave is for the whole bridge, isn't a per channel join/leave
-	if msg.Event == config.EventJoinLeave && getProtocol(msg) == "discord" && msg.Channel == "" {
-		for _, channel := range gw.Channels {
+	// discord join/leave and voice activity is for the whole bridge, isn't a per channel join/leave
+	if (msg.Event == config.EventJoinLeave || isVoiceActivity(msg)) && getProtocol(msg) == "discord" && msg.Channel == "" {
+		for _, channel := range channels {
 			if channel.Account == dest.Account && strings.Contains(channel.Direction, "out") &&
-				gw.validGatewayDest(msg) {
-				channels = append(channels, *channel)
+				validGatewayDest {
+				dests = append(dests, *channel)
 			}
 		}
-		return channels
+		return dests
 	}
 
-	// if source channel is in only, do nothing
-	for _, channel := range gw.Channels {
-		// lookup the channel from the message
-		if channel.ID == getChannelID(msg) {
-			// we only have destinations if the original message is from an "in" (sending) channel
-			if !strings.Contains(channel.Direction, "in") {
-				return channels
-			}
-			continue
-		}
+	// lookup the channel from the message
+	source, ok := channels[getChannelID(msg)]
+	if !ok {
+		return dests
+	}
+	// we only have destinations if the original message is from an "in" (sending) channel
+	if !strings.Contains(source.Direction, "in") {
+		return dests
 	}
-	for _, channel := range gw.Channels {
-		if _, ok := gw.Channels[getChannelID(msg)]; !ok {
-			continue
-		}
 
+	for _, channel := range channels {
 		// do samechannelgateway logic
 		if channel.SameChannel[msg.Gateway] {
 			if msg.Channel == channel.Name && msg.Account != dest.Account {
-				channels = append(channels, *channel)
+				dests = append(dests, *channel)
 			}
 			continue
 		}
-		if strings.Contains(channel.Direction, "out") && channel.Account == dest.Account && gw.validGatewayDest(msg) {
-			channels = append(channels, *channel)
+		if strings.Contains(channel.Direction, "out") && channel.Account == dest.Account && validGatewayDest {
+			dests = append(dests, *channel)
 		}
 	}
-	return channels
+	return dests
 }
 
 func (gw *Gateway) getDestMsgID(msgID string, dest *bridge.Bridge, channel *config.ChannelInfo) string {
-	if res, ok := gw.Messages.Get(msgID); ok {
-		IDs := res.([]*BrMsgID)
+	if id := gw.getDestBrMsgID(msgID, dest, channel); id != nil {
+		return strings.Replace(id.ID, dest.Protocol+" ", "", 1)
+	}
+	return ""
+}
+
+// getDestBrMsgID returns the BrMsgID of the copy of message msgID sent to channel on dest.
+func (gw *Gateway) getDestBrMsgID(msgID string, dest *bridge.Bridge, channel *config.ChannelInfo) *BrMsgID {
+	if IDs, ok := gw.Messages.Get(msgID); ok {
 		for _, id := range IDs {
 			// check protocol, bridge name and channelname
 			// for people that reuse the same bridge multiple times. see #342
 			if dest.Protocol == id.br.Protocol && dest.Name == id.br.Name && channel.ID == id.ChannelID {
-				return strings.Replace(id.ID, dest.Protocol+" ", "", 1)
+				return id
 			}
 		}
 	}
-	return ""
+	return nil
 }
 
 // ignoreTextEmpty returns true if we need to ignore a message with an empty text.
@@ -276,7 +442,11 @@ func (gw *Gateway) ignoreTextEmpty(msg *config.Message) bool {
 	if msg.Text != "" {
 		return false
 	}
-	if msg.Event == config.EventUserTyping {
+	if msg.Event == config.EventUserTyping || msg.Event == config.EventReadReceipt || isPin(msg) {
+		return false
+	}
+	// handled by the EditedToEmptyBehavior of the destinations
+	if gw.isEditedToEmpty(msg) {
 		return false
 	}
 	// we have an attachment or actual bytes, do not ignore
@@ -302,23 +472,74 @@ func (gw *Gateway) ignoreMessage(msg *config.Message) bool {
 		return true
 	}
 
+	// unlike nicks, user IDs don't change when a user renames
+	igUserIDs := strings.Fields(gw.Bridges[msg.Account].GetString("IgnoreUserIDs"))
+	if ignoreUserID(msg.UserID, igUserIDs) {
+		gw.logger.Debugf("ignoring message from user ID %s on %s", msg.UserID, msg.Account)
+		return true
+	}
+
+	if gw.ignoreCommand(msg) {
+		return true
+	}
+
+	if isSelfMessage(msg, gw.Bridges[msg.Account]) {
+		gw.logger.Debugf("ignoring our own message %#v from %s", msg, msg.Account)
+		return true
+	}
+
+	if gw.isBroadcastEcho(msg) {
+		return true
+	}
+
+	// only actual messages need to match AllowMessages, not events like joins or deletes
+	if msg.Event == "" || msg.Event == config.EventUserAction {
+		allowMessages := strings.Fields(gw.Bridges[msg.Account].GetString("AllowMessages"))
+		if !gw.allowText(msg.Text, allowMessages) {
+			return true
+		}
+		if gw.ignoreNonReply(msg) {
+			return true
+		}
+		if gw.isSpam(msg) {
+			return true
+		}
+		if gw.suppressRepeat(msg) {
+			return true
+		}
+	}
+
 	return false
 }
 
+// ignoreNonReply returns true if OnlyBridgeReplies is enabled on the bridge of msg and
+// msg isn't a reply to a message in the cache, ie a message that was bridged.
+func (gw *Gateway) ignoreNonReply(msg *config.Message) bool {
+	if !gw.Bridges[msg.Account].GetBool("OnlyBridgeReplies") {
+		return false
+	}
+	if msg.ParentID != "" && gw.FindCanonicalMsgID(msg.Protocol, msg.ParentID) != "" {
+		return false
+	}
+	gw.logger.Debugf("ignoring message from %s, it's not a reply to a bridged message", msg.Account)
+	return true
+}
+
 func (gw *Gateway) modifyUsername(msg *config.Message, dest *bridge.Bridge) string {
+	msg.Username = gw.nickAlias(msg.Username, getProtocol(msg))
 	if dest.GetBool("StripNick") {
 		re := regexp.MustCompile("[^a-zA-Z0-9]+")
-		msg.Username = re.ReplaceAllString(msg.Username, "")
+		msg.Username = re.ReplaceAllString(msg.Username, dest.GetString("StripNickReplacement"))
 	}
 	nick := dest.GetString("RemoteNickFormat")
 
+	// use the router to find the bridge, messages can come from other gateways (see DeadLetterGateway)
+	br := gw.Router.getBridge(msg.Account)
 	// loop to replace nicks
-	br := gw.Bridges[msg.Account]
 	for _, outer := range br.GetStringSlice2D("ReplaceNicks") {
 		search := outer[0]
 		replace := outer[1]
-		// TODO move compile to bridge init somewhere
-		re, err := regexp.Compile(search)
+		re, err := gw.regexps.compile(search)
 		if err != nil {
 			gw.logger.Errorf("regexp in %s failed: %s", msg.Account, err)
 			break
@@ -326,17 +547,16 @@ func (gw *Gateway) modifyUsername(msg *config.Message, dest *bridge.Bridge) stri
 		msg.Username = re.ReplaceAllString(msg.Username, replace)
 	}
 
+	if length := dest.GetInt("MaxNickLength"); length > 0 {
+		msg.Username = truncateNick(msg.Username, length)
+	}
+
+	if suffix := dest.GetString("NickCollisionSuffix"); suffix != "" && isLocalNick(msg.Username, dest) {
+		msg.Username += suffix
+	}
+
 	if len(msg.Username) > 0 {
-		// fix utf-8 issue #193
-		i := 0
-		for index := range msg.Username {
-			if i == 1 {
-				i = index
-				break
-			}
-			i++
-		}
-		nick = strings.Replace(nick, "{NOPINGNICK}", msg.Username[:i]+"​"+msg.Username[i:], -1)
+		nick = strings.Replace(nick, "{NOPINGNICK}", noPingNick(msg.Username, gw.noPingChar(dest)), -1)
 	}
 
 	nick = strings.Replace(nick, "{BRIDGE}", br.Name, -1)
@@ -345,6 +565,7 @@ func (gw *Gateway) modifyUsername(msg *config.Message, dest *bridge.Bridge) stri
 	nick = strings.Replace(nick, "{LABEL}", br.GetString("Label"), -1)
 	nick = strings.Replace(nick, "{NICK}", msg.Username, -1)
 	nick = strings.Replace(nick, "{CHANNEL}", msg.Channel, -1)
+	nick = strings.Replace(nick, "{COUNT}", strconv.FormatUint(gw.messageCount(msg), 10), -1)
 	tengoNick, err := gw.modifyUsernameTengo(msg, br)
 	if err != nil {
 		gw.logger.Errorf("modifyUsernameTengo error: %s", err)
@@ -353,41 +574,288 @@ func (gw *Gateway) modifyUsername(msg *config.Message, dest *bridge.Bridge) stri
 	return nick
 }
 
+// affixUsername returns username with the UsernamePrefix and UsernameSuffix of dest, which
+// are required by some platforms, eg bot names ending with "bot". Unlike RemoteNickFormat
+// they're added to the formatted username.
+func affixUsername(username string, dest *bridge.Bridge) string {
+	if username == "" {
+		return username
+	}
+	return dest.GetString("UsernamePrefix") + username + dest.GetString("UsernameSuffix")
+}
+
+// nickAlias returns the canonical name of nick on protocol in the NickAliases of the
+// gateway, eg "alice" for {"irc:al1ce"="alice"}. Aliases without protocol are used for
+// every protocol. Nicks without alias are returned as is.
+func (gw *Gateway) nickAlias(nick, protocol string) string {
+	if nick == "" {
+		return nick
+	}
+	if alias, ok := gw.MyConfig.NickAliases[protocol+":"+nick]; ok {
+		return alias
+	}
+	if alias, ok := gw.MyConfig.NickAliases[nick]; ok {
+		return alias
+	}
+	return nick
+}
+
+// isLocalNick returns true if nick is one of the LocalNicks of dest, ignoring case.
+func isLocalNick(nick string, dest *bridge.Bridge) bool {
+	for _, local := range dest.GetStringSlice("LocalNicks") {
+		if strings.EqualFold(nick, local) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateNick shortens nick to at most length runes and appends an ellipsis when it
+// had to cut. Combining characters are kept together with the rune they modify.
+func truncateNick(nick string, length int) string {
+	const ellipsis = "…"
+	if utf8.RuneCountInString(nick) <= length {
+		return nick
+	}
+	count := 0
+	for index, r := range nick {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if count == length {
+			return nick[:index] + ellipsis
+		}
+		count++
+	}
+	return nick
+}
+
+// defaultNoPingChar is the character inserted by {NOPINGNICK} without NoPingChar, a
+// zero-width space.
+const defaultNoPingChar = "\u200b"
+
+// noPingChar returns the NoPingChar of dest, which must be a single character.
+func (gw *Gateway) noPingChar(dest *bridge.Bridge) string {
+	char := dest.GetString("NoPingChar")
+	if char == "" {
+		return defaultNoPingChar
+	}
+	if utf8.RuneCountInString(char) != 1 {
+		gw.logger.Errorf("NoPingChar %#v of %s is not a single character, using a zero-width space", char, dest.Account)
+		return defaultNoPingChar
+	}
+	return char
+}
+
+// noPingNick inserts char after the first character of nick so that the user with the
+// same nick on the destination doesn't get pinged.
+func noPingNick(nick, char string) string {
+	// fix utf-8 issue #193
+	_, i := utf8.DecodeRuneInString(nick)
+	// don't separate the first rune from its combining characters
+	for i < len(nick) {
+		r, size := utf8.DecodeRuneInString(nick[i:])
+		if !unicode.Is(unicode.Mn, r) {
+			break
+		}
+		i += size
+	}
+	return nick[:i] + char + nick[i:]
+}
+
+// modifyAvatar sets the avatar of msg if it doesn't have one yet using the following fallbacks:
+// IconURL (if it can be templated), DefaultAvatarURL and a gravatar based on the nick.
 func (gw *Gateway) modifyAvatar(msg *config.Message, dest *bridge.Bridge) string {
+	if msg.Avatar != "" {
+		return msg.Avatar
+	}
 	iconurl := dest.GetString("IconURL")
-	iconurl = strings.Replace(iconurl, "{NICK}", msg.Username, -1)
-	if msg.Avatar == "" {
-		msg.Avatar = iconurl
+	switch {
+	case iconurl != "" && (msg.Username != "" || !strings.Contains(iconurl, "{NICK}")):
+		msg.Avatar = strings.Replace(iconurl, "{NICK}", msg.Username, -1)
+	case dest.GetString("DefaultAvatarURL") != "":
+		msg.Avatar = dest.GetString("DefaultAvatarURL")
+	case dest.GetBool("GravatarFallback") && msg.Username != "":
+		msg.Avatar = gravatarURL(msg.Username)
 	}
 	return msg.Avatar
 }
 
-func (gw *Gateway) modifyMessage(msg *config.Message) {
-	if err := modifyMessageTengo(gw.BridgeValues().General.TengoModifyMessage, msg); err != nil {
-		gw.logger.Errorf("TengoModifyMessage failed: %s", err)
+// modifySourceChannel returns the text of msg prefixed with its source channel formatted with
+// SourceChannelFormat when ShowSourceChannel is enabled on dest.
+func (gw *Gateway) modifySourceChannel(msg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) string {
+	if !dest.GetBool("ShowSourceChannel") || msg.Text == "" {
+		return msg.Text
+	}
+	// only actual messages, not events like joins or deletes
+	if msg.Event != "" && msg.Event != config.EventUserAction {
+		return msg.Text
+	}
+	// all channels of a samechannel gateway have the same name
+	if channel.SameChannel[msg.Gateway] {
+		return msg.Text
+	}
+	format := dest.GetString("SourceChannelFormat")
+	if format == "" {
+		format = "[from {CHANNEL}] "
+	}
+	br := gw.Router.getBridge(msg.Account)
+	format = strings.Replace(format, "{BRIDGE}", br.Name, -1)
+	format = strings.Replace(format, "{PROTOCOL}", br.Protocol, -1)
+	format = strings.Replace(format, "{GATEWAY}", gw.Name, -1)
+	format = strings.Replace(format, "{LABEL}", br.GetString("Label"), -1)
+	format = strings.Replace(format, "{CHANNEL}", msg.Channel, -1)
+	return format + msg.Text
+}
+
+// modifyProtocolIcon prepends the icon of the source protocol in the ProtocolIcons of the
+// gateway to text when ShowProtocolIcon is set. Protocols without an icon are unchanged.
+func (gw *Gateway) modifyProtocolIcon(msg *config.Message, text string) string {
+	if !gw.MyConfig.ShowProtocolIcon || text == "" {
+		return text
+	}
+	if msg.Event != "" && msg.Event != config.EventUserAction {
+		return text
+	}
+	icon := gw.MyConfig.ProtocolIcons[strings.ToLower(msg.Protocol)]
+	if icon == "" {
+		return text
+	}
+	return icon + " " + text
+}
+
+// modifyEditText returns the text to send to dest for an edit of a message, based
+// on its EditDisplay. "strike-new" shows the previous text struck through before
+// the new text, "inline" (the default) only shows the new text.
+func (gw *Gateway) modifyEditText(rmsg *config.Message, msg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) string {
+	if !strikeEdits(dest) {
+		return msg.Text
+	}
+	id := gw.getDestBrMsgID(rmsg.Protocol+" "+rmsg.ID, dest, channel)
+	if id == nil || id.Text == "" || id.Text == rmsg.Text {
+		return msg.Text
+	}
+	return "~~" + id.Text + "~~ " + msg.Text
+}
+
+// rememberEditText records the text of the edit rmsg once it's sent to channel on dest,
+// the next edit strikes through this text and a delete notice shows it.
+func (gw *Gateway) rememberEditText(rmsg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) {
+	if rmsg.ID == "" || rmsg.Event != "" && rmsg.Event != config.EventUserAction {
+		return
+	}
+	if !strikeEdits(dest) && (!noEditProtocols[dest.Protocol] || dest.GetString("EditNoticeFormat") == "") {
+		return
+	}
+	if id := gw.getDestBrMsgID(rmsg.Protocol+" "+rmsg.ID, dest, channel); id != nil {
+		id.Text = rmsg.Text
+	}
+}
+
+// strikeEdits returns true if edits are shown with the previous text struck through on dest.
+func strikeEdits(dest *bridge.Bridge) bool {
+	return dest.GetString("EditDisplay") == "strike-new"
+}
+
+// keepSentText returns true if the messages sent to dest are recorded even without an
+// ID, because their text is needed for EditDisplay or the edit and delete notices.
+func keepSentText(dest *bridge.Bridge) bool {
+	return strikeEdits(dest) || sendsEditNotices(dest)
+}
+
+// modifyTopicChange returns the text of the topic change rmsg for dest, formatted using
+// TopicChangeFormat when the new topic is known. text is returned for other messages.
+func (gw *Gateway) modifyTopicChange(rmsg *config.Message, dest *bridge.Bridge, text string) string {
+	if rmsg.Event != config.EventTopicChange || rmsg.Extra == nil || len(rmsg.Extra[config.ExtraTopic]) == 0 {
+		return text
+	}
+	// bridges that sync the topic need the original text
+	if dest.GetBool("SyncTopic") {
+		return text
 	}
-	if err := modifyMessageTengo(gw.BridgeValues().Tengo.Message, msg); err != nil {
-		gw.logger.Errorf("Tengo.Message failed: %s", err)
+	topic, ok := rmsg.Extra[config.ExtraTopic][0].(string)
+	if !ok {
+		return text
 	}
+	format := dest.GetString("TopicChangeFormat")
+	if format == "" {
+		format = "{NICK} changed topic to: {TOPIC}"
+	}
+	format = strings.Replace(format, "{NICK}", rmsg.Username, -1)
+	format = strings.Replace(format, "{CHANNEL}", rmsg.Channel, -1)
+	format = strings.Replace(format, "{TOPIC}", topic, -1)
+	return format
+}
 
-	// replace :emoji: to unicode
-	msg.Text = emoji.Sprint(msg.Text)
+// modifyMessageFormat returns text formatted with the MessageTextFormat of dest.
+// Only actual messages are formatted, not events like joins or deletes.
+func (gw *Gateway) modifyMessageFormat(rmsg *config.Message, dest *bridge.Bridge, text string) string {
+	format := dest.GetString("MessageTextFormat")
+	if format == "" || text == "" {
+		return text
+	}
+	if rmsg.Event != "" && rmsg.Event != config.EventUserAction {
+		return text
+	}
+	format = strings.Replace(format, "{NICK}", rmsg.Username, -1)
+	format = strings.Replace(format, "{CHANNEL}", rmsg.Channel, -1)
+	format = strings.Replace(format, "{GATEWAY}", gw.Name, -1)
+	format = strings.Replace(format, "{PROTOCOL}", rmsg.Protocol, -1)
+	format = strings.Replace(format, "{TIMESTAMP}", rmsg.Timestamp.Format("15:04:05"), -1)
+	// last, so tokens in the text itself aren't replaced
+	return strings.Replace(format, "{TEXT}", text, -1)
+}
 
+// gravatarURL returns the URL of a generated gravatar for nick.
+func gravatarURL(nick string) string {
+	hash := md5.Sum([]byte(strings.ToLower(strings.TrimSpace(nick)))) //nolint:gosec
+	return fmt.Sprintf("https://www.gravatar.com/avatar/%x?d=identicon", hash)
+}
+
+// redactMask is what the matches of RedactMessages are replaced with.
+const redactMask = "***"
+
+// redactMessage replaces the matches of the RedactMessages regexps in the text of msg with
+// redactMask. The redacted content itself is never logged.
+func (gw *Gateway) redactMessage(msg *config.Message) {
 	br := gw.Bridges[msg.Account]
-	// loop to replace messages
-	for _, outer := range br.GetStringSlice2D("ReplaceMessages") {
+	for _, outer := range br.GetStringSlice2D("RedactMessages") {
+		if len(outer) == 0 {
+			continue
+		}
 		search := outer[0]
-		replace := outer[1]
-		// TODO move compile to bridge init somewhere
-		re, err := regexp.Compile(search)
+		// the optional second entry describes what is redacted, used for logging
+		name := search
+		if len(outer) > 1 {
+			name = outer[1]
+		}
+		re, err := gw.regexps.compile(search)
 		if err != nil {
 			gw.logger.Errorf("regexp in %s failed: %s", msg.Account, err)
-			break
+			continue
 		}
-		msg.Text = re.ReplaceAllString(msg.Text, replace)
+		matches := len(re.FindAllStringIndex(msg.Text, -1))
+		if matches == 0 {
+			continue
+		}
+		msg.Text = re.ReplaceAllLiteralString(msg.Text, redactMask)
+		gw.logger.Debugf("redacted %d match(es) of %s in message from %s", matches, name, msg.Account)
 	}
+}
 
-	gw.handleExtractNicks(msg)
+func (gw *Gateway) modifyMessage(msg *config.Message) {
+	// redact first so that other modifications don't see the sensitive content
+	gw.redactMessage(msg)
+	gw.stripInboundNick(msg)
+	gw.stripCommand(msg)
+	gw.handleCustomEmoji(msg)
+
+	for _, stage := range gw.transformOrder() {
+		transformStages[stage](gw, msg)
+	}
+	gw.normalizeMessage(msg)
+	gw.resolveMentions(msg)
+	gw.detectLanguage(msg)
 
 	// messages from api have Gateway specified, don't overwrite
 	if msg.Protocol != apiProtocol {
@@ -395,6 +863,22 @@ func (gw *Gateway) modifyMessage(msg *config.Message) {
 	}
 }
 
+// replaceMessages applies the ReplaceMessages of the source bridge to msg.
+func (gw *Gateway) replaceMessages(msg *config.Message) {
+	br := gw.Bridges[msg.Account]
+	// loop to replace messages
+	for _, outer := range br.GetStringSlice2D("ReplaceMessages") {
+		search := outer[0]
+		replace := outer[1]
+		re, err := gw.regexps.compile(search)
+		if err != nil {
+			gw.logger.Errorf("regexp in %s failed: %s", msg.Account, err)
+			break
+		}
+		msg.Text = re.ReplaceAllString(msg.Text, replace)
+	}
+}
+
 // SendMessage sends a message (with specified parentID) to the channel on the selected
 // destination bridge and returns a message ID or an error.
 func (gw *Gateway) SendMessage(
@@ -403,44 +887,195 @@ func (gw *Gateway) SendMessage(
 	channel *config.ChannelInfo,
 	canonicalParentMsgID string,
 ) (string, error) {
+	msg, ok := gw.prepareMessage(rmsg, dest, channel, canonicalParentMsgID)
+	if !ok {
+		return "", nil
+	}
+	return gw.sendPrepared(rmsg, msg, dest, channel, canonicalParentMsgID)
+}
+
+// sendPrepared sends msg, the message rmsg became with prepareMessage, to the channel on
+// the destination bridge and returns a message ID or an error.
+func (gw *Gateway) sendPrepared(
+	rmsg *config.Message,
+	msg config.Message,
+	dest *bridge.Bridge,
+	channel *config.ChannelInfo,
+	canonicalParentMsgID string,
+) (string, error) {
+	if handled, err := gw.handleDisconnected(rmsg, &msg, dest, channel, canonicalParentMsgID); handled {
+		return "", err
+	}
+	gw.joinMessageThreadChannel(rmsg, &msg, dest, channel, canonicalParentMsgID)
+	nick := msg.Username
+	msg.Username = gw.hideRepeatedNick(rmsg, nick, dest, channel)
+	if dest.GetBool("UnfurlLinks") {
+		gw.unfurlLink(rmsg, &msg)
+	}
+	signMessage(&msg, dest)
+
+	// Too noisy to log like other events
+	if msg.Event != config.EventUserTyping && gw.sendLogSampler.sample(gw.BridgeValues().General.DebugSampleRate) {
+		gw.logger.Debugf("=> Sending %#v from %s (%s) to %s (%s)", *rmsg, rmsg.Account, rmsg.Channel, dest.Account, channel.Name)
+	}
+
+	// if we are using mattermost plugin account, send messages to MattermostPlugin channel
+	// that can be picked up by the mattermost matterbridge plugin
+	if dest.Account == "mattermost.plugin" {
+		gw.Router.MattermostPlugin <- msg
+	}
+
+	gw.notifyObservers(msg, dest.Account)
+
+	gw.sendLimit.acquire()
+	defer gw.sendLimit.release()
+
+	if dest.Protocol == webhookProtocol {
+		return gw.sendWebhook(msg, dest)
+	}
+
+	if isReaction(&msg) {
+		return gw.sendReaction(msg, rmsg.Username, gw.messageAuthor(rmsg.Protocol, rmsg.ID), dest)
+	}
+
+	if isPin(&msg) {
+		return gw.sendPin(msg, rmsg.Username, dest)
+	}
+
+	if poll, ok := getPoll(&msg); ok {
+		return gw.sendPoll(msg, poll, dest)
+	}
+
+	if gw.batchMessage(msg, dest, channel) {
+		gw.rememberLastNick(rmsg, nick, dest, channel)
+		gw.rememberSentFiles(&msg, dest, channel)
+		gw.rememberEditText(rmsg, dest, channel)
+		return "", nil
+	}
+
+	var mID string
+	var err error
+	if order := dest.GetString("AttachmentOrder"); order != "" && msg.ID == "" && msg.Text != "" && hasFiles(&msg) {
+		mID, err = gw.sendAttachmentsInOrder(msg, dest, order)
+	} else {
+		mID, err = gw.deliverMessage(msg, dest)
+	}
+	if err != nil {
+		gw.notifyFileFailure(msg, dest, err)
+		return mID, err
+	}
+	gw.rememberLastNick(rmsg, nick, dest, channel)
+	gw.rememberSentFiles(&msg, dest, channel)
+	gw.rememberEditText(rmsg, dest, channel)
+
+	// append the message ID (mID) from this bridge (dest) to our brMsgIDs slice
+	if mID != "" {
+		gw.logger.Debugf("mID %s: %s", dest.Account, mID)
+		return mID, nil
+		//brMsgIDs = append(brMsgIDs, &BrMsgID{dest, dest.Protocol + " " + mID, channel.ID})
+	}
+	return "", nil
+}
+
+// prepareMessage returns the message rmsg (with specified parentID) becomes when it's
+// sent to the channel on the destination bridge. Returns false if nothing should be sent.
+func (gw *Gateway) prepareMessage(
+	rmsg *config.Message,
+	dest *bridge.Bridge,
+	channel *config.ChannelInfo,
+	canonicalParentMsgID string,
+) (config.Message, bool) {
+	if isVoiceActivity(rmsg) {
+		voice := voiceActivityMessage(rmsg)
+		rmsg = &voice
+	}
+	if rmsg.Event == config.EventMemberCount {
+		count := memberCountMessage(rmsg)
+		rmsg = &count
+	}
+	rmsg, ok := gw.handleEditedToEmpty(rmsg, dest)
 	msg := *rmsg
+	if !ok {
+		return msg, false
+	}
 	// Only send the avatar download event to ourselves.
 	if msg.Event == config.EventAvatarDownload {
 		if channel.ID != getChannelID(rmsg) {
-			return "", nil
+			return msg, false
 		}
 	} else {
 		// do not send to ourself for any other event
 		if channel.ID == getChannelID(rmsg) {
-			return "", nil
+			return msg, false
 		}
 	}
 
-	// Too noisy to log like other events
-	if msg.Event != config.EventUserTyping {
-		gw.logger.Debugf("=> Sending %#v from %s (%s) to %s (%s)", msg, msg.Account, rmsg.Channel, dest.Account, channel.Name)
+	msg.Channel = channel.Name
+
+	msg.ParentID = gw.destParentID(rmsg, dest, channel, canonicalParentMsgID)
+	gw.modifyThreadChannel(&msg, dest, channel, canonicalParentMsgID)
+
+	// the reply is threaded on dest, the quote of the parent isn't needed
+	src := rmsg
+	if dest.GetBool("StripReplyQuote") && msg.ParentID != "" && msg.ParentID != "msg-parent-not-found" {
+		stripped := *rmsg
+		stripped.Text = gw.stripReplyQuote(rmsg)
+		src = &stripped
 	}
+	src = gw.translateMessage(src, dest)
 
-	msg.Channel = channel.Name
 	msg.Avatar = gw.modifyAvatar(rmsg, dest)
-	msg.Username = gw.modifyUsername(rmsg, dest)
+	msg.Username = affixUsername(gw.modifyUsername(rmsg, dest), dest)
+	msg.Text = gw.modifySourceChannel(src, dest, channel)
+	msg.Text = gw.modifyProtocolIcon(rmsg, msg.Text)
+	msg.Text = gw.modifyTopicChange(rmsg, dest, msg.Text)
+	msg.Text = gw.modifyMessageFormat(rmsg, dest, msg.Text)
+	msg.Text = gw.modifyTimestampFooter(rmsg, dest, msg.Text)
+	gw.applyReplyStyle(rmsg, &msg, dest, canonicalParentMsgID)
+	msg.Text = convertCodeBlocks(msg.Text, dest.GetString("CodeBlockHandling"))
+	if dest.GetBool("Transliterate") {
+		msg.Username = transliterate(msg.Username)
+		msg.Text = transliterate(msg.Text)
+	}
+	gw.modifyIRCColors(rmsg, &msg, dest)
+	gw.dropFiles(&msg, channel)
+	gw.filterFileTypes(&msg, dest)
+	gw.dedupFiles(&msg, dest, channel)
+	gw.limitAttachments(&msg, dest)
+	if msg.Text == "" && hasFiles(rmsg) && !hasFiles(&msg) {
+		gw.logger.Debugf("all files of %#v blocked, not sending to %s", rmsg, dest.Account)
+		return msg, false
+	}
+	if !dest.GetBool("PreserveTimestamp") {
+		msg.Timestamp = time.Now()
+	}
 
 	msg.ID = gw.getDestMsgID(rmsg.Protocol+" "+rmsg.ID, dest, channel)
+	if isReaction(rmsg) || isPin(rmsg) {
+		msg.ID = gw.getDestCorrelatedMsgID(rmsg.Protocol, rmsg.ID, dest, channel)
+	}
 
-	// for api we need originchannel as channel
-	if dest.Protocol == apiProtocol {
-		msg.Channel = rmsg.Channel
+	if rmsg.ID != "" && (msg.Event == "" || msg.Event == config.EventUserAction) {
+		msg.Text = gw.modifyEditText(rmsg, &msg, dest, channel)
 	}
 
-	msg.ParentID = gw.getDestMsgID(rmsg.Protocol+" "+canonicalParentMsgID, dest, channel)
-	if msg.ParentID == "" {
-		msg.ParentID = canonicalParentMsgID
+	// updates of the username/avatar are sent as an edit of the message on dest
+	if rmsg.Event == config.EventMsgUpdate {
+		if msg.ID == "" {
+			gw.logger.Debugf("update of unknown message, not sending to %s", dest.Account)
+			return msg, false
+		}
+		msg.Event = ""
 	}
 
-	// if the parentID is still empty and we have a parentID set in the original message
-	// this means that we didn't find it in the cache so set it "msg-parent-not-found"
-	if msg.ParentID == "" && rmsg.ParentID != "" {
-		msg.ParentID = "msg-parent-not-found"
+	if gw.deleteExpired(rmsg, dest, channel) {
+		return msg, false
+	}
+	gw.modifyEditNotice(rmsg, &msg, dest, channel)
+
+	// for api we need originchannel as channel
+	if dest.Protocol == apiProtocol {
+		msg.Channel = rmsg.Channel
 	}
 
 	err := gw.modifySendMessageTengo(rmsg, &msg, dest)
@@ -448,28 +1083,81 @@ func (gw *Gateway) SendMessage(
 		gw.logger.Errorf("modifySendMessageTengo: %s", err)
 	}
 
-	// if we are using mattermost plugin account, send messages to MattermostPlugin channel
-	// that can be picked up by the mattermost matterbridge plugin
-	if dest.Account == "mattermost.plugin" {
-		gw.Router.MattermostPlugin <- msg
+	return msg, true
+}
+
+// destParentID returns the ID on dest of the message rmsg replies to.
+func (gw *Gateway) destParentID(rmsg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo, canonicalParentMsgID string) string {
+	parentID := gw.getDestMsgID(rmsg.Protocol+" "+canonicalParentMsgID, dest, channel)
+	if parentID == "" {
+		parentID = canonicalParentMsgID
+	}
+
+	// if the parentID is still empty and we have a parentID set in the original message
+	// this means that we didn't find it in the cache so set it "msg-parent-not-found"
+	if parentID == "" && rmsg.ParentID != "" && !quotesReplies(rmsg.Protocol) {
+		parentID = "msg-parent-not-found"
+	}
+	return parentID
+}
+
+// deliverMessage sends msg to dest, uploading its files with the upload cache and
+// splitting its text if it's too long. Returns the ID of the (first part of the) message.
+func (gw *Gateway) deliverMessage(msg config.Message, dest *bridge.Bridge) (string, error) {
+	if uploader, ok := dest.Bridger.(bridge.FileUploader); ok && dest.GetBool("CacheUploads") && hasFiles(&msg) {
+		return "", gw.sendCachedUploads(msg, dest, uploader)
 	}
 
-	mID, err := dest.Send(msg)
+	parts := limitMessageLength(&msg, dest)
+	msg.Text = parts[0]
+	mID, err := gw.send(dest, msg)
 	if err != nil {
 		return mID, err
 	}
+	for _, part := range parts[1:] {
+		msg.Text = part
+		if _, err := gw.send(dest, msg); err != nil {
+			return mID, err
+		}
+	}
+	return mID, nil
+}
 
-	// append the message ID (mID) from this bridge (dest) to our brMsgIDs slice
-	if mID != "" {
-		gw.logger.Debugf("mID %s: %s", dest.Account, mID)
-		return mID, nil
-		//brMsgIDs = append(brMsgIDs, &BrMsgID{dest, dest.Protocol + " " + mID, channel.ID})
+// countMessage increases the message counter used by {COUNT} in RemoteNickFormat and
+// sets Extra["count"] of msg to the new value, so delayed or queued messages keep it.
+// Only actual messages are counted, not events like joins or typing notifications, nor
+// edits of a message that was counted already.
+func (gw *Gateway) countMessage(msg *config.Message) {
+	if msg.Event != "" && msg.Event != config.EventUserAction {
+		return
 	}
-	return "", nil
+	if msg.ID != "" {
+		if _, ok := gw.Messages.Get(msg.Protocol + " " + msg.ID); ok {
+			return
+		}
+	}
+	count, err := gw.counter.Increment()
+	if err != nil {
+		gw.logger.Errorf("Failed to write CountFile %s: %s", gw.MyConfig.CountFile, err)
+	}
+	// Extra can be shared with the message of other gateways
+	extra := make(map[string][]interface{}, len(msg.Extra)+1)
+	for k, v := range msg.Extra {
+		extra[k] = v
+	}
+	extra[config.ExtraCount] = []interface{}{count}
+	msg.Extra = extra
 }
 
-func (gw *Gateway) validGatewayDest(msg *config.Message) bool {
-	return msg.Gateway == gw.Name
+// messageCount returns the value of the message counter when msg was received, or the
+// current value for messages that weren't counted.
+func (gw *Gateway) messageCount(msg *config.Message) uint64 {
+	if msg.Extra != nil && len(msg.Extra[config.ExtraCount]) > 0 {
+		if count, ok := msg.Extra[config.ExtraCount][0].(uint64); ok {
+			return count
+		}
+	}
+	return gw.counter.Value()
 }
 
 func getChannelID(msg *config.Message) string {
@@ -480,14 +1168,40 @@ func isAPI(account string) bool {
 	return strings.HasPrefix(account, "api.")
 }
 
+// isSelfMessage returns true if IgnoreSelfMessages is enabled on br and msg comes
+// from one of its BotNicks or BotUserIDs, eg our own webhook messages looping back.
+func isSelfMessage(msg *config.Message, br *bridge.Bridge) bool {
+	if !br.GetBool("IgnoreSelfMessages") {
+		return false
+	}
+	for _, nick := range br.GetStringSlice("BotNicks") {
+		if strings.EqualFold(msg.Username, nick) {
+			return true
+		}
+	}
+	return ignoreUserID(msg.UserID, br.GetStringSlice("BotUserIDs"))
+}
+
+// ignoreUserID returns true if userID is one of ids.
+func ignoreUserID(userID string, ids []string) bool {
+	if userID == "" {
+		return false
+	}
+	for _, id := range ids {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
 // ignoreText returns true if text matches any of the input regexes.
 func (gw *Gateway) ignoreText(text string, input []string) bool {
 	for _, entry := range input {
 		if entry == "" {
 			continue
 		}
-		// TODO do not compile regexps everytime
-		re, err := regexp.Compile(entry)
+		re, err := gw.regexps.compile(entry)
 		if err != nil {
 			gw.logger.Errorf("incorrect regexp %s", entry)
 			continue
@@ -500,26 +1214,50 @@ func (gw *Gateway) ignoreText(text string, input []string) bool {
 	return false
 }
 
+// allowText returns true if text matches one of the regexps in input or if input is empty.
+func (gw *Gateway) allowText(text string, input []string) bool {
+	allow := true
+	for _, entry := range input {
+		if entry == "" {
+			continue
+		}
+		re, err := gw.regexps.compile(entry)
+		if err != nil {
+			gw.logger.Errorf("incorrect regexp %s", entry)
+			continue
+		}
+		if re.MatchString(text) {
+			return true
+		}
+		allow = false
+	}
+	if !allow {
+		gw.logger.Debugf("not matching AllowMessages. ignoring %s", text)
+	}
+	return allow
+}
+
 func getProtocol(msg *config.Message) string {
 	p := strings.Split(msg.Account, ".")
 	return p[0]
 }
 
-func modifyMessageTengo(filename string, msg *config.Message) error {
+// modifyMessageTengo runs the tengo script in filename on msg. The entries of data are
+// available in the script as data_<key> variables.
+func modifyMessageTengo(scripts *scriptCache, filename string, msg *config.Message, data map[string]string) error {
 	if filename == "" {
 		return nil
 	}
-	res, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return err
+	vars := map[string]interface{}{
+		"msgText":     msg.Text,
+		"msgUsername": msg.Username,
+		"msgAccount":  msg.Account,
+		"msgChannel":  msg.Channel,
+	}
+	for key, value := range data {
+		vars["data_"+key] = value
 	}
-	s := tengo.NewScript(res)
-	s.SetImports(stdlib.GetModuleMap(stdlib.AllModuleNames()...))
-	_ = s.Add("msgText", msg.Text)
-	_ = s.Add("msgUsername", msg.Username)
-	_ = s.Add("msgAccount", msg.Account)
-	_ = s.Add("msgChannel", msg.Channel)
-	c, err := s.Compile()
+	c, err := scripts.get(filename, vars)
 	if err != nil {
 		return err
 	}
@@ -536,25 +1274,20 @@ func (gw *Gateway) modifyUsernameTengo(msg *config.Message, br *bridge.Bridge) (
 	if filename == "" {
 		return "", nil
 	}
-	res, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return "", err
-	}
-	s := tengo.NewScript(res)
-	s.SetImports(stdlib.GetModuleMap(stdlib.AllModuleNames()...))
-	_ = s.Add("result", "")
-	_ = s.Add("msgText", msg.Text)
-	_ = s.Add("msgUsername", msg.Username)
-	_ = s.Add("nick", msg.Username)
-	_ = s.Add("msgAccount", msg.Account)
-	_ = s.Add("msgChannel", msg.Channel)
-	_ = s.Add("channel", msg.Channel)
-	_ = s.Add("msgProtocol", msg.Protocol)
-	_ = s.Add("remoteAccount", br.Account)
-	_ = s.Add("protocol", br.Protocol)
-	_ = s.Add("bridge", br.Name)
-	_ = s.Add("gateway", gw.Name)
-	c, err := s.Compile()
+	c, err := gw.scripts.get(filename, map[string]interface{}{
+		"result":        "",
+		"msgText":       msg.Text,
+		"msgUsername":   msg.Username,
+		"nick":          msg.Username,
+		"msgAccount":    msg.Account,
+		"msgChannel":    msg.Channel,
+		"channel":       msg.Channel,
+		"msgProtocol":   msg.Protocol,
+		"remoteAccount": br.Account,
+		"protocol":      br.Protocol,
+		"bridge":        br.Name,
+		"gateway":       gw.Name,
+	})
 	if err != nil {
 		return "", err
 	}
@@ -565,35 +1298,28 @@ func (gw *Gateway) modifyUsernameTengo(msg *config.Message, br *bridge.Bridge) (
 }
 
 func (gw *Gateway) modifySendMessageTengo(origmsg *config.Message, msg *config.Message, br *bridge.Bridge) error {
-	filename := gw.BridgeValues().Tengo.OutMessage
-	var res []byte
+	vars := map[string]interface{}{
+		"inAccount":   origmsg.Account,
+		"inProtocol":  origmsg.Protocol,
+		"inChannel":   origmsg.Channel,
+		"inGateway":   origmsg.Gateway,
+		"inEvent":     origmsg.Event,
+		"outAccount":  br.Account,
+		"outProtocol": br.Protocol,
+		"outChannel":  msg.Channel,
+		"outGateway":  gw.Name,
+		"outEvent":    msg.Event,
+		"msgText":     msg.Text,
+		"msgUsername": msg.Username,
+	}
+	var c *tengo.Compiled
 	var err error
+	filename := gw.BridgeValues().Tengo.OutMessage
 	if filename == "" {
-		res, err = internal.Asset("tengo/outmessage.tengo")
-		if err != nil {
-			return err
-		}
+		c, err = gw.scripts.getAsset("tengo/outmessage.tengo", vars)
 	} else {
-		res, err = ioutil.ReadFile(filename)
-		if err != nil {
-			return err
-		}
+		c, err = gw.scripts.get(filename, vars)
 	}
-	s := tengo.NewScript(res)
-	s.SetImports(stdlib.GetModuleMap(stdlib.AllModuleNames()...))
-	_ = s.Add("inAccount", origmsg.Account)
-	_ = s.Add("inProtocol", origmsg.Protocol)
-	_ = s.Add("inChannel", origmsg.Channel)
-	_ = s.Add("inGateway", origmsg.Gateway)
-	_ = s.Add("inEvent", origmsg.Event)
-	_ = s.Add("outAccount", br.Account)
-	_ = s.Add("outProtocol", br.Protocol)
-	_ = s.Add("outChannel", msg.Channel)
-	_ = s.Add("outGateway", gw.Name)
-	_ = s.Add("outEvent", msg.Event)
-	_ = s.Add("msgText", msg.Text)
-	_ = s.Add("msgUsername", msg.Username)
-	c, err := s.Compile()
 	if err != nil {
 		return err
 	}
@@ -1,15 +1,21 @@
 package gateway
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"strconv"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/42wim/matterbridge/bridge"
 	"github.com/42wim/matterbridge/bridge/config"
 	"github.com/42wim/matterbridge/gateway/bridgemap"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -175,15 +181,109 @@ const (
 )
 
 func maketestRouter(input []byte) *Router {
+	return maketestRouterWithMap(input, bridgemap.FullMap)
+}
+
+func maketestRouterWithMap(input []byte, bridgeMap map[string]bridge.Factory) *Router {
 	logger := logrus.New()
 	logger.SetOutput(ioutil.Discard)
 	cfg := config.NewConfigFromString(logger, input)
-	r, err := NewRouter(logger, cfg, bridgemap.FullMap)
+	r, err := NewRouter(logger, cfg, bridgeMap)
 	if err != nil {
 		fmt.Println(err)
 	}
 	return r
 }
+
+// testBridger records the messages sent to it instead of sending them.
+type testBridger struct {
+	sync.Mutex
+
+	sent         []config.Message
+	sendErr      error
+	disconnected bool
+}
+
+func newTestBridger(cfg *bridge.Config) bridge.Bridger {
+	return &testBridger{}
+}
+
+func (b *testBridger) Send(msg config.Message) (string, error) {
+	b.Lock()
+	defer b.Unlock()
+	if b.sendErr != nil {
+		return "", b.sendErr
+	}
+	b.sent = append(b.sent, msg)
+	return strconv.Itoa(len(b.sent)), nil
+}
+
+func (b *testBridger) Connect() error                               { return nil }
+func (b *testBridger) JoinChannel(channel config.ChannelInfo) error { return nil }
+
+func (b *testBridger) Disconnect() error {
+	b.Lock()
+	defer b.Unlock()
+	b.disconnected = true
+	return nil
+}
+
+func (b *testBridger) isDisconnected() bool {
+	b.Lock()
+	defer b.Unlock()
+	return b.disconnected
+}
+
+// waitFor polls condition until it returns true and fails the test after a second.
+// assert.Eventually of this testify version can panic when condition is slow.
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition never satisfied")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// startRouter runs the handleReceive loop of r. The returned func stops it and waits
+// until it returned, so it doesn't outlive the test.
+func startRouter(r *Router) func() {
+	done := make(chan struct{})
+	go func() {
+		r.handleReceive()
+		close(done)
+	}()
+	return func() {
+		close(r.Message)
+		<-done
+	}
+}
+
+func (b *testBridger) messages() []config.Message {
+	b.Lock()
+	defer b.Unlock()
+	return append([]config.Message(nil), b.sent...)
+}
+
+// testBridgeMap maps all protocols to a testBridger.
+var testBridgeMap = map[string]bridge.Factory{
+	"api":        newTestBridger,
+	"discord":    newTestBridger,
+	"gitter":     newTestBridger,
+	"irc":        newTestBridger,
+	"mattermost": newTestBridger,
+	"slack":      newTestBridger,
+	"telegram":   newTestBridger,
+	"xmpp":       newTestBridger,
+}
+
+// testBridgerOf returns the testBridger of account in gw.
+func testBridgerOf(gw *Gateway, account string) *testBridger {
+	return gw.Bridges[account].Bridger.(*testBridger)
+}
+
 func TestNewRouter(t *testing.T) {
 	r := maketestRouter(testconfig)
 	assert.Equal(t, 1, len(r.Gateways))
@@ -418,7 +518,7 @@ func TestIgnoreSuite(t *testing.T) {
 func (s *ignoreTestSuite) SetupSuite() {
 	logger := logrus.New()
 	logger.SetOutput(ioutil.Discard)
-	s.gw = &Gateway{logger: logrus.NewEntry(logger)}
+	s.gw = &Gateway{logger: logrus.NewEntry(logger), regexps: newRegexCache()}
 }
 func (s *ignoreTestSuite) TestIgnoreTextEmpty() {
 	extraFile := make(map[string][]interface{})
@@ -530,12 +630,1311 @@ func (s *ignoreTestSuite) TestIgnoreNicks() {
 	}
 }
 
+func (s *ignoreTestSuite) TestAllowTexts() {
+	msgTests := map[string]struct {
+		input  string
+		re     []string
+		output bool
+	}{
+		"no regex": {
+			input:  "a text message",
+			re:     []string{},
+			output: true,
+		},
+		"simple regex": {
+			input:  "!help",
+			re:     []string{"^!"},
+			output: true,
+		},
+		"simple regex fail": {
+			input:  "a text message",
+			re:     []string{"^!"},
+			output: false,
+		},
+		"multiple regex pass": {
+			input:  "a text message",
+			re:     []string{"^!", "sage$"},
+			output: true,
+		},
+		"invalid regex": {
+			input:  "a text message",
+			re:     []string{"("},
+			output: true,
+		},
+	}
+	for testname, testcase := range msgTests {
+		output := s.gw.allowText(testcase.input, testcase.re)
+		s.Assert().Equalf(testcase.output, output, "case '%s' failed", testname)
+	}
+}
+
+func TestIgnoreMessageAllowMessages(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	br := gw.Bridges["irc.freenode"]
+	cfg := br.Config
+	defer func() { br.Config = cfg }()
+
+	msgTests := map[string]struct {
+		msg       *config.Message
+		overrides map[string]interface{}
+		output    bool
+	}{
+		"no allowlist": {
+			msg:       &config.Message{Text: "hello", Account: "irc.freenode"},
+			overrides: map[string]interface{}{},
+			output:    false,
+		},
+		"allowed": {
+			msg:       &config.Message{Text: "!help", Account: "irc.freenode"},
+			overrides: map[string]interface{}{"irc.freenode.AllowMessages": "^!"},
+			output:    false,
+		},
+		"not allowed": {
+			msg:       &config.Message{Text: "hello", Account: "irc.freenode"},
+			overrides: map[string]interface{}{"irc.freenode.AllowMessages": "^!"},
+			output:    true,
+		},
+		"allowed action": {
+			msg:       &config.Message{Text: "!dance", Account: "irc.freenode", Event: config.EventUserAction},
+			overrides: map[string]interface{}{"irc.freenode.AllowMessages": "^!"},
+			output:    false,
+		},
+		"events are not filtered": {
+			msg:       &config.Message{Text: "user joins", Account: "irc.freenode", Event: config.EventJoinLeave},
+			overrides: map[string]interface{}{"irc.freenode.AllowMessages": "^!"},
+			output:    false,
+		},
+		"allowed but denied": {
+			msg: &config.Message{Text: "!secret", Account: "irc.freenode"},
+			overrides: map[string]interface{}{
+				"irc.freenode.AllowMessages":  "^!",
+				"irc.freenode.IgnoreMessages": "secret",
+			},
+			output: true,
+		},
+		"allowed and not denied": {
+			msg: &config.Message{Text: "!help", Account: "irc.freenode"},
+			overrides: map[string]interface{}{
+				"irc.freenode.AllowMessages":  "^!",
+				"irc.freenode.IgnoreMessages": "secret",
+			},
+			output: false,
+		},
+		"ignored user ID": {
+			msg:       &config.Message{Text: "spam", Username: "spammer", UserID: "spam@example.com", Account: "irc.freenode"},
+			overrides: map[string]interface{}{"irc.freenode.IgnoreUserIDs": "bad@example.com spam@example.com"},
+			output:    true,
+		},
+		"ignored user ID with new nick": {
+			msg:       &config.Message{Text: "spam", Username: "innocent", UserID: "spam@example.com", Account: "irc.freenode"},
+			overrides: map[string]interface{}{"irc.freenode.IgnoreUserIDs": "spam@example.com"},
+			output:    true,
+		},
+		"other user ID": {
+			msg:       &config.Message{Text: "hello", Username: "spammer", UserID: "user@example.com", Account: "irc.freenode"},
+			overrides: map[string]interface{}{"irc.freenode.IgnoreUserIDs": "spam@example.com"},
+			output:    false,
+		},
+		"no user ID": {
+			msg:       &config.Message{Text: "hello", Username: "user", Account: "irc.freenode"},
+			overrides: map[string]interface{}{"irc.freenode.IgnoreUserIDs": "spam@example.com"},
+			output:    false,
+		},
+		"self message by nick": {
+			msg: &config.Message{Text: "hello", Username: "Bridge-Bot", Account: "irc.freenode"},
+			overrides: map[string]interface{}{
+				"irc.freenode.IgnoreSelfMessages": true,
+				"irc.freenode.BotNicks":           []string{"bridge-bot"},
+			},
+			output: true,
+		},
+		"self message by user ID": {
+			msg: &config.Message{Text: "hello", Username: "user", UserID: "bot@example.com", Account: "irc.freenode"},
+			overrides: map[string]interface{}{
+				"irc.freenode.IgnoreSelfMessages": true,
+				"irc.freenode.BotUserIDs":         []string{"bot@example.com"},
+			},
+			output: true,
+		},
+		"self messages not ignored": {
+			msg: &config.Message{Text: "hello", Username: "bridge-bot", UserID: "bot@example.com", Account: "irc.freenode"},
+			overrides: map[string]interface{}{
+				"irc.freenode.BotNicks":   []string{"bridge-bot"},
+				"irc.freenode.BotUserIDs": []string{"bot@example.com"},
+			},
+			output: false,
+		},
+	}
+	for testname, testcase := range msgTests {
+		br.Config = &config.TestConfig{Config: cfg, Overrides: testcase.overrides}
+		assert.Equalf(t, testcase.output, gw.ignoreMessage(testcase.msg), "case '%s' failed", testname)
+	}
+}
+
 func BenchmarkTengo(b *testing.B) {
 	msg := &config.Message{Username: "user", Text: "blah testing", Account: "protocol.account", Channel: "mychannel"}
+	scripts := newScriptCache()
+	for n := 0; n < b.N; n++ {
+		err := modifyMessageTengo(scripts, "bench.tengo", msg, nil)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func BenchmarkTengoReload(b *testing.B) {
+	msg := &config.Message{Username: "user", Text: "blah testing", Account: "protocol.account", Channel: "mychannel"}
+	scripts := newScriptCache()
 	for n := 0; n < b.N; n++ {
-		err := modifyMessageTengo("bench.tengo", msg)
+		scripts.reset()
+		err := modifyMessageTengo(scripts, "bench.tengo", msg, nil)
 		if err != nil {
 			return
 		}
 	}
 }
+
+func TestTruncateNick(t *testing.T) {
+	nickTests := map[string]struct {
+		input  string
+		length int
+		output string
+	}{
+		"short nick": {
+			input:  "user",
+			length: 10,
+			output: "user",
+		},
+		"exact length": {
+			input:  "user",
+			length: 4,
+			output: "user",
+		},
+		"ascii nick": {
+			input:  "verylongusername",
+			length: 4,
+			output: "very…",
+		},
+		"multi-byte nick": {
+			input:  "ünïcödé",
+			length: 3,
+			output: "ünï…",
+		},
+		"cjk nick": {
+			input:  "日本語のユーザー",
+			length: 3,
+			output: "日本語…",
+		},
+		"combining characters": {
+			input:  "e\u0301e\u0301e\u0301e\u0301",
+			length: 2,
+			output: "e\u0301e\u0301…",
+		},
+	}
+	for testname, testcase := range nickTests {
+		output := truncateNick(testcase.input, testcase.length)
+		assert.Equalf(t, testcase.output, output, "case '%s' failed", testname)
+		assert.Truef(t, utf8.ValidString(output), "case '%s' produced invalid utf-8", testname)
+	}
+}
+
+func TestNoPingNick(t *testing.T) {
+	nickTests := map[string]struct {
+		input  string
+		char   string
+		output string
+	}{
+		"ascii nick": {
+			input:  "user",
+			char:   "\u200b",
+			output: "u\u200bser",
+		},
+		"single rune": {
+			input:  "ü",
+			char:   "\u200b",
+			output: "ü\u200b",
+		},
+		"multi-byte nick": {
+			input:  "ünïcödé",
+			char:   "\u200b",
+			output: "ü\u200bnïcödé",
+		},
+		"combining characters": {
+			input:  "e\u0301tienne",
+			char:   "\u200b",
+			output: "e\u0301\u200btienne",
+		},
+		"alternate character": {
+			input:  "user",
+			char:   "\u2060",
+			output: "u\u2060ser",
+		},
+		"visible character": {
+			input:  "ünïcödé",
+			char:   "·",
+			output: "ü·nïcödé",
+		},
+	}
+	for testname, testcase := range nickTests {
+		output := noPingNick(testcase.input, testcase.char)
+		assert.Equalf(t, testcase.output, output, "case '%s' failed", testname)
+	}
+}
+
+func TestModifyUsernameNoPingChar(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	src := gw.Bridges["irc.freenode"]
+	dest := gw.Bridges["slack.test"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+
+	msgTests := map[string]struct {
+		char   string
+		output string
+	}{
+		"default":        {output: "<u\u200bser>"},
+		"alternate":      {char: "\u00ad", output: "<u\u00adser>"},
+		"multiple runes": {char: "--", output: "<u\u200bser>"},
+	}
+	for testname, testcase := range msgTests {
+		dest.Config = &config.TestConfig{
+			Config: cfg,
+			Overrides: map[string]interface{}{
+				"slack.test.RemoteNickFormat": "<{NOPINGNICK}>",
+				"slack.test.NoPingChar":       testcase.char,
+			},
+		}
+		msg := &config.Message{Username: "user", Account: src.Account, Channel: "#wimtesting"}
+		assert.Equalf(t, testcase.output, gw.modifyUsername(msg, dest), "case '%s' failed", testname)
+	}
+}
+
+func TestModifyUsernameMaxNickLength(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	src := gw.Bridges["irc.freenode"]
+	dest := gw.Bridges["slack.test"]
+	cfg := dest.Config
+	dest.Config = &config.TestConfig{
+		Config: cfg,
+		Overrides: map[string]interface{}{
+			"slack.test.MaxNickLength":    3,
+			"slack.test.RemoteNickFormat": "<{NOPINGNICK}>",
+		},
+	}
+	defer func() { dest.Config = cfg }()
+
+	msg := &config.Message{Username: "ünïcödé", Account: src.Account, Channel: "#wimtesting"}
+	assert.Equal(t, "<ü\u200bnï…>", gw.modifyUsername(msg, dest))
+}
+
+func TestModifyUsernameNickCollision(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	src := gw.Bridges["irc.freenode"]
+	dest := gw.Bridges["slack.test"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+
+	local := map[string]interface{}{
+		"slack.test.LocalNicks":          []string{"alice", "Bob"},
+		"slack.test.NickCollisionSuffix": "[bridged]",
+		"slack.test.RemoteNickFormat":    "<{NICK}> ",
+	}
+	msgTests := map[string]struct {
+		nick      string
+		overrides map[string]interface{}
+		output    string
+	}{
+		"collision":            {nick: "alice", overrides: local, output: "<alice[bridged]> "},
+		"collision other case": {nick: "bob", overrides: local, output: "<bob[bridged]> "},
+		"no collision":         {nick: "carol", overrides: local, output: "<carol> "},
+		"partial match":        {nick: "alice2", overrides: local, output: "<alice2> "},
+		"no suffix": {
+			nick: "alice",
+			overrides: map[string]interface{}{
+				"slack.test.LocalNicks":       []string{"alice"},
+				"slack.test.RemoteNickFormat": "<{NICK}> ",
+			},
+			output: "<alice> ",
+		},
+	}
+	for testname, testcase := range msgTests {
+		dest.Config = &config.TestConfig{Config: cfg, Overrides: testcase.overrides}
+		msg := &config.Message{Username: testcase.nick, Account: src.Account, Channel: "#wimtesting"}
+		assert.Equalf(t, testcase.output, gw.modifyUsername(msg, dest), "case '%s' failed", testname)
+	}
+}
+
+func TestModifyUsernameNickAliases(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	dest := gw.Bridges["slack.test"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+	dest.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{
+		"slack.test.RemoteNickFormat": "<{NICK}> ",
+	}}
+	gw.MyConfig.NickAliases = map[string]string{
+		"irc:al1ce":        "alice",
+		"gitter:alice.dev": "alice",
+		"discord:al1ce":    "alice (discord)",
+		"bob_":             "bob",
+		"irc:bob_":         "bob (irc)",
+	}
+
+	msgTests := map[string]struct {
+		nick    string
+		account string
+		output  string
+	}{
+		"alias":                {nick: "al1ce", account: "irc.freenode", output: "<alice> "},
+		"other protocol":       {nick: "alice.dev", account: "gitter.42wim", output: "<alice> "},
+		"alias of protocol":    {nick: "al1ce", account: "discord.test", output: "<alice (discord)> "},
+		"all protocols":        {nick: "bob_", account: "gitter.42wim", output: "<bob> "},
+		"protocol first":       {nick: "bob_", account: "irc.freenode", output: "<bob (irc)> "},
+		"unmapped":             {nick: "carol", account: "irc.freenode", output: "<carol> "},
+		"unmapped on protocol": {nick: "alice.dev", account: "irc.freenode", output: "<alice.dev> "},
+	}
+	for testname, testcase := range msgTests {
+		msg := &config.Message{Username: testcase.nick, Account: testcase.account, Channel: "#wimtesting"}
+		assert.Equalf(t, testcase.output, gw.modifyUsername(msg, dest), "case '%s' failed", testname)
+	}
+}
+
+func TestAffixUsername(t *testing.T) {
+	r := maketestRouterWithMap(testconfigUpdate, testBridgeMap)
+	gw := r.Gateways["main"]
+	dest := gw.Bridges["discord.test"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+
+	msgTests := map[string]struct {
+		overrides map[string]interface{}
+		output    string
+	}{
+		"none": {
+			overrides: map[string]interface{}{"discord.test.RemoteNickFormat": "[{PROTOCOL}] {NICK}"},
+			output:    "[api] alice",
+		},
+		"suffix": {
+			overrides: map[string]interface{}{"discord.test.RemoteNickFormat": "[{PROTOCOL}] {NICK}", "discord.test.UsernameSuffix": " bot"},
+			output:    "[api] alice bot",
+		},
+		"prefix and suffix": {
+			overrides: map[string]interface{}{
+				"discord.test.RemoteNickFormat": "{NICK}",
+				"discord.test.UsernamePrefix":   "~",
+				"discord.test.UsernameSuffix":   "-bot",
+			},
+			output: "~alice-bot",
+		},
+		"after max nick length": {
+			overrides: map[string]interface{}{
+				"discord.test.RemoteNickFormat": "{NICK}",
+				"discord.test.MaxNickLength":    3,
+				"discord.test.UsernameSuffix":   "bot",
+			},
+			output: "ali…bot",
+		},
+		"empty username": {
+			overrides: map[string]interface{}{"discord.test.RemoteNickFormat": "", "discord.test.UsernameSuffix": "bot"},
+			output:    "",
+		},
+	}
+	for testname, testcase := range msgTests {
+		dest.Config = &config.TestConfig{Config: cfg, Overrides: testcase.overrides}
+		rmsg := &config.Message{Text: "hello", Username: "alice", Account: "api.test", Channel: "api", Protocol: "api", Gateway: "main"}
+		msg, ok := gw.prepareMessage(rmsg, dest, gw.Channels["generaldiscord.test"], "")
+		require.Truef(t, ok, "case '%s' failed", testname)
+		assert.Equalf(t, testcase.output, msg.Username, "case '%s' failed", testname)
+	}
+}
+
+var testconfigProtocolIcon = []byte(`
+[slack.test]
+server=""
+[discord.test]
+server=""
+[irc.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+ShowProtocolIcon=true
+ProtocolIcons={discord="\U0001F7EA", slack="\U0001F7E6"}
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="general"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="general"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+`)
+
+func TestProtocolIcon(t *testing.T) {
+	r := maketestRouterWithMap(testconfigProtocolIcon, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	discord := testBridgerOf(gw, "discord.test")
+
+	msgTests := []struct {
+		name   string
+		input  config.Message
+		dest   *testBridger
+		output string
+	}{
+		{
+			name:   "mapped",
+			input:  config.Message{Text: "hello", Username: "alice", Account: "slack.test", Channel: "general"},
+			dest:   irc,
+			output: "\U0001F7E6 hello",
+		},
+		{
+			name:   "other mapped",
+			input:  config.Message{Text: "hello", Username: "alice", Account: "discord.test", Channel: "general"},
+			dest:   irc,
+			output: "\U0001F7EA hello",
+		},
+		{
+			name:   "action",
+			input:  config.Message{Text: "waves", Username: "alice", Account: "slack.test", Channel: "general", Event: config.EventUserAction},
+			dest:   irc,
+			output: "\U0001F7E6 waves",
+		},
+		{
+			name:   "unmapped",
+			input:  config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#main"},
+			dest:   discord,
+			output: "hello",
+		},
+	}
+	for _, testcase := range msgTests {
+		before := len(testcase.dest.messages())
+		r.relayMessage(testcase.input)
+		sent := testcase.dest.messages()
+		require.Lenf(t, sent, before+1, "case '%s' failed", testcase.name)
+		assert.Equalf(t, testcase.output, sent[before].Text, "case '%s' failed", testcase.name)
+	}
+
+	gw.MyConfig.ShowProtocolIcon = false
+	r.relayMessage(config.Message{Text: "bye", Username: "alice", Account: "slack.test", Channel: "general"})
+	sent := irc.messages()
+	assert.Equal(t, "bye", sent[len(sent)-1].Text)
+}
+
+func TestModifyUsernameCount(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	dest := gw.Bridges["slack.test"]
+	cfg := dest.Config
+	dest.Config = &config.TestConfig{
+		Config: cfg,
+		Overrides: map[string]interface{}{
+			"slack.test.RemoteNickFormat": "#{COUNT} <{NICK}> ",
+		},
+	}
+	defer func() { dest.Config = cfg }()
+
+	msg := &config.Message{Username: "user", Account: "irc.freenode", Channel: "#wimtesting"}
+	gw.countMessage(msg)
+	assert.Equal(t, "#1 <user> ", gw.modifyUsername(msg, dest))
+	gw.countMessage(&config.Message{Event: config.EventJoinLeave})
+	second := &config.Message{Username: "user", Account: "irc.freenode", Channel: "#wimtesting", ID: "2", Protocol: "irc"}
+	gw.countMessage(second)
+	assert.Equal(t, "#2 <user> ", gw.modifyUsername(second, dest))
+
+	// the number is the one of when the message was received, not when it's sent
+	gw.countMessage(&config.Message{Username: "other"})
+	assert.Equal(t, "#1 <user> ", gw.modifyUsername(msg, dest))
+	assert.Equal(t, "#2 <user> ", gw.modifyUsername(second, dest))
+
+	// edits of a relayed message aren't counted
+	gw.Messages.Store("irc 2", []*BrMsgID{})
+	edit := &config.Message{Text: "edited", Username: "user", Account: "irc.freenode", Channel: "#wimtesting", ID: "2", Protocol: "irc"}
+	gw.countMessage(edit)
+	assert.Equal(t, uint64(3), gw.counter.Value())
+}
+
+func TestModifyUsernameStripNick(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	dest := gw.Bridges["slack.test"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+
+	nickTests := map[string]struct {
+		replacement string
+		output      string
+	}{
+		"delete": {
+			replacement: "",
+			output:      "username",
+		},
+		"replace": {
+			replacement: "_",
+			output:      "user_name_",
+		},
+	}
+	for testname, testcase := range nickTests {
+		dest.Config = &config.TestConfig{
+			Config: cfg,
+			Overrides: map[string]interface{}{
+				"slack.test.StripNick":            true,
+				"slack.test.StripNickReplacement": testcase.replacement,
+				"slack.test.RemoteNickFormat":     "{NICK}",
+			},
+		}
+		msg := &config.Message{Username: "user.:name!", Account: "irc.freenode", Channel: "#wimtesting"}
+		assert.Equalf(t, testcase.output, gw.modifyUsername(msg, dest), "case '%s' failed", testname)
+	}
+}
+
+func TestModifyAvatar(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	dest := gw.Bridges["slack.test"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+
+	avatarTests := map[string]struct {
+		msg       *config.Message
+		overrides map[string]interface{}
+		output    string
+	}{
+		"message avatar": {
+			msg:       &config.Message{Username: "user", Avatar: "https://avatar/user.png"},
+			overrides: map[string]interface{}{"slack.test.IconURL": "https://icon/{NICK}.png"},
+			output:    "https://avatar/user.png",
+		},
+		"iconurl": {
+			msg:       &config.Message{Username: "user"},
+			overrides: map[string]interface{}{"slack.test.IconURL": "https://icon/{NICK}.png"},
+			output:    "https://icon/user.png",
+		},
+		"iconurl without nick": {
+			msg: &config.Message{},
+			overrides: map[string]interface{}{
+				"slack.test.IconURL":          "https://icon/{NICK}.png",
+				"slack.test.DefaultAvatarURL": "https://default.png",
+			},
+			output: "https://default.png",
+		},
+		"default avatar": {
+			msg:       &config.Message{Username: "user"},
+			overrides: map[string]interface{}{"slack.test.DefaultAvatarURL": "https://default.png"},
+			output:    "https://default.png",
+		},
+		"gravatar": {
+			msg:       &config.Message{Username: " User "},
+			overrides: map[string]interface{}{"slack.test.GravatarFallback": true},
+			output:    "https://www.gravatar.com/avatar/ee11cbb19052e40b07aac0ca060c23ee?d=identicon",
+		},
+		"gravatar without nick": {
+			msg:       &config.Message{},
+			overrides: map[string]interface{}{"slack.test.GravatarFallback": true},
+			output:    "",
+		},
+		"no fallback": {
+			msg:       &config.Message{Username: "user"},
+			overrides: map[string]interface{}{},
+			output:    "",
+		},
+	}
+	for testname, testcase := range avatarTests {
+		dest.Config = &config.TestConfig{Config: cfg, Overrides: testcase.overrides}
+		assert.Equalf(t, testcase.output, gw.modifyAvatar(testcase.msg, dest), "case '%s' failed", testname)
+	}
+}
+
+func TestModifySourceChannel(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	dest := gw.Bridges["slack.test"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+
+	channel := &config.ChannelInfo{SameChannel: map[string]bool{"bridge1": false}}
+	sameChannel := &config.ChannelInfo{SameChannel: map[string]bool{"bridge1": true}}
+	show := map[string]interface{}{"slack.test.ShowSourceChannel": true}
+
+	sourceChannelTests := map[string]struct {
+		msg       *config.Message
+		channel   *config.ChannelInfo
+		overrides map[string]interface{}
+		output    string
+	}{
+		"disabled": {
+			msg:       &config.Message{Text: "hello", Account: "irc.freenode", Channel: "#wimtesting", Gateway: "bridge1"},
+			channel:   channel,
+			overrides: map[string]interface{}{},
+			output:    "hello",
+		},
+		"default format": {
+			msg:       &config.Message{Text: "hello", Account: "irc.freenode", Channel: "#wimtesting", Gateway: "bridge1"},
+			channel:   channel,
+			overrides: show,
+			output:    "[from #wimtesting] hello",
+		},
+		"custom format": {
+			msg:     &config.Message{Text: "hello", Account: "irc.freenode", Channel: "#wimtesting", Gateway: "bridge1"},
+			channel: channel,
+			overrides: map[string]interface{}{
+				"slack.test.ShowSourceChannel":   true,
+				"slack.test.SourceChannelFormat": "({PROTOCOL}/{BRIDGE} {CHANNEL} on {GATEWAY}) ",
+			},
+			output: "(irc/freenode #wimtesting on bridge1) hello",
+		},
+		"user action": {
+			msg:       &config.Message{Text: "waves", Account: "irc.freenode", Channel: "#wimtesting", Gateway: "bridge1", Event: config.EventUserAction},
+			channel:   channel,
+			overrides: show,
+			output:    "[from #wimtesting] waves",
+		},
+		"join event": {
+			msg:       &config.Message{Text: "user joins", Account: "irc.freenode", Channel: "#wimtesting", Gateway: "bridge1", Event: config.EventJoinLeave},
+			channel:   channel,
+			overrides: show,
+			output:    "user joins",
+		},
+		"empty text": {
+			msg:       &config.Message{Account: "irc.freenode", Channel: "#wimtesting", Gateway: "bridge1"},
+			channel:   channel,
+			overrides: show,
+			output:    "",
+		},
+		"samechannel": {
+			msg:       &config.Message{Text: "hello", Account: "irc.freenode", Channel: "#wimtesting", Gateway: "bridge1"},
+			channel:   sameChannel,
+			overrides: show,
+			output:    "hello",
+		},
+	}
+	for testname, testcase := range sourceChannelTests {
+		dest.Config = &config.TestConfig{Config: cfg, Overrides: testcase.overrides}
+		assert.Equalf(t, testcase.output, gw.modifySourceChannel(testcase.msg, dest, testcase.channel), "case '%s' failed", testname)
+	}
+}
+
+var testconfigUpdate = []byte(`
+[general]
+RemoteNickFormat="{NICK}"
+[api.test]
+bindaddress=""
+[discord.test]
+server=""
+[irc.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="api.test"
+    channel="api"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="general"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+`)
+
+func TestSendMessageUpdate(t *testing.T) {
+	r := maketestRouterWithMap(testconfigUpdate, testBridgeMap)
+	gw := r.Gateways["main"]
+	discord := testBridgerOf(gw, "discord.test")
+	irc := testBridgerOf(gw, "irc.test")
+
+	r.relayMessage(config.Message{Text: "hello", Username: "bot", Account: "api.test", Channel: "api", Gateway: "main", ID: "42"})
+	require.Len(t, discord.messages(), 1)
+	require.Len(t, irc.messages(), 1)
+
+	r.relayMessage(config.Message{
+		Text: "hello", Username: "newbot", Avatar: "https://avatar/newbot.png",
+		Account: "api.test", Channel: "api", Gateway: "main", ID: "42", Event: config.EventMsgUpdate,
+	})
+	sent := discord.messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "1", sent[1].ID)
+	assert.Equal(t, "", sent[1].Event)
+	assert.Equal(t, "newbot", sent[1].Username)
+	assert.Equal(t, "https://avatar/newbot.png", sent[1].Avatar)
+	assert.Equal(t, "hello", sent[1].Text)
+
+	// irc can't show the new username
+	assert.Len(t, irc.messages(), 1)
+
+	// discord resent the message with a new ID (like webhooks do), later events use it
+	r.relayMessage(config.Message{
+		Text: "hello", Username: "newbot", Account: "api.test", Channel: "api", Gateway: "main", ID: "42", Event: config.EventMsgUpdate,
+	})
+	sent = discord.messages()
+	require.Len(t, sent, 3)
+	assert.Equal(t, "2", sent[2].ID)
+	r.relayMessage(config.Message{Text: config.EventMsgDelete, Account: "api.test", Channel: "api", Gateway: "main", ID: "42", Event: config.EventMsgDelete})
+	sent = discord.messages()
+	require.Len(t, sent, 4)
+	assert.Equal(t, "3", sent[3].ID)
+	assert.Equal(t, config.EventMsgDelete, sent[3].Event)
+
+	// updates of unknown messages are dropped
+	r.relayMessage(config.Message{
+		Text: "hello", Username: "newbot", Account: "api.test", Channel: "api", Gateway: "main", ID: "43", Event: config.EventMsgUpdate,
+	})
+	assert.Len(t, discord.messages(), 4)
+	_, ok := gw.Messages.Get("api 43")
+	assert.False(t, ok)
+}
+
+func TestOnlyBridgeReplies(t *testing.T) {
+	r := maketestRouterWithMap(testconfigUpdate, testBridgeMap)
+	gw := r.Gateways["main"]
+	br := gw.Bridges["discord.test"]
+	cfg := br.Config
+	defer func() { br.Config = cfg }()
+	br.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"discord.test.OnlyBridgeReplies": true}}
+	irc := testBridgerOf(gw, "irc.test")
+
+	r.relayMessage(config.Message{Text: "announcement", Username: "bot", Account: "api.test", Channel: "api", Gateway: "main", ID: "42"})
+	require.Len(t, irc.messages(), 1)
+
+	// top-level message
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "discord.test", Channel: "general", ID: "d1"})
+	assert.Len(t, irc.messages(), 1)
+
+	// reply to a message that wasn't bridged
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "discord.test", Channel: "general", ID: "d2", ParentID: "d0"})
+	assert.Len(t, irc.messages(), 1)
+
+	// reply to the bridged announcement, which got ID 1 on discord
+	r.relayMessage(config.Message{Text: "nice", Username: "alice", Account: "discord.test", Channel: "general", ID: "d3", ParentID: "1"})
+	require.Len(t, irc.messages(), 2)
+	assert.Equal(t, "nice", irc.messages()[1].Text)
+
+	// only the bridge with OnlyBridgeReplies is filtered
+	r.relayMessage(config.Message{Text: "hi", Username: "bob", Account: "irc.test", Channel: "#main", ID: "i1"})
+	assert.Len(t, testBridgerOf(gw, "discord.test").messages(), 2)
+}
+
+func TestRedactMessage(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	br := gw.Bridges["irc.freenode"]
+	cfg := br.Config
+	defer func() { br.Config = cfg }()
+
+	redactTests := map[string]struct {
+		text   string
+		redact [][]string
+		output string
+	}{
+		"no redactions": {
+			text:   "my card is 1234-5678-1234-5678",
+			redact: [][]string{},
+			output: "my card is 1234-5678-1234-5678",
+		},
+		"credit card": {
+			text:   "my card is 1234-5678-1234-5678",
+			redact: [][]string{{"[0-9]{4}-?[0-9]{4}-?[0-9]{4}-?[0-9]{4}", "credit card"}},
+			output: "my card is ***",
+		},
+		"multiple matches": {
+			text:   "tokens xoxb-123 and xoxp-456",
+			redact: [][]string{{"xox[abp]-[0-9A-Za-z-]+"}},
+			output: "tokens *** and ***",
+		},
+		"mask is literal": {
+			text:   "secret=abc",
+			redact: [][]string{{"secret=(abc)"}},
+			output: "***",
+		},
+		"invalid regexp": {
+			text:   "secret=abc",
+			redact: [][]string{{"("}, {"abc"}},
+			output: "secret=***",
+		},
+	}
+	for testname, testcase := range redactTests {
+		br.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"irc.freenode.RedactMessages": testcase.redact}}
+		msg := &config.Message{Text: testcase.text, Account: "irc.freenode"}
+		gw.redactMessage(msg)
+		assert.Equalf(t, testcase.output, msg.Text, "case '%s' failed", testname)
+	}
+}
+
+func TestRedactMessageNotLogged(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	br := gw.Bridges["irc.freenode"]
+	cfg := br.Config
+	defer func() { br.Config = cfg }()
+	br.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{
+		"irc.freenode.RedactMessages": [][]string{{"hunter[0-9]", "password"}},
+	}}
+
+	var logs bytes.Buffer
+	gw.logger.Logger.SetOutput(&logs)
+	gw.logger.Logger.SetLevel(logrus.DebugLevel)
+
+	r.relayMessage(config.Message{Text: "my password is hunter2", Username: "user", Account: "irc.freenode", Channel: "#wimtesting"})
+
+	sent := testBridgerOf(gw, "slack.test").messages()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "my password is ***", sent[0].Text)
+	assert.Contains(t, logs.String(), "redacted 1 match(es) of password")
+	assert.NotContains(t, logs.String(), "hunter2")
+}
+
+func TestComputeDestinations(t *testing.T) {
+	channel := func(name, account, direction string, sameChannel bool) *config.ChannelInfo {
+		return &config.ChannelInfo{
+			Name:        name,
+			Account:     account,
+			Direction:   direction,
+			ID:          name + account,
+			SameChannel: map[string]bool{"gw": sameChannel},
+		}
+	}
+	inout := map[string]*config.ChannelInfo{
+		"#mainirc.test":       channel("#main", "irc.test", "inout", false),
+		"generaldiscord.test": channel("general", "discord.test", "inout", false),
+		"mainslack.test":      channel("main", "slack.test", "inout", false),
+	}
+	inOnly := map[string]*config.ChannelInfo{
+		"#mainirc.test":       channel("#main", "irc.test", "in", false),
+		"generaldiscord.test": channel("general", "discord.test", "out", false),
+		"mainslack.test":      channel("main", "slack.test", "inout", false),
+	}
+	multipleOut := map[string]*config.ChannelInfo{
+		"#mainirc.test":       channel("#main", "irc.test", "in", false),
+		"generaldiscord.test": channel("general", "discord.test", "out", false),
+		"randomdiscord.test":  channel("random", "discord.test", "out", false),
+		"archivediscord.test": channel("archive", "discord.test", "in", false),
+	}
+	sameChannel := map[string]*config.ChannelInfo{
+		"mainirc.test":     channel("main", "irc.test", "inout", true),
+		"mainslack.test":   channel("main", "slack.test", "inout", true),
+		"randomslack.test": channel("random", "slack.test", "inout", true),
+	}
+
+	routingTests := map[string]struct {
+		msg      *config.Message
+		channels map[string]*config.ChannelInfo
+		dest     string
+		output   []string
+	}{
+		"inout to inout": {
+			msg:      &config.Message{Channel: "#main", Account: "irc.test", Gateway: "gw"},
+			channels: inout,
+			dest:     "discord.test",
+			output:   []string{"generaldiscord.test"},
+		},
+		"inout to source account": {
+			msg:      &config.Message{Channel: "#main", Account: "irc.test", Gateway: "gw"},
+			channels: inout,
+			dest:     "irc.test",
+			output:   []string{"#mainirc.test"},
+		},
+		"other gateway": {
+			msg:      &config.Message{Channel: "#main", Account: "irc.test", Gateway: "other"},
+			channels: inout,
+			dest:     "discord.test",
+			output:   nil,
+		},
+		"unknown channel": {
+			msg:      &config.Message{Channel: "#other", Account: "irc.test", Gateway: "gw"},
+			channels: inout,
+			dest:     "discord.test",
+			output:   nil,
+		},
+		"in to out": {
+			msg:      &config.Message{Channel: "#main", Account: "irc.test", Gateway: "gw"},
+			channels: inOnly,
+			dest:     "discord.test",
+			output:   []string{"generaldiscord.test"},
+		},
+		"in to inout": {
+			msg:      &config.Message{Channel: "#main", Account: "irc.test", Gateway: "gw"},
+			channels: inOnly,
+			dest:     "slack.test",
+			output:   []string{"mainslack.test"},
+		},
+		"inout to in": {
+			msg:      &config.Message{Channel: "main", Account: "slack.test", Gateway: "gw"},
+			channels: inOnly,
+			dest:     "irc.test",
+			output:   nil,
+		},
+		"out doesn't send": {
+			msg:      &config.Message{Channel: "general", Account: "discord.test", Gateway: "gw"},
+			channels: inOnly,
+			dest:     "slack.test",
+			output:   nil,
+		},
+		"multiple out channels": {
+			msg:      &config.Message{Channel: "#main", Account: "irc.test", Gateway: "gw"},
+			channels: multipleOut,
+			dest:     "discord.test",
+			output:   []string{"generaldiscord.test", "randomdiscord.test"},
+		},
+		"api for this gateway": {
+			msg:      &config.Message{Channel: "#main", Account: "irc.test", Gateway: "gw", Protocol: "api"},
+			channels: inout,
+			dest:     "discord.test",
+			output:   []string{"generaldiscord.test"},
+		},
+		"api for other gateway": {
+			msg:      &config.Message{Channel: "#main", Account: "irc.test", Gateway: "other", Protocol: "api"},
+			channels: inout,
+			dest:     "discord.test",
+			output:   nil,
+		},
+		"discord join/leave": {
+			msg:      &config.Message{Account: "discord.test", Gateway: "gw", Event: config.EventJoinLeave},
+			channels: multipleOut,
+			dest:     "discord.test",
+			output:   []string{"generaldiscord.test", "randomdiscord.test"},
+		},
+		// the source channel is skipped by SendMessage
+		"samechannel": {
+			msg:      &config.Message{Channel: "main", Account: "irc.test", Gateway: "gw"},
+			channels: sameChannel,
+			dest:     "slack.test",
+			output:   []string{"mainirc.test", "mainslack.test"},
+		},
+		"samechannel to source account": {
+			msg:      &config.Message{Channel: "main", Account: "irc.test", Gateway: "gw"},
+			channels: sameChannel,
+			dest:     "irc.test",
+			output:   nil,
+		},
+		"samechannel unknown channel": {
+			msg:      &config.Message{Channel: "random", Account: "irc.test", Gateway: "gw"},
+			channels: sameChannel,
+			dest:     "slack.test",
+			output:   nil,
+		},
+	}
+	for testname, testcase := range routingTests {
+		var output []string
+		for _, channel := range computeDestinations("gw", testcase.msg, testcase.channels, bridge.Bridge{Account: testcase.dest}) {
+			output = append(output, channel.ID)
+		}
+		if testcase.output == nil {
+			assert.Emptyf(t, output, "case '%s' failed", testname)
+			continue
+		}
+		assert.ElementsMatchf(t, testcase.output, output, "case '%s' failed", testname)
+	}
+}
+
+func TestSendMessagePreserveTimestamp(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	slack := gw.Bridges["slack.test"]
+	cfg := slack.Config
+	defer func() { slack.Config = cfg }()
+	slack.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"slack.test.PreserveTimestamp": true}}
+
+	timestamp := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.freenode", Channel: "#wimtesting", Timestamp: timestamp})
+
+	sent := testBridgerOf(gw, "slack.test").messages()
+	require.Len(t, sent, 1)
+	assert.True(t, timestamp.Equal(sent[0].Timestamp))
+
+	sent = testBridgerOf(gw, "discord.test").messages()
+	require.Len(t, sent, 1)
+	assert.True(t, sent[0].Timestamp.After(timestamp))
+
+	// messages without timestamp get the time they were relayed
+	before := time.Now()
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.freenode", Channel: "#wimtesting"})
+	sent = testBridgerOf(gw, "slack.test").messages()
+	require.Len(t, sent, 2)
+	assert.False(t, sent[1].Timestamp.Before(before))
+}
+
+var testconfigEditDisplay = []byte(`
+[slack.test]
+server=""
+[irc.test]
+server=""
+EditDisplay="strike-new"
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+`)
+
+// testNoEditBridger is a testBridger that can't edit messages, it returns no message IDs.
+type testNoEditBridger struct {
+	*testBridger
+}
+
+func (b *testNoEditBridger) Send(msg config.Message) (string, error) {
+	_, err := b.testBridger.Send(msg)
+	return "", err
+}
+
+func TestEditDisplay(t *testing.T) {
+	noedit := &testNoEditBridger{testBridger: &testBridger{}}
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["irc"] = func(cfg *bridge.Config) bridge.Bridger { return noedit }
+
+	r := maketestRouterWithMap(testconfigEditDisplay, bridgeMap)
+	gw := r.Gateways["main"]
+	discord := testBridgerOf(gw, "discord.test")
+
+	for _, text := range []string{"helo", "hello", "hello!"} {
+		r.relayMessage(config.Message{Text: text, Username: "user", Account: "slack.test", Channel: "main", ID: "1"})
+	}
+	r.relayMessage(config.Message{Text: "other", Username: "user", Account: "slack.test", Channel: "main", ID: "2"})
+
+	var texts []string
+	for _, m := range noedit.messages() {
+		texts = append(texts, m.Text)
+	}
+	assert.Equal(t, []string{"helo", "~~helo~~ hello", "~~hello~~ hello!", "other"}, texts)
+
+	// inline shows the new text
+	texts = nil
+	for _, m := range discord.messages() {
+		texts = append(texts, m.Text)
+	}
+	assert.Equal(t, []string{"helo", "hello", "hello!", "other"}, texts)
+	assert.Equal(t, "1", discord.messages()[2].ID)
+}
+
+var testconfigTopicChange = []byte(`
+[slack.test]
+server=""
+ShowTopicChange=true
+[irc.test]
+server=""
+ShowTopicChange=true
+[discord.test]
+server=""
+ShowTopicChange=true
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.out]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.in]]
+    account="discord.test"
+    channel="main"
+`)
+
+func TestModifyTopicChange(t *testing.T) {
+	r := maketestRouterWithMap(testconfigTopicChange, testBridgeMap)
+	gw := r.Gateways["main"]
+	dest := gw.Bridges["irc.test"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+
+	topic := map[string][]interface{}{config.ExtraTopic: {"release day"}}
+	msgTests := map[string]struct {
+		msg       *config.Message
+		overrides map[string]interface{}
+		output    string
+	}{
+		"default format": {
+			msg:    &config.Message{Text: "set the channel topic: release day", Username: "alice", Channel: "main", Event: config.EventTopicChange, Extra: topic},
+			output: "alice changed topic to: release day",
+		},
+		"custom format": {
+			msg:       &config.Message{Text: "set the channel topic: release day", Username: "alice", Channel: "main", Event: config.EventTopicChange, Extra: topic},
+			overrides: map[string]interface{}{"irc.test.TopicChangeFormat": "[{CHANNEL}] topic by {NICK}: {TOPIC}"},
+			output:    "[main] topic by alice: release day",
+		},
+		"unknown topic": {
+			msg:    &config.Message{Text: "set the channel topic: release day", Username: "alice", Channel: "main", Event: config.EventTopicChange},
+			output: "set the channel topic: release day",
+		},
+		"synced topic": {
+			msg:       &config.Message{Text: "set the channel topic: release day", Username: "alice", Channel: "main", Event: config.EventTopicChange, Extra: topic},
+			overrides: map[string]interface{}{"irc.test.SyncTopic": true},
+			output:    "set the channel topic: release day",
+		},
+		"other event": {
+			msg:    &config.Message{Text: "hello", Username: "alice", Channel: "main", Extra: topic},
+			output: "hello",
+		},
+	}
+	for testname, testcase := range msgTests {
+		dest.Config = &config.TestConfig{Config: cfg, Overrides: testcase.overrides}
+		assert.Equalf(t, testcase.output, gw.modifyTopicChange(testcase.msg, dest, testcase.msg.Text), "case '%s' failed", testname)
+	}
+}
+
+func TestRelayTopicChange(t *testing.T) {
+	r := maketestRouterWithMap(testconfigTopicChange, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	r.relayMessage(config.Message{
+		Text: "set the channel topic: release day", Username: "alice", Account: "slack.test", Channel: "main",
+		Event: config.EventTopicChange, Extra: map[string][]interface{}{config.ExtraTopic: {"release day"}},
+	})
+
+	sent := testBridgerOf(gw, "irc.test").messages()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "alice changed topic to: release day", sent[0].Text)
+	// channels without out direction don't get topic changes
+	assert.Empty(t, testBridgerOf(gw, "discord.test").messages())
+
+	// topic changes from channels without in direction aren't relayed
+	r.relayMessage(config.Message{
+		Text: "bob has set the subject to: new", Username: "bob", Account: "irc.test", Channel: "#main",
+		Event: config.EventTopicChange, Extra: map[string][]interface{}{config.ExtraTopic: {"new"}},
+	})
+	assert.Empty(t, testBridgerOf(gw, "slack.test").messages())
+}
+
+func TestModifyMessageFormat(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	dest := gw.Bridges["irc.freenode"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+
+	ts := time.Date(2020, 5, 1, 13, 37, 5, 0, time.UTC)
+	msgTests := map[string]struct {
+		msg    *config.Message
+		format string
+		output string
+	}{
+		"no format": {
+			msg:    &config.Message{Text: "hello", Username: "alice", Channel: "general", Protocol: "discord"},
+			output: "hello",
+		},
+		"all tokens": {
+			msg:    &config.Message{Text: "hello", Username: "alice", Channel: "general", Protocol: "discord", Timestamp: ts},
+			format: "[{GATEWAY}] {TIMESTAMP} {NICK} in {CHANNEL} ({PROTOCOL}): {TEXT}",
+			output: "[bridge1] 13:37:05 alice in general (discord): hello",
+		},
+		"tokens in text": {
+			msg:    &config.Message{Text: "my {NICK}", Username: "alice", Channel: "general"},
+			format: "{NICK}: {TEXT}",
+			output: "alice: my {NICK}",
+		},
+		"user action": {
+			msg:    &config.Message{Text: "waves", Username: "alice", Event: config.EventUserAction},
+			format: "{NICK}: {TEXT}",
+			output: "alice: waves",
+		},
+		"join": {
+			msg:    &config.Message{Text: "alice joins", Username: "system", Event: config.EventJoinLeave},
+			format: "{NICK}: {TEXT}",
+			output: "alice joins",
+		},
+		"empty text": {
+			msg:    &config.Message{Username: "alice"},
+			format: "{NICK}: {TEXT}",
+			output: "",
+		},
+	}
+	for testname, testcase := range msgTests {
+		dest.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"irc.freenode.MessageTextFormat": testcase.format}}
+		assert.Equalf(t, testcase.output, gw.modifyMessageFormat(testcase.msg, dest, testcase.msg.Text), "case '%s' failed", testname)
+	}
+}
+
+var testconfigSelfMessages = []byte(`
+[irc.test]
+server=""
+[discord.test]
+server=""
+IgnoreSelfMessages=true
+BotUserIDs=["700"]
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+`)
+
+func TestIgnoreSelfMessagesWebhookLoop(t *testing.T) {
+	r := maketestRouterWithMap(testconfigSelfMessages, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	discord := testBridgerOf(gw, "discord.test")
+
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#main"})
+	assert.Len(t, discord.messages(), 1)
+
+	// the webhook message comes back from discord as a message of the webhook user
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", UserID: "700", Account: "discord.test", Channel: "main"})
+	assert.Empty(t, irc.messages())
+
+	r.relayMessage(config.Message{Text: "hi", Username: "bob", UserID: "800", Account: "discord.test", Channel: "main"})
+	assert.Len(t, irc.messages(), 1)
+}
+
+func TestSetEnabled(t *testing.T) {
+	r := maketestRouterWithMap(testconfigUpdate, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	assert.True(t, gw.Enabled())
+
+	r.relayMessage(config.Message{Text: "one", Username: "alice", Account: "discord.test", Channel: "general"})
+	assert.Len(t, irc.messages(), 1)
+
+	gw.SetEnabled(false)
+	assert.False(t, gw.Enabled())
+	r.relayMessage(config.Message{Text: "two", Username: "alice", Account: "discord.test", Channel: "general"})
+	assert.Len(t, irc.messages(), 1)
+	assert.Empty(t, gw.Inject(config.Message{Text: "three", Username: "alice", Account: "discord.test", Channel: "general"}))
+	// the bridges stay connected
+	assert.False(t, irc.isDisconnected())
+
+	gw.SetEnabled(true)
+	r.relayMessage(config.Message{Text: "four", Username: "alice", Account: "discord.test", Channel: "general"})
+	sent := irc.messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "four", sent[1].Text)
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,7 +26,8 @@ func (r *Router) handleEventFailure(msg *config.Message) {
 	for _, gw := range r.Gateways {
 		for _, br := range gw.Bridges {
 			if msg.Account == br.Account {
-				go gw.reconnectBridge(br)
+				r.connections.set(br.Account, StateDisconnected, fmt.Errorf("%s", msg.Text))
+				go gw.reconnect(br)
 				return
 			}
 		}
@@ -164,6 +166,13 @@ func (gw *Gateway) handleFilesLocal(fi *config.FileInfo) error {
 	return nil
 }
 
+// identityUpdateProtocols are the protocols that show the new username and avatar
+// of a message when it gets edited.
+var identityUpdateProtocols = map[string]bool{
+	"discord":  true,
+	"telegram": true,
+}
+
 // ignoreEvent returns true if we need to ignore this event for the specified destination bridge.
 func (gw *Gateway) ignoreEvent(event string, dest *bridge.Bridge) bool {
 	switch event {
@@ -173,8 +182,8 @@ func (gw *Gateway) ignoreEvent(event string, dest *bridge.Bridge) bool {
 			return true
 		}
 	case config.EventJoinLeave:
-		// only relay join/part when configured
-		if !dest.GetBool("ShowJoinPart") {
+		// only relay join/part when configured for the bridge or one of its channels
+		if !dest.GetBool("ShowJoinPart") && !gw.channelShowsJoinPart(dest) {
 			return true
 		}
 	case config.EventTopicChange:
@@ -182,6 +191,50 @@ func (gw *Gateway) ignoreEvent(event string, dest *bridge.Bridge) bool {
 		if !dest.GetBool("ShowTopicChange") && !dest.GetBool("SyncTopic") {
 			return true
 		}
+	case config.EventVoiceJoin, config.EventVoiceLeave:
+		// only relay voice activity to bridges that opt in
+		if !dest.GetBool("ShowVoiceActivity") {
+			return true
+		}
+	case config.EventMsgUpdate:
+		// only relay updates to bridges that show the new username/avatar when editing
+		if !identityUpdateProtocols[dest.Protocol] {
+			return true
+		}
+	case config.EventMemberCount:
+		// only relay member counts to bridges that opt in
+		if dest.GetInt("ShowMemberCount") <= 0 {
+			return true
+		}
+	case config.EventReadReceipt:
+		// only relay read receipts to bridges that show them
+		if !showsReadReceipts(dest) {
+			return true
+		}
+	case config.EventReactionAdd, config.EventReactionRemove:
+		// only relay reactions to bridges that support them or when a notice or summary is wanted
+		if !supportsReactions(dest) && !dest.GetBool("ReactionNotice") && dest.GetInt("ReactionSummaryWindow") <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// showJoinPart returns true if join/leave events are sent to channel on dest.
+// The ShowJoinPart option of the channel overrides the one of dest.
+func showJoinPart(dest *bridge.Bridge, channel *config.ChannelInfo) bool {
+	if channel.Options.ShowJoinPart != nil {
+		return *channel.Options.ShowJoinPart
+	}
+	return dest.GetBool("ShowJoinPart")
+}
+
+// channelShowsJoinPart returns true if ShowJoinPart is enabled on a channel of dest.
+func (gw *Gateway) channelShowsJoinPart(dest *bridge.Bridge) bool {
+	for _, channel := range gw.Channels {
+		if channel.Account == dest.Account && channel.Options.ShowJoinPart != nil && *channel.Options.ShowJoinPart {
+			return true
+		}
 	}
 	return false
 }
@@ -191,58 +244,157 @@ func (gw *Gateway) ignoreEvent(event string, dest *bridge.Bridge) bool {
 func (gw *Gateway) handleMessage(rmsg *config.Message, dest *bridge.Bridge) []*BrMsgID {
 	var brMsgIDs []*BrMsgID
 
+	channels, canonicalParentMsgID := gw.routeMessage(rmsg, dest)
+	for idx := range channels {
+		channel := &channels[idx]
+		if gw.throttleJoinLeave(rmsg, dest, channel) {
+			continue
+		}
+		if gw.skipMemberCount(rmsg, dest, channel) {
+			continue
+		}
+		if gw.batchReaction(rmsg, dest, channel) {
+			continue
+		}
+		if gw.batchReadReceipt(rmsg, dest, channel) {
+			continue
+		}
+		if gw.debounceTyping(rmsg, dest, channel) {
+			continue
+		}
+		if gw.queued(dest) {
+			gw.queueMessage(rmsg, dest, channel, canonicalParentMsgID)
+			continue
+		}
+		msgID, err := gw.SendMessage(rmsg, dest, channel, canonicalParentMsgID)
+		if err != nil {
+			gw.logger.Errorf("SendMessage failed: %s", err)
+			gw.handleDeadLetter(rmsg, dest, channel, err)
+			continue
+		}
+		// the text of messages without an ID is still needed to show their edits
+		if msgID == "" && !keepSentText(dest) {
+			continue
+		}
+		brMsgIDs = append(brMsgIDs, &BrMsgID{dest, dest.Protocol + " " + msgID, channel.ID, rmsg.Text, rmsg.Username, gw.now()})
+	}
+	return brMsgIDs
+}
+
+// routeMessage returns the channels on dest that rmsg has to be sent to and the
+// canonical ID of the message it replies to.
+func (gw *Gateway) routeMessage(rmsg *config.Message, dest *bridge.Bridge) ([]config.ChannelInfo, string) {
 	// Not all bridges support "user is typing" indications so skip the message
 	// if the targeted bridge does not support it.
 	if rmsg.Event == config.EventUserTyping {
 		if _, ok := bridgemap.UserTypingSupport[dest.Protocol]; !ok {
-			return nil
+			return nil, ""
 		}
 	}
 
 	// if we have an attached file, or other info
 	if rmsg.Extra != nil && len(rmsg.Extra[config.EventFileFailureSize]) != 0 && rmsg.Text == "" {
-		return brMsgIDs
+		return nil, ""
 	}
 
 	if gw.ignoreEvent(rmsg.Event, dest) {
-		return brMsgIDs
+		return nil, ""
+	}
+
+	if !gw.acceptsLabel(rmsg, dest) {
+		return nil, ""
 	}
 
 	// broadcast to every out channel (irc QUIT)
-	if rmsg.Channel == "" && rmsg.Event != config.EventJoinLeave {
+	if rmsg.Channel == "" && rmsg.Event != config.EventJoinLeave && !isVoiceActivity(rmsg) {
 		gw.logger.Debug("empty channel")
-		return brMsgIDs
+		return nil, ""
 	}
 
 	// Get the ID of the parent message in thread
 	var canonicalParentMsgID string
-	if rmsg.ParentID != "" && dest.GetBool("PreserveThreading") {
+	if rmsg.ParentID != "" && (dest.GetBool("PreserveThreading") || rendersReplies(dest) ||
+		dest.GetString("ThreadAsChannel") != "") {
 		canonicalParentMsgID = gw.FindCanonicalMsgID(rmsg.Protocol, rmsg.ParentID)
 	}
 
-	channels := gw.getDestChannel(rmsg, *dest)
-	for idx := range channels {
-		channel := &channels[idx]
-		msgID, err := gw.SendMessage(rmsg, dest, channel, canonicalParentMsgID)
-		if err != nil {
-			gw.logger.Errorf("SendMessage failed: %s", err)
+	var channels []config.ChannelInfo
+	for _, channel := range gw.getDestChannel(rmsg, *dest) {
+		if rmsg.Event == config.EventJoinLeave && !showJoinPart(dest, &channel) {
+			continue
+		}
+		channels = append(channels, channel)
+	}
+	return channels, canonicalParentMsgID
+}
+
+// handleDeadLetter sends a message that failed to be sent to dest to all out channels of
+// the configured DeadLetterGateway, annotated with the failure in Extra["deadletter"].
+// Dead letters that fail are handled by the DeadLetterGateway of that gateway, but messages
+// which are already a dead letter are dropped so failing DeadLetterGateways can't loop.
+func (gw *Gateway) handleDeadLetter(rmsg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo, sendErr error) {
+	// checked first, a dead letter gateway usually has no DeadLetterGateway itself
+	if rmsg.Extra != nil && len(rmsg.Extra[config.ExtraDeadLetter]) > 0 {
+		gw.logger.Errorf("Sending dead letter to %s (%s) failed, dropping message: %s", dest.Account, channel.Name, sendErr)
+		return
+	}
+	name := gw.MyConfig.DeadLetterGateway
+	if name == "" {
+		return
+	}
+	dlgw, ok := gw.Router.Gateways[name]
+	if !ok {
+		gw.logger.Errorf("DeadLetterGateway %s of gateway %s not found", name, gw.Name)
+		return
+	}
+	msg := *rmsg
+	msg.Extra = make(map[string][]interface{})
+	for k, v := range rmsg.Extra {
+		msg.Extra[k] = v
+	}
+	msg.Extra[config.ExtraDeadLetter] = []interface{}{config.DeadLetter{
+		Gateway: gw.Name,
+		Account: dest.Account,
+		Channel: channel.Name,
+		Error:   sendErr.Error(),
+	}}
+	msg.Gateway = dlgw.Name
+	for _, dlchannel := range dlgw.Channels {
+		if !strings.Contains(dlchannel.Direction, "out") {
 			continue
 		}
-		if msgID == "" {
+		dlbr, ok := dlgw.Bridges[dlchannel.Account]
+		if !ok {
 			continue
 		}
-		brMsgIDs = append(brMsgIDs, &BrMsgID{dest, dest.Protocol + " " + msgID, channel.ID})
+		if _, err := dlgw.SendMessage(&msg, dlbr, dlchannel, ""); err != nil {
+			dlgw.handleDeadLetter(&msg, dlbr, dlchannel, err)
+		}
 	}
-	return brMsgIDs
 }
 
+// handleExtractNicks sets the username of msg to the nick in its text for the
+// ExtractNicks entries of the bridge of msg. An entry is the regexp matching the username
+// of the relay bot, the regexp matching the nick in the text and optionally the index of
+// the group of the nick in that regexp (default 1).
 func (gw *Gateway) handleExtractNicks(msg *config.Message) {
 	var err error
 	br := gw.Bridges[msg.Account]
 	for _, outer := range br.GetStringSlice2D("ExtractNicks") {
+		if len(outer) < 2 {
+			gw.logger.Errorf("ExtractNicks of %s needs a username and a text regexp: %#v", msg.Account, outer)
+			continue
+		}
 		search := outer[0]
 		replace := outer[1]
-		msg.Username, msg.Text, err = extractNick(search, replace, msg.Username, msg.Text)
+		group := 1
+		if len(outer) > 2 {
+			if group, err = strconv.Atoi(outer[2]); err != nil || group < 1 {
+				gw.logger.Errorf("ExtractNicks of %s has an invalid group index: %#v", msg.Account, outer)
+				continue
+			}
+		}
+		msg.Username, msg.Text, err = extractNick(search, replace, group, msg.Username, msg.Text)
 		if err != nil {
 			gw.logger.Errorf("regexp in %s failed: %s", msg.Account, err)
 			break
@@ -250,11 +402,42 @@ func (gw *Gateway) handleExtractNicks(msg *config.Message) {
 	}
 }
 
+// stripInboundNick removes the prefix matching the InboundStripNickFormat of the bridge of
+// msg from the start of its text, like the RemoteNickFormat added by a relay upstream, and
+// makes the nick in it the username of msg. The nick is the group named "nick" or else
+// the first group of the regexp.
+func (gw *Gateway) stripInboundNick(msg *config.Message) {
+	br := gw.Bridges[msg.Account]
+	format := br.GetString("InboundStripNickFormat")
+	if format == "" {
+		return
+	}
+	re, err := gw.regexps.compile(format)
+	if err != nil {
+		gw.logger.Errorf("regexp in %s failed: %s", msg.Account, err)
+		return
+	}
+	group := 1
+	for i, name := range re.SubexpNames() {
+		if name == "nick" {
+			group = i
+			break
+		}
+	}
+	res := re.FindStringSubmatchIndex(msg.Text)
+	// only a prefix is stripped and only if it has a nick
+	if res == nil || res[0] != 0 || group >= len(res)/2 || res[2*group] < 0 || res[2*group] == res[2*group+1] {
+		return
+	}
+	msg.Username = msg.Text[res[2*group]:res[2*group+1]]
+	msg.Text = msg.Text[res[1]:]
+}
+
 // extractNick searches for a username (based on "search" a regular expression).
-// if this matches it extracts a nick (based on "extract" another regular expression) from text
-// and replaces username with this result.
+// if this matches it extracts a nick (group "group" of "extract", another regular expression)
+// from text, replaces username with this result and removes the match from text.
 // returns error if the regexp doesn't compile.
-func extractNick(search, extract, username, text string) (string, string, error) {
+func extractNick(search, extract string, group int, username, text string) (string, string, error) {
 	re, err := regexp.Compile(search)
 	if err != nil {
 		return username, text, err
@@ -264,11 +447,11 @@ func extractNick(search, extract, username, text string) (string, string, error)
 		if err != nil {
 			return username, text, err
 		}
-		res := re.FindAllStringSubmatch(text, 1)
-		// only replace if we have exactly 1 match
-		if len(res) > 0 && len(res[0]) == 2 {
-			username = res[0][1]
-			text = strings.Replace(text, res[0][0], "", 1)
+		res := re.FindStringSubmatch(text)
+		// only replace if the group matched a nick
+		if group < len(res) && res[group] != "" {
+			username = res[group]
+			text = strings.Replace(text, res[0], "", 1)
 		}
 	}
 	return username, text, nil
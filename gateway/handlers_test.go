@@ -1,6 +1,9 @@
 package gateway
 
 import (
+	"bytes"
+	"errors"
+
 	"github.com/42wim/matterbridge/bridge"
 	"github.com/42wim/matterbridge/bridge/config"
 	"github.com/stretchr/testify/assert"
@@ -42,6 +45,7 @@ func TestExtractNick(t *testing.T) {
 	eventTests := map[string]struct {
 		search         string
 		extract        string
+		group          int
 		username       string
 		text           string
 		resultUsername string
@@ -50,6 +54,7 @@ func TestExtractNick(t *testing.T) {
 		"test1": {
 			search:         "fromgitter",
 			extract:        "<(.*?)>\\s+",
+			group:          1,
 			username:       "fromgitter",
 			text:           "<userx> blahblah",
 			resultUsername: "userx",
@@ -59,17 +64,335 @@ func TestExtractNick(t *testing.T) {
 			search: "<.*?bot>",
 			//extract:        `\((.*?)\)\s+`,
 			extract:        "\\((.*?)\\)\\s+",
+			group:          1,
 			username:       "<matterbot>",
 			text:           "(userx) blahblah (abc) test",
 			resultUsername: "userx",
 			resultText:     "blahblah (abc) test",
 		},
+		"group index": {
+			search:         "^relaybot$",
+			extract:        `^\[(irc|discord)\] <([^>]+)>\s+`,
+			group:          2,
+			username:       "relaybot",
+			text:           "[irc] <userx> hello there",
+			resultUsername: "userx",
+			resultText:     "hello there",
+		},
+		"group index out of range": {
+			search:         "^relaybot$",
+			extract:        `^<([^>]+)>\s+`,
+			group:          2,
+			username:       "relaybot",
+			text:           "<userx> hello there",
+			resultUsername: "relaybot",
+			resultText:     "<userx> hello there",
+		},
+		"other username": {
+			search:         "^relaybot$",
+			extract:        `^<([^>]+)>\s+`,
+			group:          1,
+			username:       "alice",
+			text:           "<userx> hello there",
+			resultUsername: "alice",
+			resultText:     "<userx> hello there",
+		},
+		"no match": {
+			search:         "^relaybot$",
+			extract:        `^<([^>]+)>\s+`,
+			group:          1,
+			username:       "relaybot",
+			text:           "hello there",
+			resultUsername: "relaybot",
+			resultText:     "hello there",
+		},
 	}
 	//	gw := &Gateway{}
 	for testname, testcase := range eventTests {
-		resultUsername, resultText, _ := extractNick(testcase.search, testcase.extract, testcase.username, testcase.text)
+		resultUsername, resultText, _ := extractNick(testcase.search, testcase.extract, testcase.group, testcase.username, testcase.text)
 		assert.Equalf(t, testcase.resultUsername, resultUsername, "case '%s' failed", testname)
 		assert.Equalf(t, testcase.resultText, resultText, "case '%s' failed", testname)
 	}
 
 }
+
+var testconfigExtractNicks = []byte(`
+[general]
+RemoteNickFormat="{NICK}: "
+[irc.test]
+server=""
+ExtractNicks=[ ["^relaybot$", "^\\[(\\w+)\\] <([^>]+)>\\s+", "2"], ["^oldbot$", "^\\((.*?)\\)\\s+"] ]
+[slack.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+`)
+
+func TestHandleExtractNicks(t *testing.T) {
+	r := maketestRouterWithMap(testconfigExtractNicks, testBridgeMap)
+	gw := r.Gateways["main"]
+	slack := testBridgerOf(gw, "slack.test")
+
+	r.relayMessage(config.Message{Text: "[discord] <userx> hello there", Username: "relaybot", Account: "irc.test", Channel: "#main"})
+	r.relayMessage(config.Message{Text: "(usery) hi", Username: "oldbot", Account: "irc.test", Channel: "#main"})
+	r.relayMessage(config.Message{Text: "[discord] <userx> not relayed", Username: "alice", Account: "irc.test", Channel: "#main"})
+
+	sent := slack.messages()
+	if assert.Len(t, sent, 3) {
+		assert.Equal(t, "userx: ", sent[0].Username)
+		assert.Equal(t, "hello there", sent[0].Text)
+		assert.Equal(t, "usery: ", sent[1].Username)
+		assert.Equal(t, "hi", sent[1].Text)
+		assert.Equal(t, "alice: ", sent[2].Username)
+		assert.Equal(t, "[discord] <userx> not relayed", sent[2].Text)
+	}
+}
+
+var testconfigInboundStripNick = []byte(`
+[general]
+RemoteNickFormat="{NICK}: "
+[irc.test]
+server=""
+InboundStripNickFormat="\\[\\w+\\] <(?P<nick>[^>]+)> "
+[discord.test]
+server=""
+InboundStripNickFormat="^\\((.*?)\\)\\s+"
+[slack.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+`)
+
+func TestInboundStripNick(t *testing.T) {
+	r := maketestRouterWithMap(testconfigInboundStripNick, testBridgeMap)
+	gw := r.Gateways["main"]
+	slack := testBridgerOf(gw, "slack.test")
+
+	r.relayMessage(config.Message{Text: "[discord] <userx> hello there", Username: "relay", Account: "irc.test", Channel: "#main"})
+	// only a prefix is stripped
+	r.relayMessage(config.Message{Text: "quoting [discord] <userx> hi", Username: "alice", Account: "irc.test", Channel: "#main"})
+	r.relayMessage(config.Message{Text: "(usery) hi", Username: "bridge", Account: "discord.test", Channel: "main"})
+	// an empty nick isn't used
+	r.relayMessage(config.Message{Text: "() hi", Username: "bob", Account: "discord.test", Channel: "main"})
+
+	sent := slack.messages()
+	if assert.Len(t, sent, 4) {
+		assert.Equal(t, "userx: ", sent[0].Username)
+		assert.Equal(t, "hello there", sent[0].Text)
+		assert.Equal(t, "alice: ", sent[1].Username)
+		assert.Equal(t, "quoting [discord] <userx> hi", sent[1].Text)
+		assert.Equal(t, "usery: ", sent[2].Username)
+		assert.Equal(t, "hi", sent[2].Text)
+		assert.Equal(t, "bob: ", sent[3].Username)
+		assert.Equal(t, "() hi", sent[3].Text)
+	}
+}
+
+var testconfigDeadLetter = []byte(`
+[irc.test]
+server=""
+[slack.test]
+server=""
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+deadlettergateway="deadletter"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+[[gateway]]
+name="deadletter"
+enable=true
+deadlettergateway="deadletter"
+
+    [[gateway.out]]
+    account="discord.test"
+    channel="failures"
+`)
+
+func TestHandleDeadLetter(t *testing.T) {
+	r := maketestRouterWithMap(testconfigDeadLetter, testBridgeMap)
+	gw := r.Gateways["main"]
+	dlgw := r.Gateways["deadletter"]
+	slack := testBridgerOf(gw, "slack.test")
+	discord := testBridgerOf(dlgw, "discord.test")
+
+	slack.sendErr = errors.New("slack is down")
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.test", Channel: "#main"})
+
+	assert.Empty(t, slack.messages())
+	sent := discord.messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "hello", sent[0].Text)
+	assert.Equal(t, "failures", sent[0].Channel)
+	assert.Equal(t, []interface{}{config.DeadLetter{
+		Gateway: "main",
+		Account: "slack.test",
+		Channel: "main",
+		Error:   "slack is down",
+	}}, sent[0].Extra[config.ExtraDeadLetter])
+
+	// a failing dead letter gateway doesn't loop
+	discord.sendErr = errors.New("discord is down")
+	r.relayMessage(config.Message{Text: "hello again", Username: "user", Account: "irc.test", Channel: "#main"})
+	assert.Len(t, discord.messages(), 1)
+
+	// successful sends don't end up in the dead letter gateway
+	slack.sendErr = nil
+	r.relayMessage(config.Message{Text: "working", Username: "user", Account: "irc.test", Channel: "#main"})
+	assert.Len(t, slack.messages(), 1)
+	assert.Len(t, discord.messages(), 1)
+}
+
+var testconfigDeadLetterLoop = []byte(`
+[irc.test]
+server=""
+[slack.test]
+server=""
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+deadlettergateway="deadletter"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+[[gateway]]
+name="deadletter"
+enable=true
+deadlettergateway="main"
+
+    [[gateway.out]]
+    account="discord.test"
+    channel="failures"
+`)
+
+// testFailingBridger is a testBridger whose first sends fail.
+type testFailingBridger struct {
+	*testBridger
+
+	failures int
+}
+
+func (b *testFailingBridger) Send(msg config.Message) (string, error) {
+	b.Lock()
+	if b.failures > 0 {
+		b.failures--
+		b.Unlock()
+		return "", errors.New("failed")
+	}
+	b.Unlock()
+	return b.testBridger.Send(msg)
+}
+
+func TestDeadLetterLoop(t *testing.T) {
+	discord := &testFailingBridger{testBridger: &testBridger{}, failures: 1}
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["discord"] = func(cfg *bridge.Config) bridge.Bridger { return discord }
+
+	r := maketestRouterWithMap(testconfigDeadLetterLoop, bridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	slack := testBridgerOf(gw, "slack.test")
+
+	// main -> deadletter fails, the dead letter isn't sent back to main
+	slack.sendErr = errors.New("slack is down")
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.test", Channel: "#main"})
+	assert.Empty(t, irc.messages())
+	assert.Empty(t, discord.messages())
+
+	// the next failure reaches the dead letter gateway
+	r.relayMessage(config.Message{Text: "hello again", Username: "user", Account: "irc.test", Channel: "#main"})
+	assert.Empty(t, irc.messages())
+	sent := discord.messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "hello again", sent[0].Text)
+}
+
+var testconfigDeadLetterFailure = []byte(`
+[irc.test]
+server=""
+[slack.test]
+server=""
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+deadlettergateway="deadletter"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+[[gateway]]
+name="deadletter"
+enable=true
+
+    [[gateway.out]]
+    account="discord.test"
+    channel="failures"
+`)
+
+func TestDeadLetterFailureLogged(t *testing.T) {
+	r := maketestRouterWithMap(testconfigDeadLetterFailure, testBridgeMap)
+	gw := r.Gateways["main"]
+	testBridgerOf(gw, "slack.test").sendErr = errors.New("slack is down")
+	testBridgerOf(r.Gateways["deadletter"], "discord.test").sendErr = errors.New("discord is down")
+
+	var logs bytes.Buffer
+	gw.logger.Logger.SetOutput(&logs)
+
+	// the dead letter gateway has no DeadLetterGateway, the failure is still logged
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.test", Channel: "#main"})
+	assert.Contains(t, logs.String(), "Sending dead letter to discord.test (failures) failed, dropping message: discord is down")
+}
@@ -0,0 +1,57 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// startHealthCheck serves /healthz, /ready and optionally /reconnect on addr.
+func (r *Router) startHealthCheck(addr string) {
+	mux := r.healthCheckMux()
+	r.logger.Infof("Serving health checks on %s", addr)
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			r.logger.Errorf("Health check server on %s failed: %s", addr, err)
+		}
+	}()
+}
+
+// healthCheckMux returns the handler of the HealthCheckAddr server. /reconnect is only
+// served with HealthCheckReconnect and a HealthCheckToken.
+func (r *Router) healthCheckMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", r.handleHealthz)
+	mux.HandleFunc("/ready", r.handleReady)
+	general := r.BridgeValues().General
+	if general.HealthCheckReconnect {
+		if general.HealthCheckToken == "" {
+			r.logger.Warn("HealthCheckReconnect needs a HealthCheckToken, /reconnect is disabled")
+		} else {
+			mux.HandleFunc("/reconnect", r.handleReconnect)
+		}
+	}
+	return mux
+}
+
+// handleHealthz returns 200 when all bridges are connected and 503 otherwise.
+func (r *Router) handleHealthz(w http.ResponseWriter, req *http.Request) {
+	if !r.connections.allConnected() {
+		http.Error(w, "not all bridges are connected", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("OK")) //nolint:errcheck
+}
+
+// handleReady returns the state of every bridge as JSON, with status 200 when all
+// bridges are connected and 503 otherwise.
+func (r *Router) handleReady(w http.ResponseWriter, req *http.Request) {
+	status := http.StatusOK
+	if !r.connections.allConnected() {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(r.connections.all()); err != nil {
+		r.logger.Errorf("Writing health check response failed: %s", err)
+	}
+}
@@ -0,0 +1,76 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthHandlers(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+
+	get := func(handler http.HandlerFunc, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	// no bridges known yet
+	assert.Equal(t, http.StatusServiceUnavailable, get(r.handleHealthz, "/healthz").Code)
+
+	r.connections.set("irc.freenode", StateConnected, nil)
+	r.connections.set("slack.test", StateDisconnected, errors.New("connection reset"))
+	assert.Equal(t, http.StatusServiceUnavailable, get(r.handleHealthz, "/healthz").Code)
+
+	rec := get(r.handleReady, "/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var states []BridgeState
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &states))
+	require.Len(t, states, 2)
+	assert.Equal(t, "irc.freenode", states[0].Account)
+	assert.Equal(t, StateConnected, states[0].State)
+	assert.Equal(t, "", states[0].Error)
+	assert.Equal(t, "slack.test", states[1].Account)
+	assert.Equal(t, StateDisconnected, states[1].State)
+	assert.Equal(t, "connection reset", states[1].Error)
+
+	r.connections.set("slack.test", StateConnected, nil)
+	rec = get(r.handleHealthz, "/healthz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "OK", rec.Body.String())
+	assert.Equal(t, http.StatusOK, get(r.handleReady, "/ready").Code)
+}
+
+func TestConnectionTrackerSince(t *testing.T) {
+	c := newConnectionTracker()
+	c.set("irc.test", StateConnected, nil)
+	first, ok := c.get("irc.test")
+	require.True(t, ok)
+
+	// the same state keeps its start time
+	c.set("irc.test", StateConnected, nil)
+	again, _ := c.get("irc.test")
+	assert.Equal(t, first.Since, again.Since)
+
+	c.set("irc.test", StateDisconnected, errors.New("reconnect"))
+	disconnected, _ := c.get("irc.test")
+	assert.Equal(t, "reconnect", disconnected.Error)
+	assert.False(t, disconnected.Since.Before(first.Since))
+}
+
+func TestRouterStartConnectionStates(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	require.NoError(t, r.Start())
+	states := r.BridgeStates()
+	assert.Len(t, states, 4)
+	for _, s := range states {
+		assert.Equalf(t, StateConnected, s.State, "bridge %s", s.Account)
+	}
+	assert.True(t, r.connections.allConnected())
+}
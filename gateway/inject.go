@@ -0,0 +1,52 @@
+package gateway
+
+import (
+	"sort"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// DeliveryRecord is a message as it would be sent to a destination channel.
+type DeliveryRecord struct {
+	Account   string
+	ChannelID string
+	Message   config.Message
+}
+
+// Inject runs msg through gw as if it was received from msg.Account and returns
+// the messages that would be sent, sorted by channel ID. Nothing is sent, and neither
+// the message cache nor the state used to relay later messages, like the repeats for
+// RepeatSuppressInterval or the joined ThreadAsChannel channels, is changed.
+// JoinLeaveThrottle, batching and ActiveHours aren't applied, every message is
+// returned like it would be sent right away.
+func (gw *Gateway) Inject(msg config.Message) []DeliveryRecord {
+	br, ok := gw.Bridges[msg.Account]
+	if !ok {
+		return nil
+	}
+	msg.Protocol = br.Protocol
+	if gw.ignoreMessage(&msg) {
+		return nil
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	gw.modifyMessage(&msg)
+
+	var records []DeliveryRecord
+	for _, dest := range gw.Bridges {
+		channels, canonicalParentMsgID := gw.routeMessage(&msg, dest)
+		for idx := range channels {
+			channel := &channels[idx]
+			out, ok := gw.prepareMessage(&msg, dest, channel, canonicalParentMsgID)
+			if !ok {
+				continue
+			}
+			out.Username = gw.hideRepeatedNick(&msg, out.Username, dest, channel)
+			records = append(records, DeliveryRecord{Account: dest.Account, ChannelID: channel.ID, Message: out})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ChannelID < records[j].ChannelID })
+	return records
+}
@@ -0,0 +1,103 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigInject = []byte(`
+[irc.test]
+server=""
+IgnoreNicks="spammer"
+RepeatSuppressInterval=60
+[slack.test]
+server=""
+RemoteNickFormat="<{NICK}> "
+[discord.test]
+server=""
+RemoteNickFormat="[{PROTOCOL}] {NICK}: "
+ShowJoinPart=true
+[gitter.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+
+    [[gateway.in]]
+    account="gitter.test"
+    channel="main/room"
+`)
+
+func TestInject(t *testing.T) {
+	r := maketestRouterWithMap(testconfigInject, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	type delivery struct {
+		channelID string
+		username  string
+		text      string
+	}
+	msgTests := map[string]struct {
+		input  config.Message
+		output []delivery
+	}{
+		"message": {
+			input: config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#main", ID: "1"},
+			output: []delivery{
+				{"maindiscord.test", "[irc] alice: ", "hello"},
+				{"mainslack.test", "<alice> ", "hello"},
+			},
+		},
+		"from in channel": {
+			input: config.Message{Text: "hi", Username: "bob", Account: "gitter.test", Channel: "main/room"},
+			output: []delivery{
+				{"#mainirc.test", "", "hi"},
+				{"maindiscord.test", "[gitter] bob: ", "hi"},
+				{"mainslack.test", "<bob> ", "hi"},
+			},
+		},
+		"join": {
+			input:  config.Message{Text: "carol joins", Username: "system", Account: "irc.test", Channel: "#main", Event: config.EventJoinLeave},
+			output: []delivery{{"maindiscord.test", "[irc] system: ", "carol joins"}},
+		},
+		"ignored nick":    {input: config.Message{Text: "buy now", Username: "spammer", Account: "irc.test", Channel: "#main"}},
+		"unknown account": {input: config.Message{Text: "hello", Username: "alice", Account: "irc.other", Channel: "#main"}},
+		"unknown channel": {input: config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#other"}},
+	}
+	for testname, testcase := range msgTests {
+		var deliveries []delivery
+		for _, record := range gw.Inject(testcase.input) {
+			assert.Equalf(t, testcase.input.Account, record.Message.Account, "case '%s' failed", testname)
+			deliveries = append(deliveries, delivery{record.ChannelID, record.Message.Username, record.Message.Text})
+		}
+		assert.Equalf(t, testcase.output, deliveries, "case '%s' failed", testname)
+	}
+
+	// nothing was sent or stored
+	for _, account := range []string{"irc.test", "slack.test", "discord.test"} {
+		assert.Emptyf(t, testBridgerOf(gw, account).messages(), "account %s failed", account)
+	}
+	_, ok := gw.Messages.Get("irc 1")
+	assert.False(t, ok)
+
+	// a message relayed after it was injected is still delivered
+	r.relayMessage(msgTests["message"].input)
+	assert.Len(t, testBridgerOf(gw, "slack.test").messages(), 1)
+	// and suppresses the repeat
+	assert.Empty(t, gw.Inject(msgTests["message"].input))
+}
@@ -0,0 +1,106 @@
+package gateway
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// ircColorNames are the names of the mIRC colors that can be used in NickColor and
+// TextColor besides their number.
+var ircColorNames = map[string]int{
+	"white":      0,
+	"black":      1,
+	"blue":       2,
+	"green":      3,
+	"red":        4,
+	"brown":      5,
+	"purple":     6,
+	"orange":     7,
+	"yellow":     8,
+	"lightgreen": 9,
+	"cyan":       10,
+	"lightcyan":  11,
+	"lightblue":  12,
+	"pink":       13,
+	"grey":       14,
+	"lightgrey":  15,
+}
+
+// ircFormatRE matches the mIRC formatting codes: colors, hex colors, bold, reset,
+// reverse, italic, underline, strikethrough and monospace.
+var ircFormatRE = regexp.MustCompile(`\x03(\d{1,2}(,\d{1,2})?)?|\x04([0-9a-fA-F]{6}(,[0-9a-fA-F]{6})?)?|[\x02\x0f\x16\x1d\x1f\x1e\x11]`)
+
+// ircColorCode returns the mIRC color number of the color name or number value.
+func ircColorCode(value string) (int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if code, ok := ircColorNames[value]; ok {
+		return code, nil
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 0 || code > 98 {
+		return 0, fmt.Errorf("unknown irc color %q", value)
+	}
+	return code, nil
+}
+
+// ircColorFor returns the color of protocol in the [protocol, color] entries of the
+// option key of dest.
+func (gw *Gateway) ircColorFor(dest *bridge.Bridge, key, protocol string) (int, bool) {
+	for _, entry := range dest.GetStringSlice2D(key) {
+		if len(entry) != 2 || !strings.EqualFold(entry[0], protocol) {
+			continue
+		}
+		code, err := ircColorCode(entry[1])
+		if err != nil {
+			gw.logger.Errorf("%s of %s: %s", key, dest.Account, err)
+			return 0, false
+		}
+		return code, true
+	}
+	return 0, false
+}
+
+// ircColorize returns text in the mIRC color code. Every line is colored, as irc sends
+// them as separate messages.
+func ircColorize(text string, code int) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = fmt.Sprintf("\x03%02d%s\x0f", code, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stripIRCFormatting removes the mIRC formatting codes from text.
+func stripIRCFormatting(text string) string {
+	if !strings.ContainsAny(text, "\x02\x03\x04\x0f\x16\x1d\x1f\x1e\x11") {
+		return text
+	}
+	return ircFormatRE.ReplaceAllString(text, "")
+}
+
+// modifyIRCColors colors the nick and text of msg, prepared for dest from rmsg, with the
+// NickColor and TextColor of dest for the protocol of rmsg if dest is irc. The mIRC
+// formatting codes are removed for other destinations, which can't show them.
+func (gw *Gateway) modifyIRCColors(rmsg *config.Message, msg *config.Message, dest *bridge.Bridge) {
+	if dest.Protocol != "irc" {
+		msg.Username = stripIRCFormatting(msg.Username)
+		msg.Text = stripIRCFormatting(msg.Text)
+		return
+	}
+	if rmsg.Event != "" && rmsg.Event != config.EventUserAction {
+		return
+	}
+	if code, ok := gw.ircColorFor(dest, "NickColor", rmsg.Protocol); ok && msg.Username != "" {
+		msg.Username = ircColorize(msg.Username, code)
+	}
+	if code, ok := gw.ircColorFor(dest, "TextColor", rmsg.Protocol); ok && msg.Text != "" {
+		msg.Text = ircColorize(msg.Text, code)
+	}
+}
@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigIRCColor = []byte(`
+[general]
+RemoteNickFormat="<{NICK}> "
+[irc.test]
+server=""
+NickColor=[ ["discord","purple"], ["slack","12"], ["telegram","bogus"] ]
+TextColor=[ ["discord","grey"] ]
+[discord.test]
+server=""
+[slack.test]
+server=""
+[telegram.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="telegram.test"
+    channel="main"
+`)
+
+func TestIRCColors(t *testing.T) {
+	r := maketestRouterWithMap(testconfigIRCColor, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+
+	r.relayMessage(config.Message{Text: "hello\nworld", Username: "alice", Account: "discord.test", Channel: "main"})
+	r.relayMessage(config.Message{Text: "hi", Username: "bob", Account: "slack.test", Channel: "main"})
+	r.relayMessage(config.Message{Text: "hey", Username: "carol", Account: "telegram.test", Channel: "main"})
+
+	sent := irc.messages()
+	require.Len(t, sent, 3)
+	assert.Equal(t, "\x0306<alice> \x0f", sent[0].Username)
+	assert.Equal(t, "\x0314hello\x0f\n\x0314world\x0f", sent[0].Text)
+	assert.Equal(t, "\x0312<bob> \x0f", sent[1].Username)
+	assert.Equal(t, "hi", sent[1].Text)
+	// invalid colors are ignored
+	assert.Equal(t, "<carol> ", sent[2].Username)
+
+	// the formatting of irc messages is removed for other protocols
+	r.relayMessage(config.Message{Text: "\x02bold\x02 \x0304,01red\x03 \x1ditalic\x0f \x04FF0000hex", Username: "\x0312dave\x0f", Account: "irc.test", Channel: "#main"})
+	sent = testBridgerOf(gw, "slack.test").messages()
+	require.Len(t, sent, 3)
+	assert.Equal(t, "bold red italic hex", sent[2].Text)
+	assert.Equal(t, "<dave> ", sent[2].Username)
+}
+
+func TestIRCColorCode(t *testing.T) {
+	for value, want := range map[string]int{"red": 4, "LightBlue": 12, "7": 7, "98": 98} {
+		code, err := ircColorCode(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, want, code, value)
+	}
+	for _, value := range []string{"", "99", "-1", "mauve"} {
+		_, err := ircColorCode(value)
+		assert.Error(t, err, value)
+	}
+}
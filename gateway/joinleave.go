@@ -0,0 +1,134 @@
+package gateway
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// joinLeaveBatch collects the join/leave events for a destination channel with
+// JoinLeaveThrottle enabled until its window elapses.
+type joinLeaveBatch struct {
+	gw      *Gateway
+	msg     config.Message
+	dest    *bridge.Bridge
+	channel *config.ChannelInfo
+	joins   int
+	leaves  int
+	joined  []string // nicks for JoinLeaveDigest
+	left    []string
+	timer   *time.Timer
+}
+
+// isJoin returns true if the text of a join/leave event is about a join, eg
+// "nick joins" or "nick has joined the channel".
+func isJoin(text string) bool {
+	fields := strings.Fields(strings.ToLower(text))
+	// skip the nick
+	if len(fields) > 0 {
+		fields = fields[1:]
+	}
+	for _, field := range fields {
+		if strings.HasPrefix(field, "join") {
+			return true
+		}
+	}
+	return false
+}
+
+// throttleJoinLeave adds the join/leave event rmsg to the batch of channel if
+// JoinLeaveThrottle is set on dest. Returns true if rmsg is batched.
+func (gw *Gateway) throttleJoinLeave(rmsg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) bool {
+	window := dest.GetInt("JoinLeaveThrottle")
+	if rmsg.Event != config.EventJoinLeave || window <= 0 {
+		return false
+	}
+	// don't batch the events of the destination channel itself
+	if channel.ID == getChannelID(rmsg) {
+		return false
+	}
+	b, ok := gw.joinLeaveBatches[channel.ID]
+	if !ok {
+		b = &joinLeaveBatch{gw: gw, dest: dest, channel: channel}
+		b.timer = time.AfterFunc(time.Duration(window)*time.Second, func() {
+			gw.Router.joinLeaveExpired <- b
+		})
+		gw.joinLeaveBatches[channel.ID] = b
+	}
+	b.msg = *rmsg
+	nick := joinLeaveNick(rmsg.Text)
+	if isJoin(rmsg.Text) {
+		b.joins++
+		b.joined = appendNick(b.joined, nick)
+	} else {
+		b.leaves++
+		b.left = appendNick(b.left, nick)
+	}
+	return true
+}
+
+// joinLeaveNick returns the nick of the join/leave event text, eg "nick" of "nick joins".
+func joinLeaveNick(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// appendNick adds nick to nicks if it isn't in it yet.
+func appendNick(nicks []string, nick string) []string {
+	if nick == "" {
+		return nicks
+	}
+	for _, n := range nicks {
+		if n == nick {
+			return nicks
+		}
+	}
+	return append(nicks, nick)
+}
+
+// joinLeaveDigestText returns the digest of the events of b like "Joined: a, b; Left: c".
+func joinLeaveDigestText(b *joinLeaveBatch) string {
+	var parts []string
+	if len(b.joined) > 0 {
+		parts = append(parts, "Joined: "+strings.Join(b.joined, ", "))
+	}
+	if len(b.left) > 0 {
+		parts = append(parts, "Left: "+strings.Join(b.left, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// sendJoinLeave sends the events of b as one summary, or the event itself when
+// there was only one. With JoinLeaveDigest the nicks are listed instead.
+func (gw *Gateway) sendJoinLeave(b *joinLeaveBatch) {
+	if gw.joinLeaveBatches[b.channel.ID] != b {
+		return
+	}
+	delete(gw.joinLeaveBatches, b.channel.ID)
+	b.timer.Stop()
+	msg := b.msg
+	switch {
+	case b.dest.GetBool("JoinLeaveDigest"):
+		if digest := joinLeaveDigestText(b); digest != "" {
+			msg.Text = digest
+		}
+	case b.joins+b.leaves > 1:
+		msg.Text = fmt.Sprintf("+%d/-%d users", b.joins, b.leaves)
+	}
+	if _, err := gw.SendMessage(&msg, b.dest, b.channel, ""); err != nil {
+		gw.logger.Errorf("SendMessage failed: %s", err)
+	}
+}
+
+// flushJoinLeave sends all batched join/leave events of gw.
+func (gw *Gateway) flushJoinLeave() {
+	for _, b := range gw.joinLeaveBatches {
+		gw.sendJoinLeave(b)
+	}
+}
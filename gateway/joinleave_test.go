@@ -0,0 +1,185 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigJoinLeave = []byte(`
+[irc.test]
+server=""
+[slack.test]
+server=""
+ShowJoinPart=true
+JoinLeaveThrottle=60
+[discord.test]
+server=""
+ShowJoinPart=true
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+`)
+
+func TestIsJoin(t *testing.T) {
+	for text, join := range map[string]bool{
+		"nick joins":                        true,
+		"nick (ident@host) joins":           true,
+		"nick has joined the channel":       true,
+		"nick parts":                        false,
+		"nick quits":                        false,
+		"nick leaves":                       false,
+		"joiner leaves":                     false,
+		"nick has left the channel":         false,
+		"":                                  false,
+		"nick (joining@host) quits":         false,
+		"nick was added to the channel":     false,
+		"nick joined the channel by invite": true,
+	} {
+		assert.Equalf(t, join, isJoin(text), "case '%s' failed", text)
+	}
+}
+
+func TestJoinLeaveThrottle(t *testing.T) {
+	r := maketestRouterWithMap(testconfigJoinLeave, testBridgeMap)
+	gw := r.Gateways["main"]
+	slack := testBridgerOf(gw, "slack.test")
+	discord := testBridgerOf(gw, "discord.test")
+
+	events := []string{"a joins", "b joins", "c parts", "d joins", "e quits"}
+	for _, text := range events {
+		r.relayMessage(config.Message{Text: text, Username: "system", Account: "irc.test", Channel: "#main", Event: config.EventJoinLeave})
+	}
+
+	// destinations without JoinLeaveThrottle get every event
+	assert.Len(t, discord.messages(), len(events))
+	assert.Empty(t, slack.messages())
+	assert.Len(t, gw.joinLeaveBatches, 1)
+
+	gw.flushJoinLeave()
+	sent := slack.messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "+3/-2 users", sent[0].Text)
+	assert.Equal(t, config.EventJoinLeave, sent[0].Event)
+	assert.Empty(t, gw.joinLeaveBatches)
+
+	// a single event in the window is sent as is
+	r.relayMessage(config.Message{Text: "f joins", Username: "system", Account: "irc.test", Channel: "#main", Event: config.EventJoinLeave})
+	for _, b := range gw.joinLeaveBatches {
+		gw.sendJoinLeave(b)
+	}
+	sent = slack.messages()
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "f joins", sent[1].Text)
+
+	// normal messages aren't delayed
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.test", Channel: "#main"})
+	assert.Len(t, slack.messages(), 3)
+}
+
+var testconfigJoinLeaveChannels = []byte(`
+[irc.test]
+server=""
+[slack.test]
+server=""
+ShowJoinPart=true
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="shown"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="hidden"
+        [gateway.inout.options]
+        showjoinpart=false
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="shown"
+        [gateway.inout.options]
+        showjoinpart=true
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="hidden"
+`)
+
+func TestShowJoinPartChannelOption(t *testing.T) {
+	r := maketestRouterWithMap(testconfigJoinLeaveChannels, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	r.relayMessage(config.Message{Text: "a joins", Username: "system", Account: "irc.test", Channel: "#main", Event: config.EventJoinLeave})
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.test", Channel: "#main"})
+
+	for _, account := range []string{"slack.test", "discord.test"} {
+		var texts []string
+		for _, msg := range testBridgerOf(gw, account).messages() {
+			texts = append(texts, msg.Channel+": "+msg.Text)
+		}
+		assert.ElementsMatchf(t, []string{"shown: a joins", "shown: hello", "hidden: hello"}, texts, "account %s failed", account)
+	}
+}
+
+func TestJoinLeaveDigest(t *testing.T) {
+	r := maketestRouterWithMap(testconfigJoinLeave, testBridgeMap)
+	gw := r.Gateways["main"]
+	slack := gw.Bridges["slack.test"]
+	slackCfg := slack.Config
+	defer func() { slack.Config = slackCfg }()
+	slack.Config = &config.TestConfig{Config: slackCfg, Overrides: map[string]interface{}{
+		"slack.test.JoinLeaveDigest":   true,
+		"slack.test.JoinLeaveThrottle": 1,
+	}}
+
+	start := time.Now()
+	for _, text := range []string{"a joins", "b (ident@host) joins", "c parts", "a quits", "b joins"} {
+		r.relayMessage(config.Message{Text: text, Username: "system", Account: "irc.test", Channel: "#main", Event: config.EventJoinLeave})
+	}
+	assert.Empty(t, testBridgerOf(gw, "slack.test").messages())
+
+	// the digest is sent when the interval elapsed
+	select {
+	case b := <-r.joinLeaveExpired:
+		assert.True(t, time.Since(start) >= time.Second, "digest sent before the interval")
+		gw.sendJoinLeave(b)
+	case <-time.After(2 * time.Second):
+		t.Fatal("digest never sent")
+	}
+	sent := testBridgerOf(gw, "slack.test").messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "Joined: a, b; Left: c, a", sent[0].Text)
+	assert.Equal(t, config.EventJoinLeave, sent[0].Event)
+
+	// the next events start a new digest, a single event is a digest too
+	r.relayMessage(config.Message{Text: "d leaves", Username: "system", Account: "irc.test", Channel: "#main", Event: config.EventJoinLeave})
+	gw.flushJoinLeave()
+	sent = testBridgerOf(gw, "slack.test").messages()
+	assert.Len(t, sent, 2)
+	assert.Equal(t, "Left: d", sent[1].Text)
+}
@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"strings"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// handleKeywordRoutes sends msg, which has been relayed by gateways, also to the out
+// channels of the gateways of the KeywordRoutes it matches. Channels that already
+// received msg by the normal routing are skipped.
+func (r *Router) handleKeywordRoutes(msg *config.Message, gateways []*Gateway) {
+	if msg.Text == "" || (msg.Event != "" && msg.Event != config.EventUserAction) {
+		return
+	}
+	var delivered map[string]bool
+	for _, gw := range gateways {
+		for _, route := range gw.MyConfig.KeywordRoutes {
+			if len(route) != 2 {
+				gw.logger.Errorf("KeywordRoutes of gateway %s needs a regexp and a gateway: %#v", gw.Name, route)
+				continue
+			}
+			re, err := gw.regexps.compile(route[0])
+			if err != nil {
+				gw.logger.Errorf("regexp in KeywordRoutes of gateway %s failed: %s", gw.Name, err)
+				continue
+			}
+			if !re.MatchString(msg.Text) {
+				continue
+			}
+			target, ok := r.Gateways[route[1]]
+			if !ok || target.isClosed() {
+				gw.logger.Errorf("KeywordRoutes gateway %s of gateway %s not found", route[1], gw.Name)
+				continue
+			}
+			if delivered == nil {
+				delivered = normalDestinations(*msg, gateways)
+			}
+			gw.logger.Debugf("message from %s matches %s, sending to gateway %s", msg.Account, route[0], target.Name)
+			target.sendKeywordRoute(msg, delivered)
+		}
+	}
+}
+
+// normalDestinations returns the IDs of the channels msg has been sent to by gateways.
+func normalDestinations(msg config.Message, gateways []*Gateway) map[string]bool {
+	dests := map[string]bool{getChannelID(&msg): true}
+	for _, gw := range gateways {
+		// modifyMessage sets the gateway, except for messages from the api
+		if msg.Protocol != apiProtocol {
+			msg.Gateway = gw.Name
+		}
+		for _, br := range gw.Bridges {
+			for _, channel := range gw.getDestChannel(&msg, *br) {
+				dests[channel.ID] = true
+			}
+		}
+	}
+	return dests
+}
+
+// sendKeywordRoute sends msg to all out channels of gw that aren't in delivered yet.
+func (gw *Gateway) sendKeywordRoute(rmsg *config.Message, delivered map[string]bool) {
+	msg := *rmsg
+	msg.Gateway = gw.Name
+	for _, channel := range gw.Channels {
+		if !strings.Contains(channel.Direction, "out") || delivered[channel.ID] {
+			continue
+		}
+		br, ok := gw.Bridges[channel.Account]
+		if !ok {
+			continue
+		}
+		delivered[channel.ID] = true
+		if _, err := gw.SendMessage(&msg, br, channel, ""); err != nil {
+			gw.logger.Errorf("Sending keyword routed message to %s (%s) failed: %s", br.Account, channel.Name, err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigKeywordRoutes = []byte(`
+[irc.test]
+server=""
+[slack.test]
+server=""
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+keywordroutes=[ ["(?i)outage", "alerts"], ["deploy", "unknown"] ]
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+[[gateway]]
+name="alerts"
+enable=true
+
+    [[gateway.out]]
+    account="discord.test"
+    channel="alerts"
+
+    [[gateway.out]]
+    account="slack.test"
+    channel="main"
+`)
+
+func TestHandleKeywordRoutes(t *testing.T) {
+	r := maketestRouterWithMap(testconfigKeywordRoutes, testBridgeMap)
+	gw := r.Gateways["main"]
+	require.Equal(t, [][]string{{"(?i)outage", "alerts"}, {"deploy", "unknown"}}, gw.MyConfig.KeywordRoutes)
+	slack := testBridgerOf(gw, "slack.test")
+	discord := testBridgerOf(r.Gateways["alerts"], "discord.test")
+
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.test", Channel: "#main"})
+	assert.Len(t, slack.messages(), 1)
+	assert.Empty(t, discord.messages())
+
+	r.relayMessage(config.Message{Text: "Outage in progress", Username: "user", Account: "irc.test", Channel: "#main"})
+	sent := discord.messages()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "Outage in progress", sent[0].Text)
+	assert.Equal(t, "alerts", sent[0].Channel)
+	// slack main is a normal destination and doesn't get the message twice
+	assert.Len(t, slack.messages(), 2)
+
+	// events aren't routed
+	r.relayMessage(config.Message{Text: "outage joins", Username: "outage", Account: "irc.test", Channel: "#main", Event: config.EventJoinLeave})
+	assert.Len(t, discord.messages(), 1)
+
+	// unknown gateways are ignored
+	r.relayMessage(config.Message{Text: "deploy done", Username: "user", Account: "irc.test", Channel: "#main"})
+	assert.Len(t, discord.messages(), 1)
+	assert.Len(t, slack.messages(), 3)
+}
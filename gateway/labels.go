@@ -0,0 +1,43 @@
+package gateway
+
+import (
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// acceptsLabel returns true if dest accepts msg based on the Label of the bridge msg comes
+// from, and the OnlyFromLabels and ExcludeLabels of dest.
+func (gw *Gateway) acceptsLabel(msg *config.Message, dest *bridge.Bridge) bool {
+	only := dest.GetStringSlice("OnlyFromLabels")
+	exclude := dest.GetStringSlice("ExcludeLabels")
+	if len(only) == 0 && len(exclude) == 0 {
+		return true
+	}
+	label := ""
+	// use the router to find the bridge, messages can come from other gateways (see DeadLetterGateway)
+	if br := gw.Router.getBridge(msg.Account); br != nil {
+		label = br.GetString("Label")
+	}
+	if len(only) > 0 && !containsLabel(only, label) {
+		gw.logger.Debugf("not sending message from %s with label %#v to %s, not in OnlyFromLabels", msg.Account, label, dest.Account)
+		return false
+	}
+	if containsLabel(exclude, label) {
+		gw.logger.Debugf("not sending message from %s with label %#v to %s, in ExcludeLabels", msg.Account, label, dest.Account)
+		return false
+	}
+	return true
+}
+
+// containsLabel returns true if label is one of labels. Bridges without label never match.
+func containsLabel(labels []string, label string) bool {
+	if label == "" {
+		return false
+	}
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigLabels = []byte(`
+[irc.internal]
+server=""
+Label="internal"
+[irc.partner]
+server=""
+Label="partner"
+[irc.nolabel]
+server=""
+[slack.hub]
+server=""
+ExcludeLabels=["partner"]
+[discord.internal]
+server=""
+OnlyFromLabels=["internal"]
+[gitter.all]
+server=""
+
+[[gateway]]
+name="hub"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.internal"
+    channel="#internal"
+
+    [[gateway.inout]]
+    account="irc.partner"
+    channel="#partner"
+
+    [[gateway.inout]]
+    account="irc.nolabel"
+    channel="#nolabel"
+
+    [[gateway.inout]]
+    account="slack.hub"
+    channel="hub"
+
+    [[gateway.inout]]
+    account="discord.internal"
+    channel="internal"
+
+    [[gateway.inout]]
+    account="gitter.all"
+    channel="all/room"
+`)
+
+func TestLabelFiltering(t *testing.T) {
+	r := maketestRouterWithMap(testconfigLabels, testBridgeMap)
+	gw := r.Gateways["hub"]
+
+	msgTests := map[string]struct {
+		account  string
+		channel  string
+		accounts []string
+	}{
+		"internal": {
+			account:  "irc.internal",
+			channel:  "#internal",
+			accounts: []string{"discord.internal", "gitter.all", "irc.nolabel", "irc.partner", "slack.hub"},
+		},
+		"partner": {
+			account:  "irc.partner",
+			channel:  "#partner",
+			accounts: []string{"gitter.all", "irc.internal", "irc.nolabel"},
+		},
+		"no label": {
+			account:  "irc.nolabel",
+			channel:  "#nolabel",
+			accounts: []string{"gitter.all", "irc.internal", "irc.partner", "slack.hub"},
+		},
+	}
+	for testname, testcase := range msgTests {
+		var accounts []string
+		for _, record := range gw.Inject(config.Message{Text: "hello", Username: "alice", Account: testcase.account, Channel: testcase.channel}) {
+			accounts = append(accounts, record.Account)
+		}
+		assert.ElementsMatchf(t, testcase.accounts, accounts, "case '%s' failed", testname)
+	}
+}
@@ -0,0 +1,86 @@
+package gateway
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// LanguageDetector detects the language of the text of the messages received by a
+// gateway, eg "en". An empty language means it's unknown.
+type LanguageDetector interface {
+	DetectLanguage(text string) (string, error)
+}
+
+// LanguageDetectorFunc is an adapter to allow the use of ordinary functions as a LanguageDetector.
+type LanguageDetectorFunc func(text string) (string, error)
+
+// DetectLanguage calls f(text).
+func (f LanguageDetectorFunc) DetectLanguage(text string) (string, error) {
+	return f(text)
+}
+
+type languageDetector struct {
+	sync.RWMutex
+	detector LanguageDetector
+}
+
+// SetLanguageDetector sets the LanguageDetector of gw, which is used for OnlyLanguages.
+// Without one the language of messages isn't detected.
+func (gw *Gateway) SetLanguageDetector(d LanguageDetector) {
+	gw.languageDetector.Lock()
+	defer gw.languageDetector.Unlock()
+	gw.languageDetector.detector = d
+}
+
+// detectLanguage sets Extra["lang"] of msg to the language of its text.
+func (gw *Gateway) detectLanguage(msg *config.Message) {
+	gw.languageDetector.RLock()
+	d := gw.languageDetector.detector
+	gw.languageDetector.RUnlock()
+	if d == nil || msg.Text == "" || msg.Event != "" && msg.Event != config.EventUserAction {
+		return
+	}
+	lang, err := d.DetectLanguage(msg.Text)
+	if err != nil {
+		gw.logger.Errorf("detecting the language of a message from %s failed: %s", msg.Account, err)
+		return
+	}
+	if lang == "" {
+		return
+	}
+	// Extra can be shared with the message of other gateways
+	extra := make(map[string][]interface{}, len(msg.Extra)+1)
+	for k, v := range msg.Extra {
+		extra[k] = v
+	}
+	extra[config.ExtraLang] = []interface{}{lang}
+	msg.Extra = extra
+}
+
+// messageLanguage returns the language detected for msg, or an empty string.
+func messageLanguage(msg *config.Message) string {
+	if msg.Extra == nil || len(msg.Extra[config.ExtraLang]) == 0 {
+		return ""
+	}
+	lang, _ := msg.Extra[config.ExtraLang][0].(string)
+	return lang
+}
+
+// acceptsLanguage returns false if dest has OnlyLanguages and the language detected for
+// msg isn't one of them. Messages with an unknown language and events are accepted.
+func acceptsLanguage(msg *config.Message, dest *bridge.Bridge) bool {
+	languages := dest.GetStringSlice("OnlyLanguages")
+	lang := messageLanguage(msg)
+	if len(languages) == 0 || lang == "" {
+		return true
+	}
+	for _, l := range languages {
+		if strings.EqualFold(l, lang) {
+			return true
+		}
+	}
+	return false
+}
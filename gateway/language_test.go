@@ -0,0 +1,89 @@
+package gateway
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigLanguage = []byte(`
+[irc.test]
+server=""
+[slack.german]
+server=""
+OnlyLanguages=["de"]
+[slack.dutch]
+server=""
+OnlyLanguages=["DE", "nl"]
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.german"
+    channel="de"
+
+    [[gateway.inout]]
+    account="slack.dutch"
+    channel="nl"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+`)
+
+// stubLanguageDetector detects "de" and "nl" by the first word of the text.
+func stubLanguageDetector(text string) (string, error) {
+	switch {
+	case strings.HasPrefix(text, "hallo"):
+		return "de", nil
+	case strings.HasPrefix(text, "hoi"):
+		return "nl", nil
+	case strings.HasPrefix(text, "fail"):
+		return "", errors.New("detection failed")
+	case strings.HasPrefix(text, "hello"):
+		return "en", nil
+	}
+	return "", nil
+}
+
+func TestOnlyLanguages(t *testing.T) {
+	r := maketestRouterWithMap(testconfigLanguage, testBridgeMap)
+	gw := r.Gateways["main"]
+	texts := func(account string) []string {
+		var texts []string
+		for _, msg := range testBridgerOf(gw, account).messages() {
+			texts = append(texts, msg.Text)
+		}
+		return texts
+	}
+
+	// without a detector all messages are sent
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#main"})
+	assert.Equal(t, []string{"hello"}, texts("slack.german"))
+
+	gw.SetLanguageDetector(LanguageDetectorFunc(stubLanguageDetector))
+	for _, text := range []string{"hallo", "hoi", "hello again", "ok", "fail"} {
+		r.relayMessage(config.Message{Text: text, Username: "alice", Account: "irc.test", Channel: "#main"})
+	}
+
+	// messages of which the language is unknown are sent
+	assert.Equal(t, []string{"hello", "hallo", "ok", "fail"}, texts("slack.german"))
+	assert.Equal(t, []string{"hello", "hallo", "hoi", "ok", "fail"}, texts("slack.dutch"))
+	assert.Equal(t, []string{"hello", "hallo", "hoi", "hello again", "ok", "fail"}, texts("discord.test"))
+
+	// the detected language is in Extra
+	sent := testBridgerOf(gw, "discord.test").messages()
+	assert.Equal(t, "de", messageLanguage(&sent[1]))
+	assert.Equal(t, "", messageLanguage(&sent[4]))
+}
@@ -0,0 +1,92 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// loopHistorySize is the maximum number of messages remembered per channel for
+// loop detection.
+const loopHistorySize = 100
+
+// defaultLoopDetectionThreshold is the LoopDetectionThreshold if it isn't set, a user
+// can repeat a message once.
+const defaultLoopDetectionThreshold = 2
+
+// loopEntry is a message received on a channel, remembered for loop detection.
+type loopEntry struct {
+	hash [sha256.Size]byte
+	at   time.Time
+}
+
+// loopDetector remembers the hashes of the messages received per channel, to detect
+// messages that come back to the channel they were sent to, eg when a channel is
+// bridged to itself through two gateways.
+type loopDetector struct {
+	history map[string][]loopEntry
+	now     func() time.Time
+}
+
+func newLoopDetector() *loopDetector {
+	return &loopDetector{history: make(map[string][]loopEntry), now: time.Now}
+}
+
+// seen records the message key as received on channel and returns the number of times
+// it was received on channel before within window.
+func (d *loopDetector) seen(channel, key string, window time.Duration) int {
+	now := d.now()
+	hash := sha256.Sum256([]byte(key))
+	var entries []loopEntry
+	count := 0
+	for _, e := range d.history[channel] {
+		if now.Sub(e.at) > window {
+			continue
+		}
+		if e.hash == hash {
+			count++
+		}
+		entries = append(entries, e)
+	}
+	entries = append(entries, loopEntry{hash: hash, at: now})
+	if len(entries) > loopHistorySize {
+		entries = entries[len(entries)-loopHistorySize:]
+	}
+	d.history[channel] = entries
+	return count
+}
+
+// isLoop returns true if the text of msg was received from the same user on its channel
+// more than LoopDetectionThreshold times within LoopDetectionWindow, which probably means
+// that the message is looping.
+func (r *Router) isLoop(msg *config.Message) bool {
+	general := r.BridgeValues().General
+	if general.LoopDetectionWindow <= 0 || msg.Text == "" {
+		return false
+	}
+	// only actual messages, not events like joins or deletes
+	if msg.Event != "" && msg.Event != config.EventUserAction {
+		return false
+	}
+	// edits of a message can have the same text
+	if msg.ID != "" {
+		for _, gw := range r.Gateways {
+			if _, ok := gw.Messages.Get(msg.Protocol + " " + msg.ID); ok {
+				return false
+			}
+		}
+	}
+	threshold := general.LoopDetectionThreshold
+	if threshold <= 0 {
+		threshold = defaultLoopDetectionThreshold
+	}
+	window := time.Duration(general.LoopDetectionWindow) * time.Second
+	count := r.loops.seen(getChannelID(msg), msg.Username+"\n"+msg.Text, window)
+	if count < threshold {
+		return false
+	}
+	r.logger.Warnf("Dropping message from %s on %s %s: the same message was received %d times in the last %s, probably a loop between gateways",
+		msg.Username, msg.Account, msg.Channel, count, window)
+	return true
+}
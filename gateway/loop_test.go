@@ -0,0 +1,160 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigLoop = []byte(`
+[general]
+LoopDetectionWindow=10
+[irc.test]
+server=""
+RemoteNickFormat="{NICK}"
+[slack.test]
+server=""
+RemoteNickFormat="{NICK}"
+
+[[gateway]]
+name="one"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+[[gateway]]
+name="two"
+enable=true
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+`)
+
+func TestLoopDetector(t *testing.T) {
+	d := newLoopDetector()
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	assert.Equal(t, 0, d.seen("chan", "hello", time.Minute))
+	assert.Equal(t, 1, d.seen("chan", "hello", time.Minute))
+	assert.Equal(t, 0, d.seen("other", "hello", time.Minute))
+	assert.Equal(t, 0, d.seen("chan", "bye", time.Minute))
+
+	now = now.Add(2 * time.Minute)
+	assert.Equal(t, 0, d.seen("chan", "hello", time.Minute))
+
+	for i := 0; i < loopHistorySize*2; i++ {
+		d.seen("chan", "spam", time.Minute)
+	}
+	assert.Len(t, d.history["chan"], loopHistorySize)
+}
+
+func TestRelayLoop(t *testing.T) {
+	r := maketestRouterWithMap(testconfigLoop, testBridgeMap)
+	gw := r.Gateways["one"]
+	irc := testBridgerOf(gw, "irc.test")
+	now := time.Now()
+	r.loops.now = func() time.Time { return now }
+
+	// the copies sent by the gateways come back as new messages
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.test", Channel: "#main", ID: "1"})
+	for _, ID := range []string{"2", "3", "4"} {
+		r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "slack.test", Channel: "main", ID: ID})
+	}
+	// received twice on slack, the third one is dropped
+	// both gateways send the messages to irc
+	assert.Len(t, irc.messages(), 4)
+
+	// an edit with the same text isn't a loop
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "slack.test", Channel: "main", ID: "2"})
+	assert.Len(t, irc.messages(), 6)
+
+	// events aren't checked
+	for i := 0; i < 3; i++ {
+		r.relayMessage(config.Message{Text: "user joins", Username: "system", Account: "slack.test", Channel: "main", Event: config.EventJoinLeave})
+	}
+
+	// different users sending the same text isn't a loop
+	for _, user := range []string{"alice", "bob", "carol"} {
+		r.relayMessage(config.Message{Text: "+1", Username: user, Account: "slack.test", Channel: "main"})
+	}
+	assert.Len(t, irc.messages(), 12)
+
+	// the window elapsed
+	now = now.Add(11 * time.Second)
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "slack.test", Channel: "main", ID: "5"})
+	assert.Len(t, irc.messages(), 14)
+}
+
+// testEchoBridger is a testBridger that receives every message it sends again, like a
+// channel that is bridged to itself through another gateway.
+type testEchoBridger struct {
+	*testBridger
+
+	account string
+	echoes  []config.Message
+}
+
+func (b *testEchoBridger) Send(msg config.Message) (string, error) {
+	b.Lock()
+	b.echoes = append(b.echoes, config.Message{Text: msg.Text, Username: msg.Username, Account: b.account, Channel: msg.Channel})
+	b.Unlock()
+	return b.testBridger.Send(msg)
+}
+
+// takeEchoes returns and clears the messages received since the last call.
+func (b *testEchoBridger) takeEchoes() []config.Message {
+	b.Lock()
+	defer b.Unlock()
+	echoes := b.echoes
+	b.echoes = nil
+	return echoes
+}
+
+func TestRelayLoopEcho(t *testing.T) {
+	var bridgers []*testEchoBridger
+	newEchoBridger := func(cfg *bridge.Config) bridge.Bridger {
+		b := &testEchoBridger{testBridger: &testBridger{}, account: cfg.Account}
+		bridgers = append(bridgers, b)
+		return b
+	}
+	r := maketestRouterWithMap(testconfigLoop, map[string]bridge.Factory{"irc": newEchoBridger, "slack": newEchoBridger})
+
+	// irc -> slack -> irc through both gateways until the loop is detected
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.test", Channel: "#main"})
+	rounds := 0
+	for ; rounds < 10; rounds++ {
+		var echoes []config.Message
+		for _, b := range bridgers {
+			echoes = append(echoes, b.takeEchoes()...)
+		}
+		if len(echoes) == 0 {
+			break
+		}
+		for _, msg := range echoes {
+			r.relayMessage(msg)
+		}
+	}
+	assert.True(t, rounds < 10, "the message kept looping")
+
+	sent := 0
+	for _, b := range bridgers {
+		sent += len(b.messages())
+	}
+	// 2 copies to slack, both echo back to irc twice, only the first of these reaches slack again
+	assert.Equal(t, 8, sent)
+}
@@ -0,0 +1,76 @@
+package gateway
+
+import (
+	"strings"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// maintenanceResumed is the text sent when an announced maintenance ends.
+const maintenanceResumed = "Maintenance finished, bridging resumed."
+
+// maintenance is the maintenance mode of a gateway, see SetMaintenance.
+type maintenance struct {
+	enabled   bool
+	announced bool
+}
+
+// SetMaintenance enables or disables the maintenance mode of gw. In maintenance mode gw
+// doesn't forward any messages. If announce isn't empty it is sent once to all the out
+// channels of gw when enabling, and a resumed message is sent when disabling again.
+func (gw *Gateway) SetMaintenance(enabled bool, announce string) {
+	gw.maintenanceLock.Lock()
+	defer gw.maintenanceLock.Unlock()
+	if gw.maintenance.enabled == enabled {
+		return
+	}
+	if enabled {
+		if announce != "" {
+			gw.announce(announce)
+		}
+		gw.maintenance = maintenance{enabled: true, announced: announce != ""}
+		return
+	}
+	announced := gw.maintenance.announced
+	gw.maintenance = maintenance{}
+	if announced {
+		gw.announce(maintenanceResumed)
+	}
+}
+
+// InMaintenance returns true if gw is in maintenance mode, see SetMaintenance.
+func (gw *Gateway) InMaintenance() bool {
+	gw.maintenanceLock.RLock()
+	defer gw.maintenanceLock.RUnlock()
+	return gw.maintenance.enabled
+}
+
+// announce sends text as a message from the BroadcastNick to all the out channels of gw.
+func (gw *Gateway) announce(text string) {
+	gw.broadcasts.Add(text, gw.now())
+	for _, channel := range gw.ChannelSnapshot() {
+		if !strings.Contains(channel.Direction, "out") {
+			continue
+		}
+		dest := gw.Bridges[channel.Account]
+		if dest == nil || dest.Bridger == nil {
+			continue
+		}
+		msg := config.Message{
+			Text:     text,
+			Username: gw.broadcastNick(),
+			Channel:  channel.Name,
+			Account:  dest.Account,
+			Gateway:  gw.Name,
+		}
+		var err error
+		if dest.Protocol == webhookProtocol {
+			_, err = gw.sendWebhook(msg, dest)
+		} else {
+			_, err = gw.send(dest, msg)
+		}
+		if err != nil {
+			gw.logger.Errorf("sending announcement to %s failed: %s", dest.Account, err)
+		}
+	}
+}
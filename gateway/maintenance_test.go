@@ -0,0 +1,57 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceAnnounce(t *testing.T) {
+	r := maketestRouterWithMap(testconfigUpdate, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	discord := testBridgerOf(gw, "discord.test")
+
+	gw.SetMaintenance(true, "Down for maintenance")
+	assert.True(t, gw.InMaintenance())
+	for _, b := range []*testBridger{irc, discord} {
+		sent := b.messages()
+		require.Len(t, sent, 1)
+		assert.Equal(t, "Down for maintenance", sent[0].Text)
+		assert.Equal(t, "system", sent[0].Username)
+	}
+	assert.Equal(t, "#main", irc.messages()[0].Channel)
+
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "discord.test", Channel: "general"})
+	// the announcement is only sent once
+	gw.SetMaintenance(true, "Down for maintenance")
+	assert.Len(t, irc.messages(), 1)
+
+	gw.SetMaintenance(false, "")
+	assert.False(t, gw.InMaintenance())
+	sent := irc.messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, maintenanceResumed, sent[1].Text)
+	assert.Len(t, discord.messages(), 2)
+
+	r.relayMessage(config.Message{Text: "back", Username: "alice", Account: "discord.test", Channel: "general"})
+	sent = irc.messages()
+	require.Len(t, sent, 3)
+	assert.Equal(t, "back", sent[2].Text)
+}
+
+func TestMaintenanceSilent(t *testing.T) {
+	r := maketestRouterWithMap(testconfigUpdate, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+
+	gw.SetMaintenance(true, "")
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "discord.test", Channel: "general"})
+	assert.Empty(t, irc.messages())
+
+	// nothing was announced, so there's no resumed message either
+	gw.SetMaintenance(false, "")
+	assert.Empty(t, irc.messages())
+}
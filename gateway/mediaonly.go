@@ -0,0 +1,28 @@
+package gateway
+
+import (
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// acceptsAttachments returns false if msg doesn't go to channel because of its MediaOnly
+// or TextOnly option: MediaOnly channels only get messages with files, TextOnly channels
+// only messages without. Edits only go to the channels that got the original message,
+// other events like deletes and joins aren't filtered.
+func (gw *Gateway) acceptsAttachments(msg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) bool {
+	if !channel.Options.MediaOnly && !channel.Options.TextOnly {
+		return true
+	}
+	if msg.Event != "" && msg.Event != config.EventUserAction {
+		return true
+	}
+	if msg.ID != "" {
+		if _, ok := gw.Messages.Get(msg.Protocol + " " + msg.ID); ok {
+			return gw.getDestBrMsgID(msg.Protocol+" "+msg.ID, dest, channel) != nil
+		}
+	}
+	if hasFiles(msg) {
+		return !channel.Options.TextOnly
+	}
+	return !channel.Options.MediaOnly
+}
@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigMediaOnly = []byte(`
+[discord.test]
+server=""
+[slack.test]
+server=""
+[irc.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="general"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="media"
+
+        [gateway.inout.options]
+        mediaonly=true
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="text"
+
+        [gateway.inout.options]
+        textonly=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+`)
+
+func TestMediaOnlyTextOnly(t *testing.T) {
+	r := maketestRouterWithMap(testconfigMediaOnly, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	file := map[string][]interface{}{"file": {config.FileInfo{Name: "cat.png"}}}
+	r.relayMessage(config.Message{ID: "m1", Text: "hello", Username: "user", Account: "discord.test", Channel: "general"})
+	r.relayMessage(config.Message{ID: "m2", Text: "look", Username: "user", Account: "discord.test", Channel: "general", Extra: file})
+	// edits go where the original went, even without the files
+	r.relayMessage(config.Message{ID: "m1", Text: "hello!", Username: "user", Account: "discord.test", Channel: "general"})
+	r.relayMessage(config.Message{ID: "m2", Text: "look at this", Username: "user", Account: "discord.test", Channel: "general"})
+	// other events aren't filtered
+	r.relayMessage(config.Message{ID: "m2", Text: config.EventMsgDelete, Event: config.EventMsgDelete, Account: "discord.test", Channel: "general"})
+
+	sent := map[string][]string{}
+	for _, account := range []string{"slack.test", "irc.test"} {
+		for _, msg := range testBridgerOf(gw, account).messages() {
+			sent[msg.Channel] = append(sent[msg.Channel], msg.Text)
+		}
+	}
+	assert.Equal(t, []string{"look", "look at this", config.EventMsgDelete}, sent["media"])
+	assert.Equal(t, []string{"hello", "hello!", config.EventMsgDelete}, sent["text"])
+	assert.Equal(t, []string{"hello", "look", "hello!", "look at this", config.EventMsgDelete}, sent["#main"])
+}
@@ -0,0 +1,55 @@
+package gateway
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// memberCountKey is the key of the last member count of a source channel announced to a
+// destination channel.
+type memberCountKey struct {
+	source string
+	dest   string
+}
+
+// skipMemberCount returns true if the member count event rmsg isn't sent to channel,
+// because the count changed less than the ShowMemberCount of dest since the last count
+// sent to channel. The first count of a source channel is only remembered.
+func (gw *Gateway) skipMemberCount(rmsg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) bool {
+	if rmsg.Event != config.EventMemberCount {
+		return false
+	}
+	count, err := strconv.Atoi(rmsg.Text)
+	if err != nil {
+		gw.logger.Errorf("invalid member count %#v from %s", rmsg.Text, rmsg.Account)
+		return true
+	}
+	key := memberCountKey{source: getChannelID(rmsg), dest: channel.ID}
+	last, ok := gw.memberCounts[key]
+	if !ok {
+		gw.memberCounts[key] = count
+		return true
+	}
+	change := count - last
+	if change < 0 {
+		change = -change
+	}
+	if change < dest.GetInt("ShowMemberCount") {
+		return true
+	}
+	gw.memberCounts[key] = count
+	return false
+}
+
+// memberCountMessage returns the member count msg as a message from "system" like
+// "general now has 42 members".
+func memberCountMessage(msg *config.Message) config.Message {
+	count := *msg
+	count.Text = fmt.Sprintf("%s now has %s members", msg.Channel, msg.Text)
+	count.Username = "system"
+	count.Event = ""
+	return count
+}
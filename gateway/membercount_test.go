@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigMemberCount = []byte(`
+[discord.test]
+server=""
+[irc.test]
+server=""
+ShowMemberCount=10
+[slack.test]
+server=""
+ShowMemberCount=1
+[telegram.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="general"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="telegram.test"
+    channel="main"
+`)
+
+func TestShowMemberCount(t *testing.T) {
+	r := maketestRouterWithMap(testconfigMemberCount, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	for _, count := range []string{"100", "105", "95", "bogus", "94", "110"} {
+		r.relayMessage(config.Message{Text: count, Account: "discord.test", Channel: "general", Event: config.EventMemberCount})
+	}
+
+	texts := func(account string) []string {
+		var texts []string
+		for _, msg := range testBridgerOf(gw, account).messages() {
+			texts = append(texts, msg.Text)
+		}
+		return texts
+	}
+	// the first count is the baseline, then every change of at least the threshold
+	assert.Equal(t, []string{"general now has 110 members"}, texts("irc.test"))
+	assert.Equal(t, []string{
+		"general now has 105 members",
+		"general now has 95 members",
+		"general now has 94 members",
+		"general now has 110 members",
+	}, texts("slack.test"))
+	assert.Empty(t, texts("telegram.test"))
+}
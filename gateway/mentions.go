@@ -0,0 +1,65 @@
+package gateway
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// mentionRegexp matches the raw mention tokens of discord (<@123>, <@!123>) and
+// slack (<@U123>, <@U123|name>), the first group is the user ID.
+var mentionRegexp = regexp.MustCompile(`<@!?([A-Za-z0-9]+)(?:\|[^>]*)?>`)
+
+// userNickKey is the key of the nick of a user of account in the userNicks cache.
+func userNickKey(account, userID string) string {
+	return account + " " + userID
+}
+
+// rememberNick records the nick of the sender of msg for ResolveMentions.
+func (gw *Gateway) rememberNick(msg *config.Message) {
+	if msg.UserID == "" || msg.Username == "" || msg.Event != "" && msg.Event != config.EventUserAction {
+		return
+	}
+	gw.userNicks.Add(userNickKey(msg.Account, msg.UserID), msg.Username)
+}
+
+// mentionNick returns the nick of userID on the source bridge of msg. MentionNicks is
+// checked first, then the nicks of the users that sent messages.
+func (gw *Gateway) mentionNick(msg *config.Message, userID string) (string, bool) {
+	br := gw.Bridges[msg.Account]
+	for _, pair := range br.GetStringSlice2D("MentionNicks") {
+		if len(pair) == 2 && pair[0] == userID {
+			return pair[1], true
+		}
+	}
+	// the sender is only remembered once msg is relayed
+	if userID == msg.UserID && msg.Username != "" {
+		return msg.Username, true
+	}
+	if nick, ok := gw.userNicks.Get(userNickKey(msg.Account, userID)); ok {
+		return nick.(string), true
+	}
+	return "", false
+}
+
+// resolveMentions replaces the raw mention tokens in the text of msg with @nick if
+// ResolveMentions is set on the source bridge. Mentions of unknown users are replaced
+// with UnresolvedMention, or kept if it isn't set.
+func (gw *Gateway) resolveMentions(msg *config.Message) {
+	br := gw.Bridges[msg.Account]
+	if !br.GetBool("ResolveMentions") {
+		return
+	}
+	placeholder := br.GetString("UnresolvedMention")
+	msg.Text = mentionRegexp.ReplaceAllStringFunc(msg.Text, func(token string) string {
+		userID := mentionRegexp.FindStringSubmatch(token)[1]
+		if nick, ok := gw.mentionNick(msg, userID); ok {
+			return "@" + nick
+		}
+		if placeholder == "" {
+			return token
+		}
+		return strings.Replace(placeholder, "{ID}", userID, -1)
+	})
+}
@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigMentions = []byte(`
+[discord.test]
+server=""
+ResolveMentions=true
+MentionNicks=[ ["111","alice"] ]
+UnresolvedMention="@unknown-{ID}"
+[slack.test]
+server=""
+ResolveMentions=true
+[irc.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="general"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="general"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+`)
+
+func TestResolveMentions(t *testing.T) {
+	r := maketestRouterWithMap(testconfigMentions, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	// bob is known from an earlier message
+	gw.relayMessage(&config.Message{Text: "hi", Username: "bob", UserID: "222", Account: "discord.test", Channel: "general"}, false)
+
+	for _, testcase := range []struct {
+		account, text, want string
+	}{
+		{"discord.test", "hi <@111>", "hi @alice"},
+		{"discord.test", "<@!222> and <@111>", "@bob and @alice"},
+		{"discord.test", "hi <@333>", "hi @unknown-333"},
+		// the nicks are per bridge, without UnresolvedMention the token is kept
+		{"slack.test", "hi <@222>", "hi <@222>"},
+		{"slack.test", "hi <@U999|carol>", "hi <@U999|carol>"},
+		// not enabled
+		{"irc.test", "hi <@111>", "hi <@111>"},
+	} {
+		msg := config.Message{Text: testcase.text, Username: "user", Account: testcase.account, Channel: "general"}
+		gw.modifyMessage(&msg)
+		assert.Equal(t, testcase.want, msg.Text, testcase.text)
+	}
+
+	gw.relayMessage(&config.Message{Text: "hi", Username: "carol", UserID: "U999", Account: "slack.test", Channel: "general"}, false)
+	msg := config.Message{Text: "hi <@U999|carol>", Username: "user", Account: "slack.test", Channel: "general"}
+	gw.modifyMessage(&msg)
+	assert.Equal(t, "hi @carol", msg.Text)
+}
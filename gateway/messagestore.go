@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// defaultMessageStoreSize is the number of messages kept by the default message store.
+const defaultMessageStoreSize = 5000
+
+// MessageStore stores the IDs of the copies a gateway sent of every message, which
+// are used to send edits, deletes, replies and reactions to the right copy.
+// The key of a message is its protocol and ID, eg "irc 123".
+type MessageStore interface {
+	// Store replaces the IDs of the copies of the message key.
+	Store(key string, ids []*BrMsgID)
+	// Get returns the IDs of the copies of the message key.
+	Get(key string) ([]*BrMsgID, bool)
+	// FindCanonical returns the key of the original message of which the message
+	// with key ID is a copy, or "" if ID isn't a known copy.
+	FindCanonical(ID string) string
+}
+
+// lruMessageStore is the default MessageStore, it keeps the most recent messages in memory.
+type lruMessageStore struct {
+	cache *lru.Cache
+}
+
+// NewLRUMessageStore returns a MessageStore that keeps the size most recent messages
+// in memory.
+func NewLRUMessageStore(size int) MessageStore {
+	cache, _ := lru.New(size)
+	return &lruMessageStore{cache: cache}
+}
+
+func (s *lruMessageStore) Store(key string, ids []*BrMsgID) {
+	s.cache.Add(key, ids)
+}
+
+func (s *lruMessageStore) Get(key string) ([]*BrMsgID, bool) {
+	v, ok := s.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return v.([]*BrMsgID), true
+}
+
+func (s *lruMessageStore) FindCanonical(ID string) string {
+	for _, key := range s.cache.Keys() {
+		v, ok := s.cache.Peek(key)
+		if !ok {
+			continue
+		}
+		for _, downstream := range v.([]*BrMsgID) {
+			if ID == downstream.ID {
+				return key.(string)
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package gateway
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// testMessageStore is a MessageStore backed by a map.
+type testMessageStore struct {
+	messages map[string][]*BrMsgID
+}
+
+func (s *testMessageStore) Store(key string, ids []*BrMsgID) {
+	s.messages[key] = ids
+}
+
+func (s *testMessageStore) Get(key string) ([]*BrMsgID, bool) {
+	ids, ok := s.messages[key]
+	return ids, ok
+}
+
+func (s *testMessageStore) FindCanonical(ID string) string {
+	for key, ids := range s.messages {
+		for _, id := range ids {
+			if id.ID == ID {
+				return key
+			}
+		}
+	}
+	return ""
+}
+
+func TestLRUMessageStore(t *testing.T) {
+	s := NewLRUMessageStore(2)
+	one := []*BrMsgID{{ID: "slack 10"}, {ID: "discord 20"}}
+	s.Store("irc 1", one)
+	s.Store("irc 2", []*BrMsgID{{ID: "slack 11"}})
+
+	ids, ok := s.Get("irc 1")
+	assert.True(t, ok)
+	assert.Equal(t, one, ids)
+	_, ok = s.Get("irc 3")
+	assert.False(t, ok)
+
+	assert.Equal(t, "irc 1", s.FindCanonical("discord 20"))
+	assert.Equal(t, "irc 2", s.FindCanonical("slack 11"))
+	assert.Equal(t, "", s.FindCanonical("slack 99"))
+
+	// the least recently used message is removed
+	s.Store("irc 3", nil)
+	_, ok = s.Get("irc 2")
+	assert.False(t, ok)
+	_, ok = s.Get("irc 1")
+	assert.True(t, ok)
+}
+
+func TestNewWithMessageStore(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	store := &testMessageStore{messages: make(map[string][]*BrMsgID)}
+	gw := NewWithMessageStore(logger, r.Gateways["bridge1"].MyConfig, r, store)
+	r.Gateways["bridge1"] = gw
+	slack := testBridgerOf(gw, "slack.test")
+
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.freenode", Channel: "#wimtesting", ID: "1"})
+	ids, ok := store.Get("irc 1")
+	assert.True(t, ok)
+	assert.Len(t, ids, 3)
+	slackID := gw.getDestMsgID("irc 1", gw.Bridges["slack.test"], gw.Channels["testingslack.test"])
+	assert.Equal(t, "1", slackID)
+
+	// an edit is sent as an edit of the copy
+	r.relayMessage(config.Message{Text: "hello!", Username: "user", Account: "irc.freenode", Channel: "#wimtesting", ID: "1"})
+	sent := slack.messages()
+	assert.Len(t, sent, 2)
+	assert.Equal(t, slackID, sent[1].ID)
+
+	assert.Equal(t, "1", gw.FindCanonicalMsgID("irc", "1"))
+	assert.Equal(t, "irc 1", store.FindCanonical("slack "+slackID))
+	assert.Equal(t, "", gw.FindCanonicalMsgID("slack", "99"))
+}
@@ -0,0 +1,52 @@
+package gateway
+
+import (
+	"fmt"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// MessageModifier can change an incoming message before it is relayed to the
+// destination bridges of a gateway.
+type MessageModifier interface {
+	Modify(msg *config.Message) error
+}
+
+// MessageModifierFunc is an adapter to allow the use of ordinary functions as a MessageModifier.
+type MessageModifierFunc func(msg *config.Message) error
+
+// Modify calls f(msg).
+func (f MessageModifierFunc) Modify(msg *config.Message) error {
+	return f(msg)
+}
+
+// tengoModifier runs the tengo script in filename on the message.
+type tengoModifier struct {
+	name     string
+	filename string
+	data     map[string]string
+	scripts  *scriptCache
+}
+
+func (t *tengoModifier) Modify(msg *config.Message) error {
+	if err := modifyMessageTengo(t.scripts, t.filename, msg, t.data); err != nil {
+		return fmt.Errorf("%s failed: %s", t.name, err)
+	}
+	return nil
+}
+
+// AddModifier registers a modifier that will be run on every message received by the gateway.
+// Modifiers are run in the order they are added, after the configured tengo scripts.
+func (gw *Gateway) AddModifier(m MessageModifier) {
+	gw.modifiers = append(gw.modifiers, m)
+}
+
+// runModifiers runs all registered modifiers on msg, errors are logged and don't stop
+// the other modifiers from running.
+func (gw *Gateway) runModifiers(msg *config.Message) {
+	for _, m := range gw.modifiers {
+		if err := m.Modify(msg); err != nil {
+			gw.logger.Errorf("Modifying message failed: %s", err)
+		}
+	}
+}
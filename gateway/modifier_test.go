@@ -0,0 +1,35 @@
+package gateway
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type upperModifier struct {
+	calls int
+}
+
+func (m *upperModifier) Modify(msg *config.Message) error {
+	m.calls++
+	msg.Text = strings.ToUpper(msg.Text)
+	return nil
+}
+
+func TestModifyMessageModifiers(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	upper := &upperModifier{}
+	gw.AddModifier(MessageModifierFunc(func(msg *config.Message) error {
+		return errors.New("always failing")
+	}))
+	gw.AddModifier(upper)
+
+	msg := &config.Message{Text: "hello world", Username: "user", Account: "irc.freenode", Channel: "#wimtesting"}
+	gw.modifyMessage(msg)
+	assert.Equal(t, "HELLO WORLD", msg.Text)
+	assert.Equal(t, 1, upper.calls)
+}
@@ -0,0 +1,44 @@
+package gateway
+
+import (
+	"sync"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// MessageObserver is called with a copy of every message gw sends and the account
+// of the destination bridge.
+type MessageObserver func(msg config.Message, dest string)
+
+type observers struct {
+	sync.RWMutex
+	list []MessageObserver
+}
+
+// OnMessage registers fn to be called right before a message is sent to a bridge.
+// msg is a copy, but its Extra is shared with the sent message and must not be modified.
+func (gw *Gateway) OnMessage(fn func(msg config.Message, dest string)) {
+	gw.observers.Lock()
+	defer gw.observers.Unlock()
+	gw.observers.list = append(gw.observers.list, fn)
+}
+
+// notifyObservers calls every registered observer with msg for dest.
+func (gw *Gateway) notifyObservers(msg config.Message, dest string) {
+	gw.observers.RLock()
+	list := gw.observers.list
+	gw.observers.RUnlock()
+	for _, fn := range list {
+		gw.callObserver(fn, msg, dest)
+	}
+}
+
+// callObserver calls fn and logs the panics of fn instead of crashing.
+func (gw *Gateway) callObserver(fn MessageObserver, msg config.Message, dest string) {
+	defer func() {
+		if err := recover(); err != nil {
+			gw.logger.Errorf("message observer panicked: %v", err)
+		}
+	}()
+	fn(msg, dest)
+}
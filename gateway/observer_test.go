@@ -0,0 +1,35 @@
+package gateway
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnMessage(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+
+	var dests []string
+	gw.OnMessage(func(msg config.Message, dest string) {
+		panic("observer failed")
+	})
+	gw.OnMessage(func(msg config.Message, dest string) {
+		assert.Equal(t, "hello", msg.Text)
+		dests = append(dests, dest)
+		// changes to the copy aren't sent
+		msg.Text = "changed"
+	})
+
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.freenode", Channel: "#wimtesting"})
+
+	sort.Strings(dests)
+	assert.Equal(t, []string{"discord.test", "gitter.42wim", "slack.test"}, dests)
+	for _, account := range dests {
+		sent := testBridgerOf(gw, account).messages()
+		assert.Len(t, sent, 1)
+		assert.Equal(t, "hello", sent[0].Text)
+	}
+}
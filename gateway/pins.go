@@ -0,0 +1,38 @@
+package gateway
+
+import (
+	"fmt"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// isPin returns true if msg pins or unpins a message.
+func isPin(msg *config.Message) bool {
+	return msg.Event == config.EventMsgPin || msg.Event == config.EventMsgUnpin
+}
+
+// sendPin pins or unpins the message msg.ID on dest, or sends a text notice if
+// dest can't pin messages. nick is the nick of the user who pinned the message.
+func (gw *Gateway) sendPin(msg config.Message, nick string, dest *bridge.Bridge) (string, error) {
+	if msg.ID == "" {
+		gw.logger.Debugf("pin of unknown message, not sending to %s", dest.Account)
+		return "", nil
+	}
+	if p, ok := dest.Bridger.(bridge.MessagePinner); ok {
+		return "", p.PinMessage(msg)
+	}
+	msg.Text = pinNotice(msg.Event, nick)
+	msg.Event = ""
+	// this is a new message, not an edit of the pinned message
+	msg.ID = ""
+	return gw.send(dest, msg)
+}
+
+// pinNotice returns the text used for pins on bridges that can't pin messages.
+func pinNotice(event, nick string) string {
+	if event == config.EventMsgUnpin {
+		return fmt.Sprintf("📌 %s unpinned a message", nick)
+	}
+	return fmt.Sprintf("📌 %s pinned a message", nick)
+}
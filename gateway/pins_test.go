@@ -0,0 +1,74 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPinBridger is a testBridger that can pin messages.
+type testPinBridger struct {
+	*testBridger
+
+	pins []config.Message
+}
+
+func newTestPinBridger(cfg *bridge.Config) bridge.Bridger {
+	return &testPinBridger{testBridger: &testBridger{}}
+}
+
+func (b *testPinBridger) PinMessage(msg config.Message) error {
+	b.Lock()
+	defer b.Unlock()
+	b.pins = append(b.pins, msg)
+	return nil
+}
+
+func TestRelayPin(t *testing.T) {
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["slack"] = newTestPinBridger
+
+	r := maketestRouterWithMap(testconfigReactions, bridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	discord := testBridgerOf(gw, "discord.test")
+	slack := gw.Bridges["slack.test"].Bridger.(*testPinBridger)
+
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.test", Channel: "#main", ID: "orig"})
+	assert.Len(t, discord.messages(), 1)
+
+	// pin the copy of the message on discord
+	r.relayMessage(config.Message{Username: "other", Account: "discord.test", Channel: "main", ID: "1", Event: config.EventMsgPin})
+
+	// slack pins its own copy
+	assert.Len(t, slack.messages(), 1)
+	assert.Len(t, slack.pins, 1)
+	assert.Equal(t, config.EventMsgPin, slack.pins[0].Event)
+	assert.Equal(t, "1", slack.pins[0].ID)
+
+	// irc gets a notice as a new message
+	sent := irc.messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "", sent[0].Event)
+	assert.Equal(t, "", sent[0].ID)
+	assert.Equal(t, "📌 other pinned a message", sent[0].Text)
+
+	// pins are not added to the message cache
+	_, ok := gw.Messages.Get("discord 1")
+	assert.False(t, ok)
+
+	// pins of unknown messages aren't sent
+	r.relayMessage(config.Message{Username: "other", Account: "discord.test", Channel: "main", ID: "unknown", Event: config.EventMsgUnpin})
+	assert.Len(t, slack.pins, 1)
+	assert.Len(t, irc.messages(), 1)
+}
+
+func TestPinNotice(t *testing.T) {
+	assert.Equal(t, "📌 user pinned a message", pinNotice(config.EventMsgPin, "user"))
+	assert.Equal(t, "📌 user unpinned a message", pinNotice(config.EventMsgUnpin, "user"))
+}
@@ -0,0 +1,55 @@
+package gateway
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+const defaultPollFormat = `Poll: {{.Question}} — options: {{join .Options ", "}}`
+
+var pollFuncs = template.FuncMap{"join": strings.Join}
+
+// getPoll returns the poll of msg, if any.
+func getPoll(msg *config.Message) (config.Poll, bool) {
+	if msg.Extra == nil || len(msg.Extra[config.ExtraPoll]) == 0 {
+		return config.Poll{}, false
+	}
+	poll, ok := msg.Extra[config.ExtraPoll][0].(config.Poll)
+	return poll, ok
+}
+
+// sendPoll sends the poll of msg to dest, as text using PollFormat if dest doesn't
+// support polls.
+func (gw *Gateway) sendPoll(msg config.Message, poll config.Poll, dest *bridge.Bridge) (string, error) {
+	if ps, ok := dest.Bridger.(bridge.PollSender); ok {
+		return ps.SendPoll(msg, poll)
+	}
+	text := gw.renderPoll(poll, dest.GetString("PollFormat"))
+	if msg.Text != "" {
+		text = msg.Text + "\n" + text
+	}
+	msg.Text = text
+	return gw.send(dest, msg)
+}
+
+// renderPoll returns the text representation of poll using the template format.
+func (gw *Gateway) renderPoll(poll config.Poll, format string) string {
+	if format == "" {
+		format = defaultPollFormat
+	}
+	tmpl, err := template.New("poll").Funcs(pollFuncs).Parse(format)
+	if err != nil {
+		gw.logger.Errorf("invalid PollFormat %#v: %s", format, err)
+		tmpl = template.Must(template.New("poll").Funcs(pollFuncs).Parse(defaultPollFormat))
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, poll); err != nil {
+		gw.logger.Errorf("PollFormat %#v failed: %s", format, err)
+		return ""
+	}
+	return buf.String()
+}
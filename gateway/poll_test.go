@@ -0,0 +1,66 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// testPollBridger is a testBridger that supports native polls.
+type testPollBridger struct {
+	*testBridger
+
+	polls []config.Poll
+}
+
+func newTestPollBridger(cfg *bridge.Config) bridge.Bridger {
+	return &testPollBridger{testBridger: &testBridger{}}
+}
+
+func (b *testPollBridger) SendPoll(msg config.Message, poll config.Poll) (string, error) {
+	b.Lock()
+	defer b.Unlock()
+	b.polls = append(b.polls, poll)
+	return "poll", nil
+}
+
+func TestRenderPoll(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	poll := config.Poll{Question: "Lunch?", Options: []string{"pizza", "sushi", "salad"}}
+
+	for format, output := range map[string]string{
+		"": "Poll: Lunch? — options: pizza, sushi, salad",
+		"{{.Question}}{{range $i, $o := .Options}}\n{{$i}}. {{$o}}{{end}}": "Lunch?\n0. pizza\n1. sushi\n2. salad",
+		"{{.Question": "Poll: Lunch? — options: pizza, sushi, salad",
+	} {
+		assert.Equalf(t, output, gw.renderPoll(poll, format), "case '%s' failed", format)
+	}
+}
+
+func TestSendPoll(t *testing.T) {
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["discord"] = newTestPollBridger
+
+	r := maketestRouterWithMap(testconfig, bridgeMap)
+	gw := r.Gateways["bridge1"]
+	poll := config.Poll{Question: "Lunch?", Options: []string{"pizza", "sushi"}}
+
+	r.relayMessage(config.Message{
+		Text: "vote please", Username: "user", Account: "irc.freenode", Channel: "#wimtesting",
+		Extra: map[string][]interface{}{config.ExtraPoll: {poll}},
+	})
+
+	sent := testBridgerOf(gw, "slack.test").messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "vote please\nPoll: Lunch? — options: pizza, sushi", sent[0].Text)
+
+	discord := gw.Bridges["discord.test"].Bridger.(*testPollBridger)
+	assert.Equal(t, []config.Poll{poll}, discord.polls)
+	assert.Empty(t, discord.messages())
+}
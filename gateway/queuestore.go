@@ -0,0 +1,183 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+func init() {
+	// the types of Message.Extra that are saved in a SendQueueFile
+	gob.Register(config.FileInfo{})
+	gob.Register(config.Poll{})
+	gob.Register(config.DeadLetter{})
+}
+
+// persistedJob is a queued sendJob as saved in the SendQueueFile of its destination.
+type persistedJob struct {
+	Seq      uint64
+	Gateway  string
+	Channel  string
+	ParentID string
+	Msg      config.Message
+}
+
+// queueStore saves the messages queued for a bridge with ParallelSend to its
+// SendQueueFile until they are sent, so they are sent after a restart.
+type queueStore struct {
+	sync.Mutex
+
+	file string
+	next uint64
+	jobs []persistedJob
+}
+
+// loadQueueStore returns the queueStore of file with the jobs saved in it.
+func loadQueueStore(file string) (*queueStore, error) {
+	s := &queueStore{file: file}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s.jobs); err != nil {
+		return s, err
+	}
+	for _, job := range s.jobs {
+		if job.Seq >= s.next {
+			s.next = job.Seq + 1
+		}
+	}
+	return s, nil
+}
+
+// add saves job, queued by gateway, and returns its sequence number.
+func (s *queueStore) add(gateway string, job *sendJob) (uint64, error) {
+	s.Lock()
+	defer s.Unlock()
+	seq := s.next
+	s.next++
+	s.jobs = append(s.jobs, persistedJob{
+		Seq:      seq,
+		Gateway:  gateway,
+		Channel:  job.channel.ID,
+		ParentID: job.parentID,
+		Msg:      persistableMessage(job.msg),
+	})
+	return seq, s.save()
+}
+
+// remove removes the job seq, which has been handled.
+func (s *queueStore) remove(seq uint64) error {
+	s.Lock()
+	defer s.Unlock()
+	for i, job := range s.jobs {
+		if job.Seq == seq {
+			s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
+			return s.save()
+		}
+	}
+	return nil
+}
+
+// pending returns the saved jobs in the order they were queued.
+func (s *queueStore) pending() []persistedJob {
+	s.Lock()
+	defer s.Unlock()
+	return append([]persistedJob(nil), s.jobs...)
+}
+
+// save writes the jobs to the file, replacing it at once so a crash doesn't leave a
+// partially written file.
+func (s *queueStore) save() error {
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(s.jobs); err != nil {
+		return err
+	}
+	tmp := s.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.file)
+}
+
+// persistableMessage returns msg without the Extra values that can't be saved, like
+// the bridge specific attachments of slack.
+func persistableMessage(msg config.Message) config.Message {
+	if len(msg.Extra) == 0 {
+		return msg
+	}
+	extra := make(map[string][]interface{}, len(msg.Extra))
+	for k, v := range msg.Extra {
+		if err := gob.NewEncoder(ioutil.Discard).Encode(v); err != nil {
+			continue
+		}
+		extra[k] = v
+	}
+	msg.Extra = extra
+	return msg
+}
+
+// queueStores has the queueStore of every bridge with a SendQueueFile.
+type queueStores struct {
+	sync.Mutex
+	stores map[string]*queueStore
+}
+
+func newQueueStores() *queueStores {
+	return &queueStores{stores: make(map[string]*queueStore)}
+}
+
+// queueStore returns the queueStore of dest, or nil if dest has no SendQueueFile. The file is
+// loaded the first time.
+func (r *Router) queueStore(dest *bridge.Bridge) *queueStore {
+	file := dest.GetString("SendQueueFile")
+	if file == "" {
+		return nil
+	}
+	r.queueStores.Lock()
+	defer r.queueStores.Unlock()
+	if s, ok := r.queueStores.stores[dest.Account]; ok {
+		return s
+	}
+	s, err := loadQueueStore(file)
+	if err != nil {
+		r.logger.Errorf("Failed to read SendQueueFile %s of %s, dropping the saved messages: %s", file, dest.Account, err)
+	}
+	r.queueStores.stores[dest.Account] = s
+	return s
+}
+
+// restoreSendQueues queues the messages saved in the SendQueueFile of the bridges again.
+// Messages for gateways or channels that aren't bridged anymore are dropped.
+func (r *Router) restoreSendQueues() {
+	for _, gw := range r.Gateways {
+		for _, dest := range gw.Bridges {
+			s := r.queueStore(dest)
+			if s == nil {
+				continue
+			}
+			for _, job := range s.pending() {
+				if _, ok := r.Gateways[job.Gateway]; ok && job.Gateway != gw.Name {
+					continue
+				}
+				channel, ok := gw.Channels[job.Channel]
+				if job.Gateway != gw.Name || !ok || channel.Account != dest.Account {
+					r.logger.Warnf("Dropping saved message of gateway %s to %s, channel isn't bridged anymore", job.Gateway, job.Channel)
+					if err := s.remove(job.Seq); err != nil {
+						r.logger.Errorf("Failed to remove message from SendQueueFile of %s: %s", dest.Account, err)
+					}
+					continue
+				}
+				gw.enqueue(sendJob{msg: job.Msg, dest: dest, channel: channel, parentID: job.ParentID, store: s, seq: job.Seq})
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package gateway
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testconfigSendQueueFile(file string) []byte {
+	return []byte(fmt.Sprintf(`
+[general]
+ParallelSend=true
+[irc.test]
+server=""
+[slack.test]
+server=""
+SendQueueFile=%q
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="general"
+`, file))
+}
+
+func TestSendQueueFileRestore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matterbridge-queue")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "queue")
+
+	r := maketestRouterWithMap(testconfigSendQueueFile(file), testBridgeMap)
+	gw := r.Gateways["main"]
+	dest := gw.Bridges["slack.test"]
+	store := r.queueStore(dest)
+	require.NotNil(t, store)
+	// queued but not sent before stopping
+	for _, text := range []string{"one", "two"} {
+		job := sendJob{
+			msg: config.Message{
+				Text: text, Username: "user", Account: "irc.test", Channel: "general", Protocol: "irc",
+				Extra: map[string][]interface{}{"file": {config.FileInfo{Name: "a.png", URL: "http://x/a.png"}}},
+			},
+			dest:    dest,
+			channel: gw.Channels["generalslack.test"],
+		}
+		_, err := store.add(gw.Name, &job)
+		require.NoError(t, err)
+	}
+	// a message for a channel that isn't bridged anymore
+	_, err = store.add(gw.Name, &sendJob{msg: config.Message{Text: "gone"}, channel: &config.ChannelInfo{ID: "oldslack.test"}})
+	require.NoError(t, err)
+
+	// simulate a restart
+	r = maketestRouterWithMap(testconfigSendQueueFile(file), testBridgeMap)
+	gw = r.Gateways["main"]
+	r.restoreSendQueues()
+	gw.flushSendQueues()
+
+	sent := testBridgerOf(gw, "slack.test").messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "one", sent[0].Text)
+	assert.Equal(t, "two", sent[1].Text)
+	assert.Equal(t, "a.png", sent[0].Extra["file"][0].(config.FileInfo).Name)
+
+	store, err = loadQueueStore(file)
+	require.NoError(t, err)
+	assert.Empty(t, store.pending())
+}
+
+func TestPersistableMessage(t *testing.T) {
+	msg := config.Message{Text: "hello", Extra: map[string][]interface{}{
+		"file":  {config.FileInfo{Name: "a.png"}},
+		"slack": {func() {}},
+	}}
+	persisted := persistableMessage(msg)
+	assert.Contains(t, persisted.Extra, "file")
+	assert.NotContains(t, persisted.Extra, "slack")
+	// the message itself is unchanged
+	assert.Contains(t, msg.Extra, "slack")
+}
@@ -0,0 +1,99 @@
+package gateway
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// isReaction returns true if msg adds or removes a reaction.
+func isReaction(msg *config.Message) bool {
+	return msg.Event == config.EventReactionAdd || msg.Event == config.EventReactionRemove
+}
+
+// getDestCorrelatedMsgID returns the ID on dest of the message msgID received from protocol.
+// The message can be the original message or one of the copies sent by the gateway.
+func (gw *Gateway) getDestCorrelatedMsgID(protocol, msgID string, dest *bridge.Bridge, channel *config.ChannelInfo) string {
+	key := protocol + " " + msgID
+	if _, ok := gw.Messages.Get(key); ok {
+		return gw.getDestMsgID(key, dest, channel)
+	}
+	key = gw.Messages.FindCanonical(key)
+	if key == "" {
+		return ""
+	}
+	if ID := gw.getDestMsgID(key, dest, channel); ID != "" {
+		return ID
+	}
+	// the original message was sent on dest
+	if strings.HasPrefix(key, dest.Protocol+" ") {
+		return strings.TrimPrefix(key, dest.Protocol+" ")
+	}
+	return ""
+}
+
+// supportsReactions returns true if dest can send reactions natively.
+func supportsReactions(dest *bridge.Bridge) bool {
+	_, ok := dest.Bridger.(bridge.ReactionSender)
+	return ok
+}
+
+// sendReaction sends the reaction msg to dest, or a text notice if dest doesn't support
+// reactions and ReactionNotice is enabled. nick is the nick of the user who reacted and
+// author the nick of the sender of the reacted message, if known.
+func (gw *Gateway) sendReaction(msg config.Message, nick, author string, dest *bridge.Bridge) (string, error) {
+	if msg.ID == "" {
+		gw.logger.Debugf("reaction to unknown message, not sending to %s", dest.Account)
+		return "", nil
+	}
+	if rs, ok := dest.Bridger.(bridge.ReactionSender); ok {
+		return "", rs.SendReaction(msg)
+	}
+	if !dest.GetBool("ReactionNotice") {
+		return "", nil
+	}
+	msg.Text = reactionNotice(&msg, nick, author)
+	msg.Event = ""
+	// this is a new message, not an edit of the reacted message
+	msg.ID = ""
+	return gw.send(dest, msg)
+}
+
+// reactionNotice returns the text used for reactions on bridges without reaction support,
+// eg "alice reacted 👍 to bob's message". Unknown nicks are left out.
+func reactionNotice(msg *config.Message, nick, author string) string {
+	remove := msg.Event == config.EventReactionRemove
+	switch {
+	case nick != "" && author != "" && remove:
+		return fmt.Sprintf("%s removed reaction %s from %s's message", nick, msg.Text, author)
+	case nick != "" && author != "":
+		return fmt.Sprintf("%s reacted %s to %s's message", nick, msg.Text, author)
+	case nick != "" && remove:
+		return fmt.Sprintf("%s removed reaction %s", nick, msg.Text)
+	case nick != "":
+		return fmt.Sprintf("%s reacted with %s", nick, msg.Text)
+	case remove:
+		return fmt.Sprintf("removed reaction %s", msg.Text)
+	}
+	return fmt.Sprintf("reacted with %s", msg.Text)
+}
+
+// messageAuthor returns the nick of the sender of the message msgID received from
+// protocol, which can be the original message or one of its copies.
+func (gw *Gateway) messageAuthor(protocol, msgID string) string {
+	key := protocol + " " + msgID
+	IDs, ok := gw.Messages.Get(key)
+	if !ok {
+		if key = gw.Messages.FindCanonical(key); key != "" {
+			IDs, _ = gw.Messages.Get(key)
+		}
+	}
+	for _, id := range IDs {
+		if id.Username != "" {
+			return id.Username
+		}
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigReactions = []byte(`
+[irc.test]
+server=""
+ReactionNotice=true
+[slack.test]
+server=""
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+`)
+
+// testReactionBridger is a testBridger that supports native reactions.
+type testReactionBridger struct {
+	*testBridger
+
+	reactions []config.Message
+}
+
+func newTestReactionBridger(cfg *bridge.Config) bridge.Bridger {
+	return &testReactionBridger{testBridger: &testBridger{}}
+}
+
+func (b *testReactionBridger) SendReaction(msg config.Message) error {
+	b.Lock()
+	defer b.Unlock()
+	b.reactions = append(b.reactions, msg)
+	return nil
+}
+
+func TestRelayReaction(t *testing.T) {
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["discord"] = newTestReactionBridger
+
+	r := maketestRouterWithMap(testconfigReactions, bridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	slack := testBridgerOf(gw, "slack.test")
+	discord := gw.Bridges["discord.test"].Bridger.(*testReactionBridger)
+
+	r.relayMessage(config.Message{Text: "hello", Username: "user", Account: "irc.test", Channel: "#main", ID: "orig"})
+	assert.Len(t, slack.messages(), 1)
+	assert.Len(t, discord.messages(), 1)
+
+	// react on the copy of the message on slack
+	r.relayMessage(config.Message{
+		Text: "👍", Username: "other", Account: "slack.test", Channel: "main",
+		ID: "1", Event: config.EventReactionAdd,
+	})
+
+	// discord reacts natively on its own copy
+	assert.Len(t, discord.messages(), 1)
+	assert.Len(t, discord.reactions, 1)
+	assert.Equal(t, config.EventReactionAdd, discord.reactions[0].Event)
+	assert.Equal(t, "1", discord.reactions[0].ID)
+	assert.Equal(t, "👍", discord.reactions[0].Text)
+
+	// irc gets a notice as a new message
+	sent := irc.messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "", sent[0].Event)
+	assert.Equal(t, "", sent[0].ID)
+	assert.Equal(t, "other reacted 👍 to user's message", sent[0].Text)
+
+	// slack doesn't support reactions and doesn't want a notice
+	assert.Len(t, slack.messages(), 1)
+
+	// reactions are not added to the message cache
+	_, ok := gw.Messages.Get("slack 1")
+	assert.False(t, ok)
+}
+
+func TestReactionNotice(t *testing.T) {
+	add := &config.Message{Event: config.EventReactionAdd, Text: ":smile:"}
+	remove := &config.Message{Event: config.EventReactionRemove, Text: ":smile:"}
+	assert.Equal(t, "reacted with :smile:", reactionNotice(add, "", ""))
+	assert.Equal(t, "removed reaction :smile:", reactionNotice(remove, "", ""))
+	assert.Equal(t, "alice reacted with :smile:", reactionNotice(add, "alice", ""))
+	assert.Equal(t, "alice removed reaction :smile:", reactionNotice(remove, "alice", ""))
+	assert.Equal(t, "alice reacted :smile: to bob's message", reactionNotice(add, "alice", "bob"))
+	assert.Equal(t, "alice removed reaction :smile: from bob's message", reactionNotice(remove, "alice", "bob"))
+}
@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// reactionBatch collects the reaction changes for a message on a destination channel with
+// ReactionSummaryWindow enabled until its window elapses.
+type reactionBatch struct {
+	gw       *Gateway
+	key      string
+	msg      config.Message
+	dest     *bridge.Bridge
+	channel  *config.ChannelInfo
+	parentID string
+	changes  map[string]int
+	timer    *time.Timer
+}
+
+// reactionSummary is the summary sent for a message on a destination channel, kept to
+// update it with the changes of the next windows.
+type reactionSummary struct {
+	id     string
+	counts map[string]int
+}
+
+// batchReaction adds the reaction rmsg to the batch of the reacted message on channel if
+// ReactionSummaryWindow is set on dest. Returns true if rmsg is batched.
+func (gw *Gateway) batchReaction(rmsg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) bool {
+	window := dest.GetInt("ReactionSummaryWindow")
+	if !isReaction(rmsg) || window <= 0 {
+		return false
+	}
+	// don't batch the reactions of the destination channel itself
+	if channel.ID == getChannelID(rmsg) {
+		return false
+	}
+	destID := gw.getDestCorrelatedMsgID(rmsg.Protocol, rmsg.ID, dest, channel)
+	if destID == "" {
+		return false
+	}
+	key := channel.ID + " " + destID
+	b, ok := gw.reactionBatches[key]
+	if !ok {
+		b = &reactionBatch{
+			gw: gw, key: key, dest: dest, channel: channel,
+			parentID: gw.FindCanonicalMsgID(rmsg.Protocol, rmsg.ID),
+			changes:  make(map[string]int),
+		}
+		b.timer = time.AfterFunc(time.Duration(window)*time.Second, func() {
+			gw.Router.reactionsExpired <- b
+		})
+		gw.reactionBatches[key] = b
+	}
+	b.msg = *rmsg
+	if rmsg.Event == config.EventReactionRemove {
+		b.changes[rmsg.Text]--
+	} else {
+		b.changes[rmsg.Text]++
+	}
+	return true
+}
+
+// reactionSummaryText returns the reactions in counts like "❤️ x5 👍 x2", the most used
+// reactions first.
+func reactionSummaryText(counts map[string]int) string {
+	reactions := make([]string, 0, len(counts))
+	for reaction := range counts {
+		reactions = append(reactions, reaction)
+	}
+	sort.Slice(reactions, func(i, j int) bool {
+		if counts[reactions[i]] != counts[reactions[j]] {
+			return counts[reactions[i]] > counts[reactions[j]]
+		}
+		return reactions[i] < reactions[j]
+	})
+	parts := make([]string, 0, len(reactions))
+	for _, reaction := range reactions {
+		parts = append(parts, fmt.Sprintf("%s x%d", reaction, counts[reaction]))
+	}
+	return strings.Join(parts, " ")
+}
+
+// sendReactionSummary adds the changes of b to the summary of the reacted message and
+// sends it as a reply to the message. The summary sent for an earlier window is edited,
+// destinations that didn't return its ID get a new summary.
+func (gw *Gateway) sendReactionSummary(b *reactionBatch) {
+	if gw.reactionBatches[b.key] != b {
+		return
+	}
+	delete(gw.reactionBatches, b.key)
+	b.timer.Stop()
+
+	summary := &reactionSummary{counts: make(map[string]int)}
+	if v, ok := gw.reactionTotals.Get(b.key); ok {
+		summary = v.(*reactionSummary)
+	}
+	changed := false
+	for reaction, change := range b.changes {
+		if change == 0 {
+			continue
+		}
+		changed = true
+		summary.counts[reaction] += change
+		if summary.counts[reaction] <= 0 {
+			delete(summary.counts, reaction)
+		}
+	}
+	if !changed || summary.id == "" && len(summary.counts) == 0 {
+		return
+	}
+
+	rmsg := b.msg
+	rmsg.Event = ""
+	rmsg.ID = ""
+	rmsg.Username = "system"
+	rmsg.Text = reactionSummaryText(summary.counts)
+	if rmsg.Text == "" {
+		rmsg.Text = "no reactions"
+	}
+	msg, ok := gw.prepareMessage(&rmsg, b.dest, b.channel, b.parentID)
+	if !ok {
+		return
+	}
+	msg.ID = summary.id
+	mID, err := gw.send(b.dest, msg)
+	if err != nil {
+		gw.logger.Errorf("sending reaction summary to %s failed: %s", b.dest.Account, err)
+		return
+	}
+	if summary.id == "" {
+		summary.id = mID
+	}
+	gw.reactionTotals.Add(b.key, summary)
+}
+
+// flushReactionSummaries sends the summaries of all batched reactions of gw.
+func (gw *Gateway) flushReactionSummaries() {
+	for _, b := range gw.reactionBatches {
+		gw.sendReactionSummary(b)
+	}
+}
@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigReactionSummary = []byte(`
+[general]
+RemoteNickFormat="{NICK}: "
+[api.test]
+bindaddress=""
+[discord.test]
+server=""
+ReactionSummaryWindow=60
+[irc.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="api.test"
+    channel="api"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="general"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+`)
+
+func TestReactionSummaryText(t *testing.T) {
+	assert.Equal(t, "❤️ x5 🎉 x2 👍 x2", reactionSummaryText(map[string]int{"👍": 2, "❤️": 5, "🎉": 2}))
+	assert.Equal(t, "", reactionSummaryText(map[string]int{}))
+}
+
+func TestReactionSummary(t *testing.T) {
+	r := maketestRouterWithMap(testconfigReactionSummary, testBridgeMap)
+	gw := r.Gateways["main"]
+	discord := testBridgerOf(gw, "discord.test")
+	irc := testBridgerOf(gw, "irc.test")
+
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "api.test", Channel: "api", Gateway: "main", ID: "42"})
+	require.Len(t, discord.messages(), 1)
+
+	react := func(event, emoji string) {
+		r.relayMessage(config.Message{Text: emoji, Username: "bob", Account: "api.test", Channel: "api", Gateway: "main", ID: "42", Event: event})
+	}
+	react(config.EventReactionAdd, "❤️")
+	react(config.EventReactionAdd, "❤️")
+	react(config.EventReactionAdd, "👍")
+	react(config.EventReactionAdd, "❤️")
+	react(config.EventReactionRemove, "👍")
+
+	// the reactions are collected until the window elapses
+	assert.Len(t, discord.messages(), 1)
+	assert.Len(t, gw.reactionBatches, 1)
+	// irc has no reaction support, notices or summaries
+	assert.Len(t, irc.messages(), 1)
+
+	gw.flushReactionSummaries()
+	sent := discord.messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "❤️ x3", sent[1].Text)
+	assert.Equal(t, "system: ", sent[1].Username)
+	assert.Equal(t, "1", sent[1].ParentID)
+	assert.Equal(t, "", sent[1].ID)
+	assert.Equal(t, "", sent[1].Event)
+	assert.Empty(t, gw.reactionBatches)
+
+	// the summary of the next window edits the summary
+	react(config.EventReactionAdd, "👍")
+	react(config.EventReactionRemove, "❤️")
+	gw.flushReactionSummaries()
+	sent = discord.messages()
+	require.Len(t, sent, 3)
+	assert.Equal(t, "❤️ x2 👍 x1", sent[2].Text)
+	assert.Equal(t, "2", sent[2].ID)
+
+	// windows without changes aren't sent
+	react(config.EventReactionAdd, "🎉")
+	react(config.EventReactionRemove, "🎉")
+	gw.flushReactionSummaries()
+	assert.Len(t, discord.messages(), 3)
+
+	// reactions to unknown messages aren't summarized
+	r.relayMessage(config.Message{Text: "👍", Username: "bob", Account: "api.test", Channel: "api", Gateway: "main", ID: "43", Event: config.EventReactionAdd})
+	assert.Empty(t, gw.reactionBatches)
+	assert.Len(t, discord.messages(), 3)
+}
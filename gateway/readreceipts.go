@@ -0,0 +1,138 @@
+package gateway
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// seenBy are the readers of a message, by account and user.
+type seenBy map[string]bool
+
+// readReceiptUpdate is a pending update of the seen count of a message on a destination
+// channel with ShowReadReceipts, sent when its interval elapses.
+type readReceiptUpdate struct {
+	gw      *Gateway
+	key     string
+	msgKey  string
+	dest    *bridge.Bridge
+	channel *config.ChannelInfo
+	timer   *time.Timer
+}
+
+// canonicalKey returns the key of the message msgID of protocol in the message cache,
+// which can be the original message or one of its copies.
+func (gw *Gateway) canonicalKey(protocol, msgID string) string {
+	key := protocol + " " + msgID
+	if _, ok := gw.Messages.Get(key); ok {
+		return key
+	}
+	return gw.Messages.FindCanonical(key)
+}
+
+// rememberReadable keeps the message msg, so its seen count can be shown later.
+func (gw *Gateway) rememberReadable(msg *config.Message) {
+	if msg.ID == "" || msg.Event != "" && msg.Event != config.EventUserAction {
+		return
+	}
+	src := *msg
+	src.Extra = nil
+	gw.readables.Add(msg.Protocol+" "+msg.ID, src)
+}
+
+// countReadReceipt adds the reader of the read receipt msg to the readers of the message
+// it refers to. Every reader is only counted once.
+func (gw *Gateway) countReadReceipt(msg *config.Message) {
+	if msg.Event != config.EventReadReceipt {
+		return
+	}
+	key := gw.canonicalKey(msg.Protocol, msg.ID)
+	reader := msg.UserID
+	if reader == "" {
+		reader = msg.Username
+	}
+	if key == "" || reader == "" {
+		return
+	}
+	readers := seenBy{}
+	if v, ok := gw.seen.Get(key); ok {
+		readers = v.(seenBy)
+	}
+	readers[msg.Account+" "+reader] = true
+	gw.seen.Add(key, readers)
+}
+
+// seenCount returns the number of readers of the message with key msgKey.
+func (gw *Gateway) seenCount(msgKey string) int {
+	if v, ok := gw.seen.Get(msgKey); ok {
+		return len(v.(seenBy))
+	}
+	return 0
+}
+
+// showsReadReceipts returns true if the seen count is shown on dest, which needs to be
+// able to edit its messages.
+func showsReadReceipts(dest *bridge.Bridge) bool {
+	return dest.GetInt("ShowReadReceipts") > 0 && !noEditProtocols[dest.Protocol] && !strikeEdits(dest)
+}
+
+// batchReadReceipt schedules an update of the seen count of the message the read receipt
+// rmsg refers to on channel, if it's shown on dest. Returns true if rmsg is a read receipt,
+// they are never sent themselves.
+func (gw *Gateway) batchReadReceipt(rmsg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) bool {
+	if rmsg.Event != config.EventReadReceipt {
+		return false
+	}
+	if !showsReadReceipts(dest) {
+		return true
+	}
+	msgKey := gw.canonicalKey(rmsg.Protocol, rmsg.ID)
+	// only our copies of the message can be edited
+	if msgKey == "" || gw.getDestBrMsgID(msgKey, dest, channel) == nil {
+		return true
+	}
+	key := channel.ID + " " + msgKey
+	if _, ok := gw.receiptUpdates[key]; ok {
+		return true
+	}
+	u := &readReceiptUpdate{gw: gw, key: key, msgKey: msgKey, dest: dest, channel: channel}
+	u.timer = time.AfterFunc(time.Duration(dest.GetInt("ShowReadReceipts"))*time.Second, func() {
+		gw.Router.receiptsExpired <- u
+	})
+	gw.receiptUpdates[key] = u
+	return true
+}
+
+// sendReadReceipts edits the copy of the message of u to end with "(seen by N)".
+func (gw *Gateway) sendReadReceipts(u *readReceiptUpdate) {
+	if gw.receiptUpdates[u.key] != u {
+		return
+	}
+	delete(gw.receiptUpdates, u.key)
+	u.timer.Stop()
+
+	v, ok := gw.readables.Get(u.msgKey)
+	if !ok {
+		gw.logger.Debugf("message %s is gone, not showing read receipts on %s", u.msgKey, u.dest.Account)
+		return
+	}
+	src := v.(config.Message)
+	src.Text = strings.TrimRight(src.Text, " ") + fmt.Sprintf(" (seen by %d)", gw.seenCount(u.msgKey))
+	msg, ok := gw.prepareMessage(&src, u.dest, u.channel, "")
+	if !ok || msg.ID == "" {
+		return
+	}
+	if _, err := gw.send(u.dest, msg); err != nil {
+		gw.logger.Errorf("sending read receipts to %s failed: %s", u.dest.Account, err)
+	}
+}
+
+// flushReadReceipts sends all pending read receipt updates of gw.
+func (gw *Gateway) flushReadReceipts() {
+	for _, u := range gw.receiptUpdates {
+		gw.sendReadReceipts(u)
+	}
+}
@@ -0,0 +1,94 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigReadReceipts = []byte(`
+[general]
+RemoteNickFormat="{NICK}: "
+[irc.test]
+server=""
+ShowReadReceipts=60
+[discord.test]
+server=""
+ShowReadReceipts=60
+[slack.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="general"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="general"
+`)
+
+func TestReadReceiptCount(t *testing.T) {
+	r := maketestRouterWithMap(testconfigReadReceipts, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#main", ID: "42"})
+	key := gw.canonicalKey("irc", "42")
+	require.NotEmpty(t, key)
+
+	read := func(account, channel, id, userID, username string) {
+		r.relayMessage(config.Message{Event: config.EventReadReceipt, Account: account, Channel: channel, ID: id, UserID: userID, Username: username})
+	}
+	// the receipts refer to the copies of the message
+	read("discord.test", "general", "1", "100", "bob")
+	read("discord.test", "general", "1", "101", "carol")
+	// readers are only counted once
+	read("discord.test", "general", "1", "100", "bob")
+	// the same user ID on another bridge is another user
+	read("slack.test", "general", "1", "100", "")
+	read("slack.test", "general", "1", "", "dave")
+	// receipts without reader or of unknown messages are ignored
+	read("slack.test", "general", "1", "", "")
+	read("slack.test", "general", "999", "102", "erin")
+
+	assert.Equal(t, 4, gw.seenCount(key))
+	assert.Equal(t, 0, gw.seenCount("slack 999"))
+}
+
+func TestShowReadReceipts(t *testing.T) {
+	r := maketestRouterWithMap(testconfigReadReceipts, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	discord := testBridgerOf(gw, "discord.test")
+	slack := testBridgerOf(gw, "slack.test")
+
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#main", ID: "42"})
+	require.Len(t, discord.messages(), 1)
+	r.relayMessage(config.Message{Event: config.EventReadReceipt, Account: "slack.test", Channel: "general", ID: "1", UserID: "200"})
+	r.relayMessage(config.Message{Event: config.EventReadReceipt, Account: "slack.test", Channel: "general", ID: "1", UserID: "201"})
+
+	// the updates wait for the interval, the receipts themselves are never sent
+	assert.Len(t, discord.messages(), 1)
+	assert.Len(t, gw.receiptUpdates, 1)
+	assert.Len(t, slack.messages(), 1)
+
+	gw.flushReadReceipts()
+	sent := discord.messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "hello (seen by 2)", sent[1].Text)
+	assert.Equal(t, "alice: ", sent[1].Username)
+	assert.Equal(t, "1", sent[1].ID)
+	assert.Empty(t, gw.receiptUpdates)
+	// irc can't edit and slack doesn't show read receipts
+	assert.Len(t, irc.messages(), 0)
+	assert.Len(t, slack.messages(), 1)
+}
@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/42wim/matterbridge/bridge"
+)
+
+// reconnectLocks makes sure a bridge isn't reconnected by multiple goroutines at the same
+// time, eg by a manual Reconnect while it's reconnecting after a failure.
+type reconnectLocks struct {
+	sync.Mutex
+
+	locks map[string]*sync.Mutex
+}
+
+func newReconnectLocks() *reconnectLocks {
+	return &reconnectLocks{locks: make(map[string]*sync.Mutex)}
+}
+
+// get returns the lock of the bridge of account.
+func (l *reconnectLocks) get(account string) *sync.Mutex {
+	l.Lock()
+	defer l.Unlock()
+	lock, ok := l.locks[account]
+	if !ok {
+		lock = &sync.Mutex{}
+		l.locks[account] = lock
+	}
+	return lock
+}
+
+// Reconnect disconnects the bridge of account and connects it again, eg when it's stuck.
+// It returns when the bridge is connected. Reconnects of the same bridge are done one
+// after the other.
+func (gw *Gateway) Reconnect(account string) error {
+	br, ok := gw.Bridges[account]
+	if !ok {
+		return fmt.Errorf("account %s not found in gateway %s", account, gw.Name)
+	}
+	gw.reconnect(br)
+	return nil
+}
+
+// reconnect reconnects br after the other reconnects of br are done.
+func (gw *Gateway) reconnect(br *bridge.Bridge) {
+	lock := gw.Router.reconnects.get(br.Account)
+	lock.Lock()
+	defer lock.Unlock()
+	gw.reconnectBridge(br)
+}
+
+// handleReconnect reconnects the bridge of the account query parameter in the background.
+// The request needs the HealthCheckToken as bearer token. Returns 202 when the reconnect
+// is started, 401 without the token and 404 for unknown accounts.
+func (r *Router) handleReconnect(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	token := r.BridgeValues().General.HealthCheckToken
+	auth := req.Header.Get("Authorization")
+	if token == "" || subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) != 1 {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+	account := req.URL.Query().Get("account")
+	for _, gw := range r.Gateways {
+		if _, ok := gw.Bridges[account]; ok {
+			r.logger.Infof("Reconnect of %s requested", account)
+			go gw.Reconnect(account) //nolint:errcheck
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte("reconnecting " + account)) //nolint:errcheck
+			return
+		}
+	}
+	http.Error(w, "unknown account "+account, http.StatusNotFound)
+}
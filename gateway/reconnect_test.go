@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/stretchr/testify/assert"
+)
+
+// connectCounter counts the calls to Connect and the maximum of concurrent calls.
+type connectCounter struct {
+	testBridger
+
+	mu         sync.Mutex
+	active     int
+	maxActive  int
+	connects   int
+	disconnect int
+}
+
+func (b *connectCounter) Connect() error {
+	b.mu.Lock()
+	b.active++
+	b.connects++
+	if b.active > b.maxActive {
+		b.maxActive = b.active
+	}
+	b.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	b.mu.Lock()
+	b.active--
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *connectCounter) Disconnect() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.disconnect++
+	return nil
+}
+
+// withoutReconnectDelay disables the reconnect delays and returns a func restoring them.
+func withoutReconnectDelay() func() {
+	delay, retry := reconnectDelay, reconnectRetryDelay
+	reconnectDelay, reconnectRetryDelay = 0, 0
+	return func() { reconnectDelay, reconnectRetryDelay = delay, retry }
+}
+
+func TestReconnectSerialized(t *testing.T) {
+	defer withoutReconnectDelay()()
+	irc := &connectCounter{}
+	r := maketestRouterWithMap(testconfigUpdate, map[string]bridge.Factory{
+		"api":     newTestBridger,
+		"discord": newTestBridger,
+		"irc":     func(*bridge.Config) bridge.Bridger { return irc },
+	})
+	gw := r.Gateways["main"]
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, gw.Reconnect("irc.test"))
+		}()
+	}
+	// a reconnect after a failure uses the same lock
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		gw.reconnect(gw.Bridges["irc.test"])
+	}()
+	wg.Wait()
+
+	assert.Equal(t, 4, irc.connects)
+	assert.Equal(t, 4, irc.disconnect)
+	assert.Equal(t, 1, irc.maxActive)
+	state, ok := r.connections.get("irc.test")
+	assert.True(t, ok)
+	assert.Equal(t, StateConnected, state.State)
+
+	assert.EqualError(t, gw.Reconnect("irc.unknown"), "account irc.unknown not found in gateway main")
+}
+
+var testconfigReconnect = []byte(`
+[general]
+HealthCheckReconnect=true
+HealthCheckToken="secret"
+[irc.test]
+server=""
+[slack.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+`)
+
+func TestHandleReconnect(t *testing.T) {
+	defer withoutReconnectDelay()()
+	irc := &connectCounter{}
+	r := maketestRouterWithMap(testconfigReconnect, map[string]bridge.Factory{
+		"slack": newTestBridger,
+		"irc":   func(*bridge.Config) bridge.Bridger { return irc },
+	})
+	mux := r.healthCheckMux()
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+	assert.Equal(t, http.StatusMethodNotAllowed, do("GET", "/reconnect?account=irc.test", "secret").Code)
+	assert.Equal(t, http.StatusUnauthorized, do("POST", "/reconnect?account=irc.test", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, do("POST", "/reconnect?account=irc.test", "wrong").Code)
+	assert.Equal(t, http.StatusNotFound, do("POST", "/reconnect?account=irc.unknown", "secret").Code)
+	assert.Equal(t, http.StatusAccepted, do("POST", "/reconnect?account=irc.test", "secret").Code)
+	waitFor(t, func() bool {
+		irc.mu.Lock()
+		defer irc.mu.Unlock()
+		return irc.connects == 1
+	})
+}
+
+func TestHandleReconnectWithoutToken(t *testing.T) {
+	r := maketestRouterWithMap(bytes.Replace(testconfigReconnect, []byte(`HealthCheckToken="secret"`), nil, 1), testBridgeMap)
+	rec := httptest.NewRecorder()
+	r.healthCheckMux().ServeHTTP(rec, httptest.NewRequest("POST", "/reconnect?account=irc.test", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
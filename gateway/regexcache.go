@@ -0,0 +1,33 @@
+package gateway
+
+import (
+	"regexp"
+	"sync"
+)
+
+// regexCache keeps compiled regular expressions from the configuration around so they
+// don't get recompiled for every message.
+type regexCache struct {
+	sync.Mutex
+
+	regexps map[string]*regexp.Regexp
+}
+
+func newRegexCache() *regexCache {
+	return &regexCache{regexps: make(map[string]*regexp.Regexp)}
+}
+
+// compile returns the compiled regexp of expr. Invalid expressions aren't cached.
+func (rc *regexCache) compile(expr string) (*regexp.Regexp, error) {
+	rc.Lock()
+	defer rc.Unlock()
+	if re, ok := rc.regexps[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	rc.regexps[expr] = re
+	return re, nil
+}
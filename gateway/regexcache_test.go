@@ -0,0 +1,23 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegexCacheCompile(t *testing.T) {
+	rc := newRegexCache()
+	re, err := rc.compile("^!")
+	require.NoError(t, err)
+	assert.True(t, re.MatchString("!help"))
+
+	again, err := rc.compile("^!")
+	require.NoError(t, err)
+	assert.True(t, re == again, "regexp should be cached")
+
+	_, err = rc.compile("(")
+	assert.Error(t, err)
+	assert.Len(t, rc.regexps, 1)
+}
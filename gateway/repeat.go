@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// repeatKey identifies a text sent by a user on a channel for RepeatSuppressInterval.
+type repeatKey struct {
+	user    string
+	channel string
+	hash    [sha256.Size]byte
+}
+
+// repeatKeyOf returns the key of msg and the RepeatSuppressInterval of its bridge, or
+// false if repeats of msg aren't suppressed.
+func (gw *Gateway) repeatKeyOf(msg *config.Message) (repeatKey, time.Duration, bool) {
+	interval := gw.Bridges[msg.Account].GetInt("RepeatSuppressInterval")
+	if interval <= 0 || msg.Text == "" || msg.Event != "" && msg.Event != config.EventUserAction {
+		return repeatKey{}, 0, false
+	}
+	user := msg.UserID
+	if user == "" {
+		user = msg.Username
+	}
+	key := repeatKey{user: user, channel: getChannelID(msg), hash: sha256.Sum256([]byte(msg.Text))}
+	return key, time.Duration(interval) * time.Second, true
+}
+
+// suppressRepeat returns true if RepeatSuppressInterval is set on the bridge of msg and
+// the same user sent the same text on its channel within the interval. It only checks,
+// rememberRepeat records msg once it is relayed, so a suppressed repeat doesn't extend
+// the interval.
+func (gw *Gateway) suppressRepeat(msg *config.Message) bool {
+	key, interval, ok := gw.repeatKeyOf(msg)
+	if !ok {
+		return false
+	}
+	if v, ok := gw.repeats.Get(key); ok && gw.now().Sub(v.(time.Time)) < interval {
+		gw.logger.Debugf("suppressing repeated message from %s on %s", key.user, msg.Account)
+		return true
+	}
+	return false
+}
+
+// rememberRepeat records that msg is relayed, for suppressRepeat.
+func (gw *Gateway) rememberRepeat(msg *config.Message) {
+	if key, _, ok := gw.repeatKeyOf(msg); ok {
+		gw.repeats.Add(key, gw.now())
+	}
+}
@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepeatSuppressInterval(t *testing.T) {
+	r := maketestRouterWithMap(testconfigUpdate, testBridgeMap)
+	gw := r.Gateways["main"]
+	br := gw.Bridges["discord.test"]
+	cfg := br.Config
+	defer func() { br.Config = cfg }()
+	br.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"discord.test.RepeatSuppressInterval": 60}}
+	irc := testBridgerOf(gw, "irc.test")
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	gw.now = func() time.Time { return now }
+
+	spam := config.Message{Text: "buy now", Username: "alice", UserID: "a1", Account: "discord.test", Channel: "general"}
+	r.relayMessage(spam)
+	assert.Len(t, irc.messages(), 1)
+
+	// within the interval
+	now = now.Add(30 * time.Second)
+	r.relayMessage(spam)
+	assert.Len(t, irc.messages(), 1)
+
+	// other texts and other users aren't suppressed
+	r.relayMessage(config.Message{Text: "buy later", Username: "alice", UserID: "a1", Account: "discord.test", Channel: "general"})
+	r.relayMessage(config.Message{Text: "buy now", Username: "bob", UserID: "b1", Account: "discord.test", Channel: "general"})
+	assert.Len(t, irc.messages(), 3)
+
+	// a suppressed repeat doesn't extend the interval
+	now = now.Add(31 * time.Second)
+	r.relayMessage(spam)
+	assert.Len(t, irc.messages(), 4)
+
+	// checking a message without relaying it doesn't record it
+	now = now.Add(time.Hour)
+	assert.False(t, gw.ignoreMessage(&spam))
+	assert.False(t, gw.ignoreMessage(&spam))
+	r.relayMessage(spam)
+	assert.Len(t, irc.messages(), 5)
+
+	// only the bridge with RepeatSuppressInterval is filtered
+	discord := testBridgerOf(gw, "discord.test")
+	r.relayMessage(config.Message{Text: "hi", Username: "carol", Account: "irc.test", Channel: "#main"})
+	r.relayMessage(config.Message{Text: "hi", Username: "carol", Account: "irc.test", Channel: "#main"})
+	assert.Len(t, discord.messages(), 2)
+}
@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// defaultRepeatedNickWindow is the RepeatedNickWindow (in seconds) if it isn't set.
+const defaultRepeatedNickWindow = 60
+
+// lastNick is the formatted nick of the last message sent to a destination channel.
+type lastNick struct {
+	nick string
+	at   time.Time
+}
+
+type lastNicks struct {
+	sync.Mutex
+	channels map[string]lastNick
+}
+
+func newLastNicks() *lastNicks {
+	return &lastNicks{channels: make(map[string]lastNick)}
+}
+
+// hidesRepeatedNick returns true if the nick of msg is hidden on dest when it repeats.
+func hidesRepeatedNick(msg *config.Message, nick string, dest *bridge.Bridge) bool {
+	return dest.GetBool("HideRepeatedNick") && nick != "" && (msg.Event == "" || msg.Event == config.EventUserAction)
+}
+
+// hideRepeatedNick returns the formatted nick of msg for channel on dest, or an empty
+// nick if HideRepeatedNick is set on dest and the previous message sent to channel was
+// from the same nick within RepeatedNickWindow. Actions always keep their nick.
+func (gw *Gateway) hideRepeatedNick(msg *config.Message, nick string, dest *bridge.Bridge, channel *config.ChannelInfo) string {
+	if !hidesRepeatedNick(msg, nick, dest) || msg.Event != "" {
+		return nick
+	}
+	window := dest.GetInt("RepeatedNickWindow")
+	if window <= 0 {
+		window = defaultRepeatedNickWindow
+	}
+	gw.lastNicks.Lock()
+	defer gw.lastNicks.Unlock()
+	last, ok := gw.lastNicks.channels[channel.ID]
+	if ok && last.nick == nick && gw.now().Sub(last.at) < time.Duration(window)*time.Second {
+		return ""
+	}
+	return nick
+}
+
+// rememberLastNick records that a message from the formatted nick was sent to channel on
+// dest, for hideRepeatedNick. Only called once the message is sent.
+func (gw *Gateway) rememberLastNick(msg *config.Message, nick string, dest *bridge.Bridge, channel *config.ChannelInfo) {
+	if !hidesRepeatedNick(msg, nick, dest) {
+		return
+	}
+	gw.lastNicks.Lock()
+	defer gw.lastNicks.Unlock()
+	gw.lastNicks.channels[channel.ID] = lastNick{nick: nick, at: gw.now()}
+}
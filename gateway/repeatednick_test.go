@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigRepeatedNick = []byte(`
+[general]
+RemoteNickFormat="[{NICK}] "
+[irc.test]
+server=""
+HideRepeatedNick=true
+RepeatedNickWindow=30
+[slack.test]
+server=""
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+`)
+
+func TestHideRepeatedNick(t *testing.T) {
+	r := maketestRouterWithMap(testconfigRepeatedNick, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	slack := testBridgerOf(gw, "slack.test")
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	gw.now = func() time.Time { return now }
+
+	send := func(nick, account, text string) {
+		r.relayMessage(config.Message{Text: text, Username: nick, Account: account, Channel: "main"})
+		now = now.Add(time.Second)
+	}
+	send("alice", "slack.test", "one")
+	send("alice", "slack.test", "two")
+	send("alice", "slack.test", "three")
+	// interleaved senders
+	send("bob", "slack.test", "four")
+	send("alice", "slack.test", "five")
+	// the same nick from another bridge
+	send("alice", "discord.test", "six")
+	send("alice", "discord.test", "seven")
+	// after the window
+	now = now.Add(time.Minute)
+	send("alice", "discord.test", "eight")
+	// actions keep their nick
+	r.relayMessage(config.Message{Text: "waves", Username: "alice", Account: "discord.test", Channel: "main", Event: config.EventUserAction})
+
+	var usernames []string
+	for _, msg := range irc.messages() {
+		usernames = append(usernames, msg.Username)
+	}
+	assert.Equal(t, []string{"[alice] ", "", "", "[bob] ", "[alice] ", "", "", "[alice] ", "[alice] "}, usernames)
+
+	// destinations without HideRepeatedNick always get the nick
+	for _, msg := range slack.messages() {
+		assert.Equal(t, "[alice] ", msg.Username)
+	}
+
+	// a message that failed to send doesn't hide the nick of the next one
+	now = now.Add(time.Minute)
+	irc.sendErr = errors.New("irc is down")
+	send("carol", "slack.test", "lost")
+	irc.sendErr = nil
+	send("carol", "slack.test", "sent")
+	sent := irc.messages()
+	assert.Equal(t, "[carol] ", sent[len(sent)-1].Username)
+}
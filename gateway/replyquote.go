@@ -0,0 +1,142 @@
+package gateway
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+const defaultTelegramQuoteFormat = "{MESSAGE} (re @{QUOTENICK}: {QUOTEMESSAGE})"
+
+// The ReplyStyle values other than the default "thread", which keeps the ParentID.
+const (
+	replyStyleQuote   = "quote"
+	replyStyleMention = "mention"
+)
+
+// replyQuoteLength is the maximum length (in runes) of the parent text quoted by ReplyStyle.
+const replyQuoteLength = 100
+
+// stripReplyQuote returns the text of msg without the quote of the message it
+// replies to. Telegram adds the quote using its QuoteFormat, other bridges may
+// start the text with a markdown quote block.
+func (gw *Gateway) stripReplyQuote(msg *config.Message) string {
+	text := msg.Text
+	if msg.Protocol == "telegram" {
+		br := gw.Router.getBridge(msg.Account)
+		if !br.GetBool("QuoteDisable") {
+			format := br.GetString("QuoteFormat")
+			if format == "" {
+				format = defaultTelegramQuoteFormat
+			}
+			text = gw.stripQuoteFormat(text, format)
+		}
+	}
+	return stripQuoteBlock(text)
+}
+
+// quotesReplies returns true if the bridges of protocol quote the parent in the text of
+// replies. Their ParentID is only used to thread or restyle the reply, a reply to an unknown
+// parent is sent as a normal message instead of being marked msg-parent-not-found.
+func quotesReplies(protocol string) bool {
+	return protocol == "telegram"
+}
+
+// stripQuoteFormat returns the {MESSAGE} part of text if text matches format.
+func (gw *Gateway) stripQuoteFormat(text, format string) string {
+	message := regexp.QuoteMeta("{MESSAGE}")
+	expr := regexp.QuoteMeta(format)
+	if !strings.Contains(expr, message) {
+		return text
+	}
+	expr = strings.Replace(expr, message, "(.*?)", 1)
+	expr = strings.Replace(expr, message, ".*?", -1)
+	expr = strings.Replace(expr, regexp.QuoteMeta("{QUOTENICK}"), ".*?", -1)
+	expr = strings.Replace(expr, regexp.QuoteMeta("{QUOTEMESSAGE}"), ".*", -1)
+	re, err := gw.regexps.compile("(?s)^" + expr + "$")
+	if err != nil {
+		gw.logger.Errorf("invalid QuoteFormat %#v: %s", format, err)
+		return text
+	}
+	res := re.FindStringSubmatch(text)
+	if res == nil || strings.TrimSpace(res[1]) == "" {
+		return text
+	}
+	return res[1]
+}
+
+// stripQuoteBlock removes the lines starting with > at the start of text.
+// text is kept as is if it only consists of a quote.
+func stripQuoteBlock(text string) string {
+	lines := strings.Split(text, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], ">") {
+		i++
+	}
+	if i == 0 {
+		return text
+	}
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	if i == len(lines) {
+		return text
+	}
+	return strings.Join(lines[i:], "\n")
+}
+
+// rendersReplies returns true if the ReplyStyle of dest renders replies in their text,
+// which needs the parent message like PreserveThreading.
+func rendersReplies(dest *bridge.Bridge) bool {
+	style := dest.GetString("ReplyStyle")
+	return style == replyStyleQuote || style == replyStyleMention
+}
+
+// applyReplyStyle renders the reply msg for dest as set by its ReplyStyle. "quote" starts
+// the text with a quote block of the text of the parent message, "mention" with the nick
+// of its sender. Both drop the ParentID, so the reply isn't threaded. Replies to unknown
+// parents are kept as is.
+func (gw *Gateway) applyReplyStyle(rmsg *config.Message, msg *config.Message, dest *bridge.Bridge, canonicalParentMsgID string) {
+	if !rendersReplies(dest) || canonicalParentMsgID == "" || msg.Event != "" && msg.Event != config.EventUserAction {
+		return
+	}
+	parent := gw.getParentBrMsgID(rmsg.Protocol, canonicalParentMsgID)
+	if parent == nil {
+		return
+	}
+	switch dest.GetString("ReplyStyle") {
+	case replyStyleQuote:
+		if parent.Text == "" {
+			return
+		}
+		msg.Text = quoteText(truncateText(parent.Text, replyQuoteLength, defaultTruncateSuffix)) + "\n" + msg.Text
+	case replyStyleMention:
+		if parent.Username == "" {
+			return
+		}
+		msg.Text = "@" + parent.Username + " " + msg.Text
+	}
+	msg.ParentID = ""
+}
+
+// getParentBrMsgID returns a copy of the canonical message mID that knows its text and
+// sender. Canonical IDs of messages from another protocol than protocol keep their protocol.
+func (gw *Gateway) getParentBrMsgID(protocol, mID string) *BrMsgID {
+	IDs, ok := gw.Messages.Get(protocol + " " + mID)
+	if !ok {
+		IDs, _ = gw.Messages.Get(mID)
+	}
+	for _, id := range IDs {
+		if id.Text != "" || id.Username != "" {
+			return id
+		}
+	}
+	return nil
+}
+
+// quoteText returns text as a markdown quote block.
+func quoteText(text string) string {
+	return "> " + strings.Replace(text, "\n", "\n> ", -1)
+}
@@ -0,0 +1,182 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigReplyQuote = []byte(`
+[telegram.test]
+server=""
+[telegram.custom]
+server=""
+QuoteFormat="> {QUOTENICK}: {QUOTEMESSAGE}\n{MESSAGE}"
+[slack.test]
+server=""
+PreserveThreading=true
+StripReplyQuote=true
+[xmpp.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="telegram.test"
+    channel="-100"
+
+    [[gateway.inout]]
+    account="telegram.custom"
+    channel="-200"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="xmpp.test"
+    channel="main"
+`)
+
+func TestStripReplyQuote(t *testing.T) {
+	r := maketestRouterWithMap(testconfigReplyQuote, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	msgTests := map[string]struct {
+		input  *config.Message
+		output string
+	}{
+		"telegram default format": {
+			input:  &config.Message{Text: "yes (re @alice: are you there?)", Account: "telegram.test", Protocol: "telegram"},
+			output: "yes",
+		},
+		"telegram quote with parenthesis": {
+			input:  &config.Message{Text: "sure (re @alice: lunch (noon)?)", Account: "telegram.test", Protocol: "telegram"},
+			output: "sure",
+		},
+		"telegram multiline": {
+			input:  &config.Message{Text: "line 1\nline 2 (re @alice: question\nwith newline)", Account: "telegram.test", Protocol: "telegram"},
+			output: "line 1\nline 2",
+		},
+		"telegram no quote": {
+			input:  &config.Message{Text: "just a message", Account: "telegram.test", Protocol: "telegram"},
+			output: "just a message",
+		},
+		"telegram custom format": {
+			input:  &config.Message{Text: "> alice: are you there?\nyes", Account: "telegram.custom", Protocol: "telegram"},
+			output: "yes",
+		},
+		"quote block": {
+			input:  &config.Message{Text: "> <alice> are you there?\n> really\n\nyes", Account: "xmpp.test", Protocol: "xmpp"},
+			output: "yes",
+		},
+		"only quote": {
+			input:  &config.Message{Text: "> quote", Account: "xmpp.test", Protocol: "xmpp"},
+			output: "> quote",
+		},
+		"quote later in message": {
+			input:  &config.Message{Text: "yes\n> are you there?", Account: "xmpp.test", Protocol: "xmpp"},
+			output: "yes\n> are you there?",
+		},
+	}
+	for testname, testcase := range msgTests {
+		assert.Equalf(t, testcase.output, gw.stripReplyQuote(testcase.input), "case '%s' failed", testname)
+	}
+}
+
+func TestSendMessageStripReplyQuote(t *testing.T) {
+	r := maketestRouterWithMap(testconfigReplyQuote, testBridgeMap)
+	gw := r.Gateways["main"]
+	slack := testBridgerOf(gw, "slack.test")
+
+	r.relayMessage(config.Message{Text: "are you there?", Username: "alice", Account: "telegram.test", Channel: "-100", ID: "1"})
+	r.relayMessage(config.Message{Text: "yes (re @alice: are you there?)", Username: "bob", Account: "telegram.test", Channel: "-100", ID: "2", ParentID: "1"})
+	// not threaded on slack, the quote is kept
+	r.relayMessage(config.Message{Text: "no (re @carol: unknown)", Username: "bob", Account: "telegram.test", Channel: "-100", ID: "3", ParentID: "0"})
+
+	sent := slack.messages()
+	assert.Len(t, sent, 3)
+	assert.Equal(t, "1", sent[1].ParentID)
+	assert.Equal(t, "yes", sent[1].Text)
+	// the quote already shows what it replies to
+	assert.Empty(t, sent[2].ParentID)
+	assert.Equal(t, "no (re @carol: unknown)", sent[2].Text)
+
+	// destinations without threading get the quoted replies as normal messages
+	for _, msg := range testBridgerOf(gw, "xmpp.test").messages() {
+		assert.Empty(t, msg.ParentID)
+	}
+	assert.Equal(t, "yes (re @alice: are you there?)", testBridgerOf(gw, "xmpp.test").messages()[1].Text)
+}
+
+var testconfigReplyStyle = []byte(`
+[slack.test]
+server=""
+[xmpp.thread]
+server=""
+PreserveThreading=true
+ReplyStyle="thread"
+[xmpp.quote]
+server=""
+ReplyStyle="quote"
+[xmpp.mention]
+server=""
+ReplyStyle="mention"
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="xmpp.thread"
+    channel="thread"
+
+    [[gateway.inout]]
+    account="xmpp.quote"
+    channel="quote"
+
+    [[gateway.inout]]
+    account="xmpp.mention"
+    channel="mention"
+`)
+
+func TestReplyStyle(t *testing.T) {
+	r := maketestRouterWithMap(testconfigReplyStyle, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	r.relayMessage(config.Message{Text: "are you there?\nanyone?", Username: "alice", Account: "slack.test", Channel: "main", ID: "1"})
+	r.relayMessage(config.Message{Text: "yes", Username: "bob", Account: "slack.test", Channel: "main", ID: "2", ParentID: "1"})
+	r.relayMessage(config.Message{Text: "who?", Username: "bob", Account: "slack.test", Channel: "main", ID: "3", ParentID: "0"})
+
+	msgTests := map[string]struct {
+		parentID string
+		text     string
+	}{
+		"xmpp.thread":  {parentID: "1", text: "yes"},
+		"xmpp.quote":   {text: "> are you there?\n> anyone?\nyes"},
+		"xmpp.mention": {text: "@alice yes"},
+	}
+	for account, testcase := range msgTests {
+		sent := testBridgerOf(gw, account).messages()
+		if !assert.Lenf(t, sent, 3, "case '%s' failed", account) {
+			continue
+		}
+		assert.Equalf(t, testcase.parentID, sent[1].ParentID, "case '%s' failed", account)
+		assert.Equalf(t, testcase.text, sent[1].Text, "case '%s' failed", account)
+		// unknown parents are kept as is
+		assert.Equalf(t, "msg-parent-not-found", sent[2].ParentID, "case '%s' failed", account)
+		assert.Equalf(t, "who?", sent[2].Text, "case '%s' failed", account)
+	}
+
+	// replies to a message bridged from another protocol
+	r.relayMessage(config.Message{Text: "sure", Username: "carol", Account: "xmpp.thread", Channel: "thread", ID: "x1", ParentID: "1"})
+	sent := testBridgerOf(gw, "xmpp.quote").messages()
+	assert.Equal(t, "> are you there?\n> anyone?\nsure", sent[len(sent)-1].Text)
+}
@@ -20,7 +20,22 @@ type Router struct {
 	Message          chan config.Message
 	MattermostPlugin chan config.Message
 
-	logger *logrus.Entry
+	logger           *logrus.Entry
+	coalescers       map[string]*coalescer
+	coalesceTimeout  chan coalesceTimeout
+	shutdown         chan shutdownRequest
+	activeHoursStart chan *Gateway
+	joinLeaveExpired chan *joinLeaveBatch
+	reactionsExpired chan *reactionBatch
+	typingExpired    chan *pendingTyping
+	receiptsExpired  chan *readReceiptUpdate
+	delayedExpired   chan *delayedMessage
+	delayed          map[string]*delayedMessage
+	connections      *connectionTracker
+	reconnects       *reconnectLocks
+	loops            *loopDetector
+	queueStores      *queueStores
+	abandonedSends   *abandonedSends
 }
 
 // NewRouter initializes a new Matterbridge router for the specified configuration and
@@ -35,6 +50,21 @@ func NewRouter(rootLogger *logrus.Logger, cfg config.Config, bridgeMap map[strin
 		MattermostPlugin: make(chan config.Message),
 		Gateways:         make(map[string]*Gateway),
 		logger:           logger,
+		coalescers:       make(map[string]*coalescer),
+		coalesceTimeout:  make(chan coalesceTimeout),
+		shutdown:         make(chan shutdownRequest),
+		activeHoursStart: make(chan *Gateway),
+		joinLeaveExpired: make(chan *joinLeaveBatch),
+		reactionsExpired: make(chan *reactionBatch),
+		typingExpired:    make(chan *pendingTyping),
+		receiptsExpired:  make(chan *readReceiptUpdate),
+		delayedExpired:   make(chan *delayedMessage),
+		delayed:          make(map[string]*delayedMessage),
+		connections:      newConnectionTracker(),
+		reconnects:       newReconnectLocks(),
+		loops:            newLoopDetector(),
+		queueStores:      newQueueStores(),
+		abandonedSends:   newAbandonedSends(),
 	}
 	sgw := samechannel.New(cfg)
 	gwconfigs := append(sgw.GetConfig(), cfg.BridgeValues().Gateway...)
@@ -50,6 +80,9 @@ func NewRouter(rootLogger *logrus.Logger, cfg config.Config, bridgeMap map[strin
 		if _, ok := r.Gateways[entry.Name]; ok {
 			return nil, fmt.Errorf("Gateway with name %s already exists", entry.Name)
 		}
+		if err := validateTransformOrder(entry.TransformOrder); err != nil {
+			return nil, fmt.Errorf("gateway %s: %s", entry.Name, err)
+		}
 		r.Gateways[entry.Name] = New(rootLogger, entry, r)
 	}
 	return r, nil
@@ -71,11 +104,23 @@ func (r *Router) Start() error {
 			m[br.Account] = br
 		}
 	}
+	for _, br := range m {
+		if err := validateTimestampTimezone(br); err != nil {
+			return err
+		}
+	}
+	for _, br := range m {
+		r.connections.set(br.Account, StateConnecting, nil)
+	}
+	if addr := r.BridgeValues().General.HealthCheckAddr; addr != "" {
+		r.startHealthCheck(addr)
+	}
 	for _, br := range m {
 		r.logger.Infof("Starting bridge: %s ", br.Account)
 		err := br.Connect()
 		if err != nil {
 			e := fmt.Errorf("Bridge %s failed to start: %v", br.Account, err)
+			r.connections.set(br.Account, StateDisconnected, e)
 			if r.disableBridge(br, e) {
 				continue
 			}
@@ -84,11 +129,13 @@ func (r *Router) Start() error {
 		err = br.JoinChannels()
 		if err != nil {
 			e := fmt.Errorf("Bridge %s failed to join channel: %v", br.Account, err)
+			r.connections.set(br.Account, StateDisconnected, e)
 			if r.disableBridge(br, e) {
 				continue
 			}
 			return e
 		}
+		r.connections.set(br.Account, StateConnected, nil)
 	}
 	// remove unused bridges
 	for _, gw := range r.Gateways {
@@ -99,6 +146,7 @@ func (r *Router) Start() error {
 			}
 		}
 	}
+	r.restoreSendQueues()
 	go r.handleReceive()
 	//go r.updateChannelMembers()
 	return nil
@@ -126,46 +174,127 @@ func (r *Router) getBridge(account string) *bridge.Bridge {
 }
 
 func (r *Router) handleReceive() {
-	for msg := range r.Message {
-		msg := msg // scopelint
-		r.handleEventGetChannelMembers(&msg)
-		r.handleEventFailure(&msg)
-		r.handleEventRejoinChannels(&msg)
+	for {
+		var msgs []config.Message
+		select {
+		case msg, ok := <-r.Message:
+			if !ok {
+				return
+			}
+			msgs = r.coalesce(msg)
+		case t := <-r.coalesceTimeout:
+			msgs = t.c.expire(t.gen)
+		case gw := <-r.activeHoursStart:
+			gw.flushOutsideActiveHours()
+		case b := <-r.joinLeaveExpired:
+			b.gw.sendJoinLeave(b)
+		case b := <-r.reactionsExpired:
+			b.gw.sendReactionSummary(b)
+		case t := <-r.typingExpired:
+			t.gw.sendTyping(t)
+		case u := <-r.receiptsExpired:
+			u.gw.sendReadReceipts(u)
+		case d := <-r.delayedExpired:
+			r.forwardDelayed(d)
+		case req := <-r.shutdown:
+			r.drain(req.gw)
+			r.flushDelayed()
+			req.gw.flushJoinLeave()
+			req.gw.flushReactionSummaries()
+			req.gw.cancelTyping()
+			req.gw.flushReadReceipts()
+			req.gw.flushSendQueues()
+			req.gw.flushBatches()
+			req.gw.close()
+			close(req.done)
+		}
+		for _, msg := range msgs {
+			r.relayMessage(msg)
+		}
+	}
+}
 
-		// Set message protocol based on the account it came from
-		msg.Protocol = r.getBridge(msg.Account).Protocol
+// relayMessage handles the events of msg and sends it to all matching gateways, after
+// the ForwardDelay of its bridge.
+func (r *Router) relayMessage(msg config.Message) {
+	r.handleEventGetChannelMembers(&msg)
+	r.handleEventFailure(&msg)
+	r.handleEventRejoinChannels(&msg)
 
-		filesHandled := false
-		for _, gw := range r.Gateways {
-			// record all the message ID's of the different bridges
-			var msgIDs []*BrMsgID
-			if gw.ignoreMessage(&msg) {
-				continue
-			}
-			msg.Timestamp = time.Now()
-			gw.modifyMessage(&msg)
-			if !filesHandled {
-				gw.handleFiles(&msg)
-				filesHandled = true
-			}
-			for _, br := range gw.Bridges {
-				msgIDs = append(msgIDs, gw.handleMessage(&msg, br)...)
-			}
+	// Set message protocol based on the account it came from
+	msg.Protocol = r.getBridge(msg.Account).Protocol
+	if r.isLoop(&msg) {
+		return
+	}
+	if r.delayForward(&msg) {
+		return
+	}
+	r.forwardMessage(msg)
+}
 
-			if msg.ID != "" {
-				_, exists := gw.Messages.Get(msg.Protocol + " " + msg.ID)
+// forwardMessage sends msg to all matching gateways.
+func (r *Router) forwardMessage(msg config.Message) {
+	filesHandled := false
+	var relayed []*Gateway
+	for _, gw := range r.Gateways {
+		if gw.isClosed() {
+			continue
+		}
+		if gw.ignoreMessage(&msg) {
+			continue
+		}
+		if gw.holdOutsideActiveHours(&msg) {
+			continue
+		}
+		gw.relayMessage(&msg, !filesHandled)
+		filesHandled = true
+		relayed = append(relayed, gw)
+	}
+	r.handleKeywordRoutes(&msg, relayed)
+}
 
-				// Only add the message ID if it doesn't already exist
-				//
-				// For some bridges we always add/update the message ID.
-				// This is necessary as msgIDs will change if a bridge returns
-				// a different ID in response to edits.
-				if !exists || msg.Protocol == "discord" {
-					gw.Messages.Add(msg.Protocol+" "+msg.ID, msgIDs)
-				}
-			}
+// relayMessage sends msg to all bridges of gw. The files of msg are only handled
+// when handleFiles is true, this needs to happen only once for all gateways.
+func (gw *Gateway) relayMessage(msg *config.Message, handleFiles bool) {
+	// record all the message ID's of the different bridges
+	var msgIDs []*BrMsgID
+	// keep the timestamp of bridges that set the original time of the message
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	gw.rememberRepeat(msg)
+	gw.modifyMessage(msg)
+	gw.rememberNick(msg)
+	gw.countMessage(msg)
+	gw.countReadReceipt(msg)
+	gw.rememberReadable(msg)
+	gw.archiveMessage(msg)
+	if handleFiles {
+		gw.handleFiles(msg)
+	}
+	// the queues of destinations with a SmoothingRate may add IDs while we're sending
+	_, exists := gw.Messages.Get(msg.Protocol + " " + msg.ID)
+	for _, br := range gw.Bridges {
+		msgIDs = append(msgIDs, gw.handleMessage(msg, br)...)
+	}
+
+	// reactions, pins, updates, read receipts and edits to empty refer to an existing message,
+	// they're not a new message. With ParallelSend the queues record the message IDs once they're sent
+	if msg.ID != "" && !isReaction(msg) && !isPin(msg) && msg.Event != config.EventMsgUpdate &&
+		msg.Event != config.EventReadReceipt && !gw.parallelSend() && !gw.isEditedToEmpty(msg) {
+		// Only add the message ID if it doesn't already exist
+		//
+		// For some bridges we always add/update the message ID.
+		// This is necessary as msgIDs will change if a bridge returns
+		// a different ID in response to edits.
+		if !exists || msg.Protocol == "discord" {
+			gw.addMsgID(msg, msgIDs...)
 		}
 	}
+	// updates are sent as an edit, which some destinations (discord webhooks) resend with a new ID
+	if exists && msg.Event == config.EventMsgUpdate && !gw.parallelSend() {
+		gw.updateMsgIDs(msg, msgIDs...)
+	}
 }
 
 // updateChannelMembers sends every minute an GetChannelMembers event to all bridges.
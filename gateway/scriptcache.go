@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/42wim/matterbridge/internal"
+	"github.com/d5/tengo/v2"
+	"github.com/d5/tengo/v2/stdlib"
+)
+
+// scriptCache keeps compiled tengo scripts around so they only get recompiled
+// when the modification time of the script file changes.
+type scriptCache struct {
+	sync.Mutex
+
+	scripts map[string]*cachedScript
+}
+
+type cachedScript struct {
+	modTime  time.Time
+	compiled *tengo.Compiled
+}
+
+func newScriptCache() *scriptCache {
+	return &scriptCache{scripts: make(map[string]*cachedScript)}
+}
+
+// get returns a compiled copy of the script in filename with vars set as its global variables.
+func (sc *scriptCache) get(filename string, vars map[string]interface{}) (*tengo.Compiled, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	return sc.compile(filename, fi.ModTime(), vars, func() ([]byte, error) {
+		return ioutil.ReadFile(filename)
+	})
+}
+
+// getAsset is like get but uses the script compiled into the binary as internal asset.
+func (sc *scriptCache) getAsset(name string, vars map[string]interface{}) (*tengo.Compiled, error) {
+	return sc.compile("asset:"+name, time.Time{}, vars, func() ([]byte, error) {
+		return internal.Asset(name)
+	})
+}
+
+func (sc *scriptCache) compile(key string, modTime time.Time, vars map[string]interface{}, read func() ([]byte, error)) (*tengo.Compiled, error) {
+	// the same script can be used with different variables (eg InMessage and OutMessage)
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	key += "|" + strings.Join(names, ",")
+
+	sc.Lock()
+	cached, ok := sc.scripts[key]
+	sc.Unlock()
+	if !ok || !cached.modTime.Equal(modTime) {
+		res, err := read()
+		if err != nil {
+			return nil, err
+		}
+		s := tengo.NewScript(res)
+		s.SetImports(stdlib.GetModuleMap(stdlib.AllModuleNames()...))
+		for _, name := range names {
+			_ = s.Add(name, vars[name])
+		}
+		c, err := s.Compile()
+		if err != nil {
+			return nil, err
+		}
+		cached = &cachedScript{modTime: modTime, compiled: c}
+		sc.Lock()
+		sc.scripts[key] = cached
+		sc.Unlock()
+	}
+	c := cached.compiled.Clone()
+	for _, name := range names {
+		if err := c.Set(name, vars[name]); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// reset removes all compiled scripts from the cache.
+func (sc *scriptCache) reset() {
+	sc.Lock()
+	sc.scripts = make(map[string]*cachedScript)
+	sc.Unlock()
+}
+
+// ReloadScripts makes sure all tengo scripts are read and compiled again
+// the next time they are used.
+func (gw *Gateway) ReloadScripts() {
+	gw.scripts.reset()
+}
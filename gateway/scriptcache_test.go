@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScript(t *testing.T, filename, script string, modTime time.Time) {
+	require.NoError(t, ioutil.WriteFile(filename, []byte(script), 0600))
+	require.NoError(t, os.Chtimes(filename, modTime, modTime))
+}
+
+func TestScriptCacheRecompile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matterbridge-tengo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.tengo")
+	modTime := time.Now().Add(-time.Hour)
+	scripts := newScriptCache()
+
+	writeScript(t, filename, `msgText="first"`, modTime)
+	msg := &config.Message{Text: "text"}
+	require.NoError(t, modifyMessageTengo(scripts, filename, msg, nil))
+	assert.Equal(t, "first", msg.Text)
+
+	// same modification time, the cached script is used
+	writeScript(t, filename, `msgText="second"`, modTime)
+	require.NoError(t, modifyMessageTengo(scripts, filename, msg, nil))
+	assert.Equal(t, "first", msg.Text)
+
+	// changed modification time, the script is compiled again
+	writeScript(t, filename, `msgText="third"`, modTime.Add(time.Minute))
+	require.NoError(t, modifyMessageTengo(scripts, filename, msg, nil))
+	assert.Equal(t, "third", msg.Text)
+}
+
+func TestScriptCacheVariables(t *testing.T) {
+	scripts := newScriptCache()
+	for _, text := range []string{"blah one", "blah two"} {
+		msg := &config.Message{Username: "user", Text: text, Account: "protocol.account", Channel: "mychannel"}
+		require.NoError(t, modifyMessageTengo(scripts, "bench.tengo", msg, nil))
+		assert.Equal(t, "replaced by this", msg.Text)
+		assert.Equal(t, "fakeuser", msg.Username)
+	}
+	msg := &config.Message{Username: "user", Text: "no match", Account: "protocol.account", Channel: "mychannel"}
+	require.NoError(t, modifyMessageTengo(scripts, "bench.tengo", msg, nil))
+	assert.Equal(t, "no match", msg.Text)
+	assert.Equal(t, "user", msg.Username)
+}
+
+func TestReloadScripts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matterbridge-tengo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.tengo")
+	modTime := time.Now().Add(-time.Hour)
+	gw := &Gateway{scripts: newScriptCache()}
+
+	writeScript(t, filename, `msgText="first"`, modTime)
+	msg := &config.Message{Text: "text"}
+	require.NoError(t, modifyMessageTengo(gw.scripts, filename, msg, nil))
+	assert.Equal(t, "first", msg.Text)
+
+	writeScript(t, filename, `msgText="second"`, modTime)
+	gw.ReloadScripts()
+	require.NoError(t, modifyMessageTengo(gw.scripts, filename, msg, nil))
+	assert.Equal(t, "second", msg.Text)
+}
+
+func TestModifyMessageTengoData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matterbridge-tengo")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.tengo")
+	writeScript(t, filename, `msgText=msgText+" "+data_suffix`, time.Now())
+
+	msg := &config.Message{Text: "text"}
+	require.NoError(t, modifyMessageTengo(newScriptCache(), filename, msg, map[string]string{"suffix": "configured"}))
+	assert.Equal(t, "text configured", msg.Text)
+}
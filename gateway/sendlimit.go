@@ -0,0 +1,28 @@
+package gateway
+
+// sendLimiter limits the number of messages a gateway sends at the same time.
+// A nil sendLimiter doesn't limit anything.
+type sendLimiter chan struct{}
+
+// newSendLimiter returns a sendLimiter allowing max concurrent sends, or nil
+// when max is 0 or less.
+func newSendLimiter(max int) sendLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return make(sendLimiter, max)
+}
+
+// acquire blocks until a send is allowed.
+func (l sendLimiter) acquire() {
+	if l != nil {
+		l <- struct{}{}
+	}
+}
+
+// release makes room for the next send.
+func (l sendLimiter) release() {
+	if l != nil {
+		<-l
+	}
+}
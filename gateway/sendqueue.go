@@ -0,0 +1,184 @@
+package gateway
+
+import (
+	"sync"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// sendQueueSize is the number of messages that can wait for a destination channel
+// before relaying to it blocks.
+const sendQueueSize = 100
+
+// sendJob is a message waiting in the queue of a destination channel.
+type sendJob struct {
+	msg      config.Message
+	dest     *bridge.Bridge
+	channel  *config.ChannelInfo
+	parentID string
+	// prepared is the message msg became for channel, for a message held while dest was
+	// disconnected. It's sent as is instead of preparing msg again.
+	prepared *config.Message
+	// store has the job saved until it is sent, if dest has a SendQueueFile
+	store *queueStore
+	seq   uint64
+}
+
+// sendQueues holds a queue for every destination channel when ParallelSend is enabled.
+// Every queue is sent from its own goroutine, so a slow channel doesn't delay the
+// others, while messages to the same channel are still sent in order.
+type sendQueues struct {
+	sync.Mutex
+	queues map[string]chan sendJob
+	wg     sync.WaitGroup
+}
+
+func newSendQueues() *sendQueues {
+	return &sendQueues{queues: make(map[string]chan sendJob)}
+}
+
+// parallelSend returns true if messages are sent from a queue per destination channel.
+func (gw *Gateway) parallelSend() bool {
+	return gw.BridgeValues().General.ParallelSend
+}
+
+// queued returns true if the messages to dest are sent from a queue per channel, either
+// because of ParallelSend or because they're spaced by a SmoothingRate.
+func (gw *Gateway) queued(dest *bridge.Bridge) bool {
+	return gw.parallelSend() || smoothed(dest)
+}
+
+// queueMessage adds a copy of rmsg to the queue of channel, saving it to the
+// SendQueueFile of dest if set.
+func (gw *Gateway) queueMessage(rmsg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo, parentID string) {
+	job := sendJob{msg: *rmsg, dest: dest, channel: channel, parentID: parentID}
+	if store := gw.Router.queueStore(dest); store != nil {
+		seq, err := store.add(gw.Name, &job)
+		if err != nil {
+			gw.logger.Errorf("Failed to save queued message to SendQueueFile of %s: %s", dest.Account, err)
+		}
+		job.store, job.seq = store, seq
+	}
+	gw.enqueue(job)
+}
+
+// enqueue adds job to the queue of its channel, starting the queue if it doesn't
+// exist yet.
+func (gw *Gateway) enqueue(job sendJob) {
+	q := gw.sendQueues
+	q.Lock()
+	jobs, ok := q.queues[job.channel.ID]
+	if !ok {
+		jobs = make(chan sendJob, sendQueueSize)
+		q.queues[job.channel.ID] = jobs
+		q.wg.Add(1)
+		go gw.runSendQueue(jobs)
+	}
+	q.Unlock()
+	jobs <- job
+}
+
+// runSendQueue sends the messages of jobs until it is closed.
+func (gw *Gateway) runSendQueue(jobs chan sendJob) {
+	defer gw.sendQueues.wg.Done()
+	for job := range jobs {
+		gw.smoothSend(&job.msg, job.dest, job.channel)
+		msgID, err := gw.sendQueued(&job)
+		if job.store != nil {
+			if err := job.store.remove(job.seq); err != nil {
+				gw.logger.Errorf("Failed to remove sent message from SendQueueFile of %s: %s", job.dest.Account, err)
+			}
+		}
+		if err != nil {
+			gw.logger.Errorf("SendMessage failed: %s", err)
+			gw.handleDeadLetter(&job.msg, job.dest, job.channel, err)
+			continue
+		}
+		if msgID == "" && !keepSentText(job.dest) {
+			continue
+		}
+		if job.msg.Event == config.EventMsgUpdate {
+			gw.updateMsgIDs(&job.msg, &BrMsgID{job.dest, job.dest.Protocol + " " + msgID, job.channel.ID, job.msg.Text, job.msg.Username, gw.now()})
+			continue
+		}
+		gw.addMsgID(&job.msg, &BrMsgID{job.dest, job.dest.Protocol + " " + msgID, job.channel.ID, job.msg.Text, job.msg.Username, gw.now()})
+	}
+}
+
+// sendQueued sends the message of job, without preparing it again if it's already prepared.
+func (gw *Gateway) sendQueued(job *sendJob) (string, error) {
+	if job.prepared != nil {
+		return gw.sendPrepared(&job.msg, *job.prepared, job.dest, job.channel, job.parentID)
+	}
+	return gw.SendMessage(&job.msg, job.dest, job.channel, job.parentID)
+}
+
+// addMsgID records the IDs of a message sent to other bridges in the message cache,
+// replacing the IDs recorded earlier for the same channels.
+func (gw *Gateway) addMsgID(msg *config.Message, ids ...*BrMsgID) {
+	if msg.ID == "" || isReaction(msg) || isPin(msg) || msg.Event == config.EventMsgUpdate {
+		return
+	}
+	gw.sendQueues.Lock()
+	defer gw.sendQueues.Unlock()
+	key := msg.Protocol + " " + msg.ID
+	var kept []*BrMsgID
+	if stored, ok := gw.Messages.Get(key); ok {
+		for _, old := range stored {
+			if !hasMsgIDFor(ids, old) {
+				kept = append(kept, old)
+			}
+		}
+	}
+	gw.Messages.Store(key, append(kept, ids...))
+}
+
+// updateMsgIDs replaces the IDs recorded for the message that msg updates with the IDs
+// in ids that differ. Destinations that resend an edited message, like discord webhooks,
+// return a new ID which later edits and deletes have to use.
+func (gw *Gateway) updateMsgIDs(msg *config.Message, ids ...*BrMsgID) {
+	gw.sendQueues.Lock()
+	defer gw.sendQueues.Unlock()
+	key := msg.Protocol + " " + msg.ID
+	stored, ok := gw.Messages.Get(key)
+	if !ok {
+		return
+	}
+	updated := make([]*BrMsgID, len(stored))
+	changed := false
+	for i, old := range stored {
+		updated[i] = old
+		for _, id := range ids {
+			if id.br == old.br && id.ChannelID == old.ChannelID && id.ID != old.ID && id.ID != id.br.Protocol+" " {
+				updated[i] = id
+				changed = true
+			}
+		}
+	}
+	if changed {
+		gw.Messages.Store(key, updated)
+	}
+}
+
+// hasMsgIDFor returns true if ids has an ID for the bridge and channel of id.
+func hasMsgIDFor(ids []*BrMsgID, id *BrMsgID) bool {
+	for _, other := range ids {
+		if other.br == id.br && other.ChannelID == id.ChannelID {
+			return true
+		}
+	}
+	return false
+}
+
+// flushSendQueues waits until all queued messages are sent and stops the queues.
+func (gw *Gateway) flushSendQueues() {
+	q := gw.sendQueues
+	q.Lock()
+	for id, jobs := range q.queues {
+		close(jobs)
+		delete(q.queues, id)
+	}
+	q.Unlock()
+	q.wg.Wait()
+}
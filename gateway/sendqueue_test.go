@@ -0,0 +1,192 @@
+package gateway
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigParallelSend = []byte(`
+[general]
+ParallelSend=true
+[irc.test]
+server=""
+[slack.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="fast"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="slow"
+`)
+
+// testSlowBridger is a testBridger that blocks sending to channel "slow" until
+// release is closed.
+type testSlowBridger struct {
+	*testBridger
+
+	release chan struct{}
+}
+
+func (b *testSlowBridger) Send(msg config.Message) (string, error) {
+	if msg.Channel == "slow" {
+		<-b.release
+	}
+	return b.testBridger.Send(msg)
+}
+
+func channelTexts(b *testBridger, channel string) []string {
+	var texts []string
+	for _, m := range b.messages() {
+		if m.Channel == channel {
+			texts = append(texts, m.Text)
+		}
+	}
+	return texts
+}
+
+func TestParallelSendOrder(t *testing.T) {
+	slow := &testSlowBridger{testBridger: &testBridger{}, release: make(chan struct{})}
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["slack"] = func(cfg *bridge.Config) bridge.Bridger { return slow }
+
+	r := maketestRouterWithMap(testconfigParallelSend, bridgeMap)
+	gw := r.Gateways["main"]
+
+	var want []string
+	for i := 0; i < 20; i++ {
+		text := strconv.Itoa(i)
+		want = append(want, text)
+		r.relayMessage(config.Message{Text: text, Username: "user", Account: "irc.test", Channel: "#main", ID: text})
+	}
+
+	// the fast channel isn't delayed by the slow one
+	waitFor(t, func() bool { return len(channelTexts(slow.testBridger, "fast")) == 20 })
+	assert.Empty(t, channelTexts(slow.testBridger, "slow"))
+
+	close(slow.release)
+	gw.flushSendQueues()
+	assert.Equal(t, want, channelTexts(slow.testBridger, "fast"))
+	assert.Equal(t, want, channelTexts(slow.testBridger, "slow"))
+
+	// the message IDs of both channels are recorded
+	ids, ok := gw.Messages.Get("irc 0")
+	assert.True(t, ok)
+	assert.Len(t, ids, 2)
+}
+
+var testconfigMaxConcurrentSends = []byte(`
+[general]
+ParallelSend=true
+[irc.test]
+server=""
+[slack.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+MaxConcurrentSends=2
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="one"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="two"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="three"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="four"
+`)
+
+// testBlockingBridger is a testBridger that blocks every send until release is
+// closed and keeps track of the number of concurrent sends.
+type testBlockingBridger struct {
+	*testBridger
+
+	release chan struct{}
+	mu      sync.Mutex
+	active  int
+	max     int
+}
+
+func (b *testBlockingBridger) Send(msg config.Message) (string, error) {
+	b.mu.Lock()
+	b.active++
+	if b.active > b.max {
+		b.max = b.active
+	}
+	b.mu.Unlock()
+	<-b.release
+	b.mu.Lock()
+	b.active--
+	b.mu.Unlock()
+	return b.testBridger.Send(msg)
+}
+
+func (b *testBlockingBridger) state() (int, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.active, b.max
+}
+
+func TestMaxConcurrentSends(t *testing.T) {
+	blocking := &testBlockingBridger{testBridger: &testBridger{}, release: make(chan struct{})}
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["slack"] = func(cfg *bridge.Config) bridge.Bridger { return blocking }
+
+	r := maketestRouterWithMap(testconfigMaxConcurrentSends, bridgeMap)
+	gw := r.Gateways["main"]
+
+	for i := 0; i < 5; i++ {
+		r.relayMessage(config.Message{Text: strconv.Itoa(i), Username: "user", Account: "irc.test", Channel: "#main"})
+	}
+
+	// 4 channel queues are waiting, only 2 of them are sending
+	waitFor(t, func() bool { active, _ := blocking.state(); return active == 2 })
+	time.Sleep(50 * time.Millisecond)
+	active, max := blocking.state()
+	assert.Equal(t, 2, active)
+	assert.Equal(t, 2, max)
+
+	close(blocking.release)
+	gw.flushSendQueues()
+	assert.Len(t, blocking.messages(), 20)
+	_, max = blocking.state()
+	assert.Equal(t, 2, max)
+	for _, channel := range []string{"one", "two", "three", "four"} {
+		assert.Equalf(t, []string{"0", "1", "2", "3", "4"}, channelTexts(blocking.testBridger, channel), "channel %s failed", channel)
+	}
+}
@@ -0,0 +1,148 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// sendTimeoutError is returned when a bridge didn't send a message within its SendTimeout.
+// Like a network timeout it's temporary, sending the message again can succeed. It's
+// only returned after the SendTimeoutRetries of the bridge.
+type sendTimeoutError struct {
+	account string
+	timeout time.Duration
+}
+
+func (e *sendTimeoutError) Error() string {
+	return fmt.Sprintf("sending to %s timed out after %s", e.account, e.timeout)
+}
+
+func (e *sendTimeoutError) Timeout() bool   { return true }
+func (e *sendTimeoutError) Temporary() bool { return true }
+
+// sendTimeout returns the SendTimeout of dest, 0 if sending isn't limited.
+func sendTimeout(dest *bridge.Bridge) time.Duration {
+	return time.Duration(dest.GetInt("SendTimeout")) * time.Millisecond
+}
+
+type sendResult struct {
+	mID string
+	err error
+}
+
+// abandonedSends are the sends that were given up after a timeout but are still running,
+// by account. Bridgers aren't safe for concurrent use, the next message to the bridge
+// waits until they're done.
+type abandonedSends struct {
+	sync.Mutex
+	done map[string]chan struct{}
+}
+
+func newAbandonedSends() *abandonedSends {
+	return &abandonedSends{done: make(map[string]chan struct{})}
+}
+
+// add records result as the abandoned send to account, until it returns.
+func (a *abandonedSends) add(account string, result <-chan sendResult, onDone func(sendResult)) {
+	done := make(chan struct{})
+	a.Lock()
+	a.done[account] = done
+	a.Unlock()
+	go func() {
+		onDone(<-result)
+		a.Lock()
+		if a.done[account] == done {
+			delete(a.done, account)
+		}
+		a.Unlock()
+		close(done)
+	}()
+}
+
+// wait waits up to timeout for the abandoned send to account. Returns false if it's still
+// running.
+func (a *abandonedSends) wait(account string, timeout time.Duration) bool {
+	a.Lock()
+	done, ok := a.done[account]
+	a.Unlock()
+	if !ok {
+		return true
+	}
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// startSend sends msg to dest in the background. The send is cancelled with the returned
+// func if the bridger implements bridge.ContextSender, other bridgers keep sending.
+func startSend(dest *bridge.Bridge, msg config.Message) (<-chan sendResult, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// buffered so the goroutine of a bridger that hangs doesn't leak after it returns
+	result := make(chan sendResult, 1)
+	go func() {
+		var res sendResult
+		if cs, ok := dest.Bridger.(bridge.ContextSender); ok {
+			res.mID, res.err = cs.SendContext(ctx, msg)
+		} else {
+			res.mID, res.err = dest.Send(msg)
+		}
+		result <- res
+	}()
+	return result, cancel
+}
+
+// send sends msg to dest and gives up after its SendTimeout. A send that timed out is
+// retried SendTimeoutRetries times: bridgers implementing bridge.ContextSender are
+// cancelled and send the message again, other bridgers get more time for the send that
+// is still running, so a message is never sent twice at the same time.
+func (gw *Gateway) send(dest *bridge.Bridge, msg config.Message) (string, error) {
+	timeout := sendTimeout(dest)
+	if timeout <= 0 {
+		return dest.Send(msg)
+	}
+	if !gw.Router.abandonedSends.wait(dest.Account, timeout) {
+		err := &sendTimeoutError{account: dest.Account, timeout: timeout}
+		gw.logger.Errorf("%s, an earlier message to %s is still being sent", err, dest.Account)
+		return "", err
+	}
+
+	result, cancel := startSend(dest, msg)
+	attempts, timedOut := 0, false
+	for {
+		timer := time.NewTimer(timeout)
+		select {
+		case res := <-result:
+			timer.Stop()
+			cancel()
+			if res.err == nil || !timedOut {
+				return res.mID, res.err
+			}
+			// the send failed after it timed out, eg because it was cancelled
+			result, cancel = startSend(dest, msg)
+			timedOut = false
+		case <-timer.C:
+			cancel()
+			err := &sendTimeoutError{account: dest.Account, timeout: timeout}
+			attempts++
+			if attempts > dest.GetInt("SendTimeoutRetries") {
+				gw.logger.Errorf("%s, giving up on the message to %s after %d attempt(s)", err, msg.Channel, attempts)
+				gw.Router.abandonedSends.add(dest.Account, result, func(res sendResult) {
+					if res.err == nil {
+						gw.logger.Warnf("the message to %s on %s that timed out was sent after all", msg.Channel, dest.Account)
+					}
+				})
+				return "", err
+			}
+			gw.logger.Warnf("%s, retrying the message to %s", err, msg.Channel)
+			timedOut = true
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigSendTimeout = []byte(`
+[api.test]
+bindaddress=""
+[irc.test]
+server=""
+SendTimeout=50
+[slack.test]
+server=""
+SendTimeout=50
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="api.test"
+    channel="api"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+`)
+
+// blockingBridger blocks sending until unblock is closed or receives a value.
+type blockingBridger struct {
+	testBridger
+
+	unblock   chan struct{}
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+func (b *blockingBridger) Send(msg config.Message) (string, error) {
+	b.mu.Lock()
+	b.active++
+	if b.active > b.maxActive {
+		b.maxActive = b.active
+	}
+	b.mu.Unlock()
+	<-b.unblock
+	b.mu.Lock()
+	b.active--
+	b.mu.Unlock()
+	return b.testBridger.Send(msg)
+}
+
+// contextBridger blocks sending until its context is done, the first failures times.
+type contextBridger struct {
+	testBridger
+
+	failures  int
+	cancelled chan error
+}
+
+func (b *contextBridger) SendContext(ctx context.Context, msg config.Message) (string, error) {
+	b.Lock()
+	fail := b.failures > 0
+	b.failures--
+	b.Unlock()
+	if !fail {
+		return b.testBridger.Send(msg)
+	}
+	<-ctx.Done()
+	b.cancelled <- ctx.Err()
+	return "", ctx.Err()
+}
+
+func TestSendTimeout(t *testing.T) {
+	blocking := &blockingBridger{unblock: make(chan struct{})}
+	defer close(blocking.unblock)
+	ctxSender := &contextBridger{failures: 1, cancelled: make(chan error, 1)}
+	r := maketestRouterWithMap(testconfigSendTimeout, map[string]bridge.Factory{
+		"api":   newTestBridger,
+		"irc":   func(*bridge.Config) bridge.Bridger { return blocking },
+		"slack": func(*bridge.Config) bridge.Bridger { return ctxSender },
+	})
+	gw := r.Gateways["main"]
+	msg := &config.Message{Text: "hello", Username: "alice", Account: "api.test", Channel: "api", Protocol: "api", Gateway: "main"}
+
+	_, err := gw.SendMessage(msg, gw.Bridges["irc.test"], gw.Channels["#mainirc.test"], "")
+	require.Error(t, err)
+	assert.EqualError(t, err, "sending to irc.test timed out after 50ms")
+	netErr, ok := err.(net.Error)
+	require.True(t, ok)
+	assert.True(t, netErr.Timeout())
+	assert.True(t, netErr.Temporary())
+
+	// the send that timed out is still running, the next one isn't sent at the same time
+	_, err = gw.SendMessage(msg, gw.Bridges["irc.test"], gw.Channels["#mainirc.test"], "")
+	assert.EqualError(t, err, "sending to irc.test timed out after 50ms")
+	blocking.mu.Lock()
+	assert.Equal(t, 1, blocking.maxActive)
+	blocking.mu.Unlock()
+
+	_, err = gw.SendMessage(msg, gw.Bridges["slack.test"], gw.Channels["mainslack.test"], "")
+	assert.EqualError(t, err, "sending to slack.test timed out after 50ms")
+	assert.Equal(t, context.Canceled, <-ctxSender.cancelled)
+}
+
+func TestSendTimeoutRetries(t *testing.T) {
+	blocking := &blockingBridger{unblock: make(chan struct{})}
+	ctxSender := &contextBridger{failures: 1, cancelled: make(chan error, 1)}
+	r := maketestRouterWithMap(testconfigSendTimeout, map[string]bridge.Factory{
+		"api":   newTestBridger,
+		"irc":   func(*bridge.Config) bridge.Bridger { return blocking },
+		"slack": func(*bridge.Config) bridge.Bridger { return ctxSender },
+	})
+	gw := r.Gateways["main"]
+	for _, account := range []string{"irc.test", "slack.test"} {
+		br := gw.Bridges[account]
+		cfg := br.Config
+		defer func() { br.Config = cfg }()
+		br.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{account + ".SendTimeoutRetries": 3}}
+	}
+	msg := &config.Message{Text: "hello", Username: "alice", Account: "api.test", Channel: "api", Protocol: "api", Gateway: "main"}
+
+	// the cancelled send is sent again
+	mID, err := gw.SendMessage(msg, gw.Bridges["slack.test"], gw.Channels["mainslack.test"], "")
+	require.NoError(t, err)
+	assert.Equal(t, "1", mID)
+	assert.Equal(t, context.Canceled, <-ctxSender.cancelled)
+	assert.Len(t, ctxSender.messages(), 1)
+
+	// the retry waits for the send that is still running instead of sending again
+	go func() {
+		time.Sleep(75 * time.Millisecond)
+		blocking.unblock <- struct{}{}
+	}()
+	mID, err = gw.SendMessage(msg, gw.Bridges["irc.test"], gw.Channels["#mainirc.test"], "")
+	require.NoError(t, err)
+	assert.Equal(t, "1", mID)
+	assert.Len(t, blocking.messages(), 1)
+	blocking.mu.Lock()
+	assert.Equal(t, 1, blocking.maxActive)
+	blocking.mu.Unlock()
+}
+
+func TestSendTimeoutNotReached(t *testing.T) {
+	r := maketestRouterWithMap(testconfigSendTimeout, testBridgeMap)
+	gw := r.Gateways["main"]
+	msg := &config.Message{Text: "hello", Username: "alice", Account: "api.test", Channel: "api", Protocol: "api", Gateway: "main"}
+
+	mID, err := gw.SendMessage(msg, gw.Bridges["irc.test"], gw.Channels["#mainirc.test"], "")
+	require.NoError(t, err)
+	assert.Equal(t, "1", mID)
+	assert.Len(t, testBridgerOf(gw, "irc.test").messages(), 1)
+}
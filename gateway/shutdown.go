@@ -0,0 +1,93 @@
+package gateway
+
+import "context"
+
+// shutdownRequest asks the router to stop relaying messages from and to gw.
+// done is closed when the router did so.
+type shutdownRequest struct {
+	gw   *Gateway
+	done chan struct{}
+}
+
+// Shutdown relays the messages that are still pending, stops relaying new messages
+// from and to gw and disconnects the bridges of gw that aren't used by other gateways.
+// When ctx expires before this is done ctx.Err() is returned.
+func (gw *Gateway) Shutdown(ctx context.Context) error {
+	req := shutdownRequest{gw: gw, done: make(chan struct{})}
+	select {
+	case gw.Router.shutdown <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-req.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for account, br := range gw.Bridges {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if gw.Router.bridgeInUse(account) {
+			continue
+		}
+		gw.logger.Infof("Disconnecting bridge %s", account)
+		if err := br.Disconnect(); err != nil {
+			gw.logger.Errorf("Disconnecting bridge %s failed: %s", account, err)
+		}
+	}
+	return nil
+}
+
+func (gw *Gateway) close() {
+	if !gw.isClosed() {
+		close(gw.closed)
+	}
+}
+
+// isClosed returns true if gw has been shut down.
+func (gw *Gateway) isClosed() bool {
+	select {
+	case <-gw.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// drain relays the messages bridges are waiting to send to the router and the
+// messages held back by the coalescers of the bridges of gw.
+func (r *Router) drain(gw *Gateway) {
+	for {
+		select {
+		case msg, ok := <-r.Message:
+			if !ok {
+				return
+			}
+			for _, m := range r.coalesce(msg) {
+				r.relayMessage(m)
+			}
+		default:
+			for account := range gw.Bridges {
+				c := r.coalescers[account]
+				if c == nil {
+					continue
+				}
+				for _, msg := range c.flush() {
+					r.relayMessage(msg)
+				}
+			}
+			return
+		}
+	}
+}
+
+// bridgeInUse returns true if account is a bridge of a gateway that isn't shut down.
+func (r *Router) bridgeInUse(account string) bool {
+	for _, gw := range r.Gateways {
+		if _, ok := gw.Bridges[account]; ok && !gw.isClosed() {
+			return true
+		}
+	}
+	return false
+}
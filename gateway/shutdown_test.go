@@ -0,0 +1,92 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigShutdown = []byte(`
+[irc.test]
+server=""
+[slack.test]
+server=""
+CoalesceWindow=3600000
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+[[gateway]]
+name="other"
+enable=true
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="other"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="other"
+`)
+
+func TestShutdown(t *testing.T) {
+	r := maketestRouterWithMap(testconfigShutdown, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	slack := testBridgerOf(gw, "slack.test")
+	stop := startRouter(r)
+	defer stop()
+
+	// these are held back by the coalescer of slack
+	r.Message <- config.Message{Text: "hello", Username: "user", Account: "slack.test", Channel: "main"}
+	r.Message <- config.Message{Text: "world", Username: "user", Account: "slack.test", Channel: "main"}
+	assert.Empty(t, irc.messages())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, gw.Shutdown(ctx))
+
+	// pending messages are delivered
+	sent := irc.messages()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "hello\nworld", sent[0].Text)
+
+	// slack is still used by the other gateway
+	assert.True(t, irc.isDisconnected())
+	assert.False(t, slack.isDisconnected())
+
+	// new messages aren't relayed anymore, the second message makes sure the router
+	// handled the first one
+	r.Message <- config.Message{Text: "ignored", Username: "user", Account: "slack.test", Channel: "main"}
+	r.Message <- config.Message{Text: "sync", Username: "user", Account: "slack.test", Channel: "other"}
+	assert.Len(t, irc.messages(), 1)
+
+	// shutting down twice is fine
+	require.NoError(t, gw.Shutdown(ctx))
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	// the router isn't running, so the shutdown can't finish
+	r := maketestRouterWithMap(testconfigShutdown, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, gw.Shutdown(ctx))
+	assert.False(t, testBridgerOf(gw, "irc.test").isDisconnected())
+}
@@ -0,0 +1,54 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// extraSignature is the key of the signature of SigningSecret in the Extra of a message.
+const extraSignature = "signature"
+
+// signaturePayload returns the canonical form of msg that is signed: the lines
+// "v1", the Unix time of the timestamp, the gateway, channel, username, event and text
+// of msg, separated by "\n". The text is last as it can contain newlines.
+func signaturePayload(msg *config.Message) string {
+	return strings.Join([]string{
+		"v1",
+		strconv.FormatInt(msg.Timestamp.Unix(), 10),
+		msg.Gateway,
+		msg.Channel,
+		msg.Username,
+		msg.Event,
+		msg.Text,
+	}, "\n")
+}
+
+// messageSignature returns the HMAC-SHA256 of the canonical form of msg with secret,
+// like "sha256=<hex>".
+func messageSignature(msg *config.Message, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signaturePayload(msg)))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// signMessage sets Extra["signature"] of msg to its signature with the SigningSecret of
+// dest, so consumers of the API or a webhook can verify it was sent by matterbridge.
+func signMessage(msg *config.Message, dest *bridge.Bridge) {
+	secret := dest.GetString("SigningSecret")
+	if secret == "" {
+		return
+	}
+	// Extra can be shared with the messages sent to other bridges
+	extra := make(map[string][]interface{}, len(msg.Extra)+1)
+	for k, v := range msg.Extra {
+		extra[k] = v
+	}
+	extra[extraSignature] = []interface{}{messageSignature(msg, secret)}
+	msg.Extra = extra
+}
@@ -0,0 +1,71 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigSignature = []byte(`
+[irc.test]
+server=""
+[api.test]
+SigningSecret="secret"
+[slack.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="api.test"
+    channel="api"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+`)
+
+func TestMessageSignature(t *testing.T) {
+	msg := &config.Message{
+		Text: "hello\nworld", Username: "alice", Channel: "#main", Gateway: "main",
+		Timestamp: time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC),
+	}
+	expected := "sha256=37397c288d8607f2d870fc8aa81654b8abba85d71d0e1d0dc618f685454f2e92"
+	assert.Equal(t, "v1\n1591005600\nmain\n#main\nalice\n\nhello\nworld", signaturePayload(msg))
+	assert.Equal(t, expected, messageSignature(msg, "secret"))
+
+	// the signature doesn't depend on the other fields or the timezone
+	msg.Account = "irc.test"
+	msg.Extra = map[string][]interface{}{"file": {}}
+	msg.Timestamp = msg.Timestamp.In(time.FixedZone("CEST", 2*60*60))
+	assert.Equal(t, expected, messageSignature(msg, "secret"))
+
+	assert.NotEqual(t, expected, messageSignature(msg, "other secret"))
+	msg.Text = "hello"
+	assert.NotEqual(t, expected, messageSignature(msg, "secret"))
+}
+
+func TestSignMessage(t *testing.T) {
+	r := maketestRouterWithMap(testconfigSignature, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	extra := map[string][]interface{}{"attachments": {}}
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#main", Extra: extra})
+
+	sent := testBridgerOf(gw, "api.test").messages()
+	require.Len(t, sent, 1)
+	require.Len(t, sent[0].Extra[extraSignature], 1)
+	assert.Equal(t, messageSignature(&sent[0], "secret"), sent[0].Extra[extraSignature][0])
+	// other destinations and the received message aren't signed
+	assert.Empty(t, testBridgerOf(gw, "slack.test").messages()[0].Extra[extraSignature])
+	assert.Empty(t, extra[extraSignature])
+}
@@ -0,0 +1,56 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// smoother spaces the messages sent to destination channels with a SmoothingRate like a
+// leaky bucket: a burst is sent at the steady rate instead of at once. Unlike a limit
+// nothing is dropped, sending just waits for the next slot of the channel.
+type smoother struct {
+	sync.Mutex
+
+	// next is the time of the next free slot by destination channel ID
+	next map[string]time.Time
+}
+
+func newSmoother() *smoother {
+	return &smoother{next: make(map[string]time.Time)}
+}
+
+// reserve returns the time the message to channel can be sent when messages are sent
+// every interval, and reserves it.
+func (s *smoother) reserve(channel string, now time.Time, interval time.Duration) time.Time {
+	s.Lock()
+	defer s.Unlock()
+	slot := s.next[channel]
+	if slot.Before(now) {
+		slot = now
+	}
+	s.next[channel] = slot.Add(interval)
+	return slot
+}
+
+// smoothed returns true if the messages to dest are spaced by a SmoothingRate.
+func smoothed(dest *bridge.Bridge) bool {
+	return dest.GetInt("SmoothingRate") > 0
+}
+
+// smoothSend waits until msg can be sent to channel according to the SmoothingRate
+// (messages per minute) of dest. Typing notifications aren't delayed. It is only called
+// from the send queue of channel, waiting never blocks the router.
+func (gw *Gateway) smoothSend(msg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) {
+	rate := dest.GetInt("SmoothingRate")
+	if rate <= 0 || msg.Event == config.EventUserTyping {
+		return
+	}
+	now := time.Now()
+	slot := gw.smoother.reserve(channel.ID, now, time.Minute/time.Duration(rate))
+	if wait := slot.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+}
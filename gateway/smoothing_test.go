@@ -0,0 +1,121 @@
+package gateway
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigSmoothing = []byte(`
+[irc.test]
+server=""
+[slack.test]
+server=""
+SmoothingRate=300
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+`)
+
+// testTimedBridger records when every message was sent.
+type testTimedBridger struct {
+	*testBridger
+
+	timesLock sync.Mutex
+	times     []time.Time
+}
+
+func (b *testTimedBridger) Send(msg config.Message) (string, error) {
+	b.timesLock.Lock()
+	b.times = append(b.times, time.Now())
+	b.timesLock.Unlock()
+	return b.testBridger.Send(msg)
+}
+
+func (b *testTimedBridger) sentTimes() []time.Time {
+	b.timesLock.Lock()
+	defer b.timesLock.Unlock()
+	return append([]time.Time(nil), b.times...)
+}
+
+func TestSmoothingRate(t *testing.T) {
+	smoothed := &testTimedBridger{testBridger: &testBridger{}}
+	direct := &testTimedBridger{testBridger: &testBridger{}}
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["slack"] = func(cfg *bridge.Config) bridge.Bridger { return smoothed }
+	bridgeMap["discord"] = func(cfg *bridge.Config) bridge.Bridger { return direct }
+
+	r := maketestRouterWithMap(testconfigSmoothing, bridgeMap)
+	gw := r.Gateways["main"]
+
+	start := time.Now()
+	for _, text := range []string{"1", "2", "3", "4", "5"} {
+		r.relayMessage(config.Message{Text: text, Username: "user", Account: "irc.test", Channel: "#main"})
+	}
+	// the router doesn't wait for the smoothed channel, even without ParallelSend
+	assert.True(t, time.Since(start) < 100*time.Millisecond, "relaying took %s", time.Since(start))
+	gw.flushSendQueues()
+
+	// the burst is sent at once to destinations without SmoothingRate
+	times := direct.sentTimes()
+	require.Len(t, times, 5)
+	assert.True(t, times[4].Sub(start) < 100*time.Millisecond, "burst was delayed")
+
+	// and one message every 200ms to slack, the first one immediately
+	times = smoothed.sentTimes()
+	require.Len(t, times, 5)
+	assert.True(t, times[0].Sub(start) < 100*time.Millisecond, "first message was delayed")
+	for i, sent := range times {
+		assert.True(t, sent.Sub(start) >= time.Duration(i)*200*time.Millisecond, "message %d sent after %s", i, sent.Sub(start))
+	}
+	assert.True(t, times[4].Sub(start) < 1500*time.Millisecond, "burst took %s", times[4].Sub(start))
+
+	var texts []string
+	for _, msg := range smoothed.messages() {
+		texts = append(texts, msg.Text)
+	}
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, texts)
+
+	// the IDs of the queued and the directly sent messages are both recorded
+	r.relayMessage(config.Message{Text: "6", ID: "6", Username: "user", Account: "irc.test", Channel: "#main"})
+	gw.flushSendQueues()
+	IDs, ok := gw.Messages.Get("irc 6")
+	require.True(t, ok)
+	assert.Len(t, IDs, 2)
+}
+
+func TestSmootherReserve(t *testing.T) {
+	s := newSmoother()
+	now := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, now, s.reserve("main", now, time.Second))
+	assert.Equal(t, now.Add(time.Second), s.reserve("main", now, time.Second))
+	assert.Equal(t, now.Add(2*time.Second), s.reserve("main", now.Add(500*time.Millisecond), time.Second))
+	// channels have their own slots
+	assert.Equal(t, now, s.reserve("other", now, time.Second))
+	// the bucket is empty again after a pause
+	later := now.Add(time.Minute)
+	assert.Equal(t, later, s.reserve("main", later, time.Second))
+}
@@ -0,0 +1,78 @@
+package gateway
+
+import (
+	"sync"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// SpamClassifier decides if a message received by a gateway is spam. Spam isn't relayed,
+// the reason is logged.
+type SpamClassifier interface {
+	IsSpam(msg config.Message) (bool, string)
+}
+
+// SpamClassifierFunc is an adapter to allow the use of ordinary functions as a SpamClassifier.
+type SpamClassifierFunc func(msg config.Message) (bool, string)
+
+// IsSpam calls f(msg).
+func (f SpamClassifierFunc) IsSpam(msg config.Message) (bool, string) {
+	return f(msg)
+}
+
+// noopSpamClassifier is the SpamClassifier of a gateway until SetSpamClassifier is
+// called, nothing is spam.
+type noopSpamClassifier struct{}
+
+func (noopSpamClassifier) IsSpam(msg config.Message) (bool, string) {
+	return false, ""
+}
+
+type spamFilter struct {
+	sync.RWMutex
+	classifier SpamClassifier
+	// dropped is the number of messages dropped as spam by reason
+	dropped map[string]uint64
+}
+
+func newSpamFilter() *spamFilter {
+	return &spamFilter{classifier: noopSpamClassifier{}, dropped: make(map[string]uint64)}
+}
+
+// SetSpamClassifier sets the SpamClassifier of gw, nil restores the default which
+// doesn't drop anything.
+func (gw *Gateway) SetSpamClassifier(c SpamClassifier) {
+	if c == nil {
+		c = noopSpamClassifier{}
+	}
+	gw.spam.Lock()
+	defer gw.spam.Unlock()
+	gw.spam.classifier = c
+}
+
+// SpamCounts returns the number of messages dropped as spam by gw by reason.
+func (gw *Gateway) SpamCounts() map[string]uint64 {
+	gw.spam.RLock()
+	defer gw.spam.RUnlock()
+	counts := make(map[string]uint64, len(gw.spam.dropped))
+	for reason, n := range gw.spam.dropped {
+		counts[reason] = n
+	}
+	return counts
+}
+
+// isSpam returns true if the SpamClassifier of gw classifies msg as spam.
+func (gw *Gateway) isSpam(msg *config.Message) bool {
+	gw.spam.RLock()
+	c := gw.spam.classifier
+	gw.spam.RUnlock()
+	spam, reason := c.IsSpam(*msg)
+	if !spam {
+		return false
+	}
+	gw.logger.Infof("dropping spam from %s on %s: %s", msg.Username, msg.Account, reason)
+	gw.spam.Lock()
+	gw.spam.dropped[reason]++
+	gw.spam.Unlock()
+	return true
+}
@@ -0,0 +1,66 @@
+package gateway
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigSpam = []byte(`
+[irc.test]
+server=""
+[slack.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+`)
+
+func TestSpamClassifier(t *testing.T) {
+	r := maketestRouterWithMap(testconfigSpam, testBridgeMap)
+	gw := r.Gateways["main"]
+	slack := testBridgerOf(gw, "slack.test")
+
+	var classified []string
+	gw.SetSpamClassifier(SpamClassifierFunc(func(msg config.Message) (bool, string) {
+		classified = append(classified, msg.Text)
+		if strings.Contains(msg.Text, "free crypto") {
+			return true, "crypto"
+		}
+		return false, ""
+	}))
+
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#main"})
+	r.relayMessage(config.Message{Text: "get free crypto now", Username: "bot", Account: "irc.test", Channel: "#main"})
+	r.relayMessage(config.Message{Text: "more free crypto", Username: "bot", Account: "irc.test", Channel: "#main"})
+	// events aren't classified
+	r.relayMessage(config.Message{Text: "alice joins", Username: "system", Account: "irc.test", Channel: "#main", Event: config.EventJoinLeave})
+
+	sent := slack.messages()
+	require.NotEmpty(t, sent)
+	assert.Equal(t, "hello", sent[0].Text)
+	for _, msg := range sent {
+		assert.NotContains(t, msg.Text, "crypto")
+	}
+	assert.Equal(t, []string{"hello", "get free crypto now", "more free crypto"}, classified)
+	assert.Equal(t, map[string]uint64{"crypto": 2}, gw.SpamCounts())
+
+	// nil restores the default, nothing is spam
+	gw.SetSpamClassifier(nil)
+	r.relayMessage(config.Message{Text: "free crypto is back", Username: "bot", Account: "irc.test", Channel: "#main"})
+	sent = slack.messages()
+	assert.Equal(t, "free crypto is back", sent[len(sent)-1].Text)
+	assert.Equal(t, map[string]uint64{"crypto": 2}, gw.SpamCounts())
+}
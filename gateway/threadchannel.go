@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// threadIDCleanRE matches the characters of a thread ID that aren't used in the name of
+// its ThreadAsChannel channel.
+var threadIDCleanRE = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// threadChannelName returns the name of the channel on dest for the replies to the thread
+// threadID, the ThreadAsChannel of dest with {CHANNEL} and {THREAD} replaced. Returns an
+// empty string without ThreadAsChannel or thread.
+func threadChannelName(dest *bridge.Bridge, channel *config.ChannelInfo, threadID string) string {
+	format := dest.GetString("ThreadAsChannel")
+	if format == "" || threadID == "" {
+		return ""
+	}
+	// replies from other bridges get the canonical ID with its protocol, eg "slack 1234"
+	if i := strings.LastIndex(threadID, " "); i >= 0 {
+		threadID = threadID[i+1:]
+	}
+	threadID = strings.Trim(threadIDCleanRE.ReplaceAllString(threadID, "-"), "-")
+	name := strings.Replace(format, "{CHANNEL}", channel.Name, -1)
+	return strings.Replace(name, "{THREAD}", threadID, -1)
+}
+
+// joinThreadChannel joins the channel name on dest if it didn't yet. The channel is added
+// to the channels of dest, so it's joined again after a reconnect.
+func (gw *Gateway) joinThreadChannel(dest *bridge.Bridge, channel *config.ChannelInfo, name string) error {
+	dest.Lock()
+	defer dest.Unlock()
+	info := config.ChannelInfo{
+		Name:        name,
+		Account:     dest.Account,
+		Direction:   "out",
+		ID:          name + dest.Account,
+		SameChannel: channel.SameChannel,
+		Options:     channel.Options,
+	}
+	if dest.Joined[info.ID] {
+		return nil
+	}
+	gw.logger.Infof("%s: joining thread channel %s", dest.Account, name)
+	if err := dest.JoinChannel(info); err != nil {
+		return err
+	}
+	dest.Channels[info.ID] = info
+	dest.Joined[info.ID] = true
+	return nil
+}
+
+// modifyThreadChannel sends the reply msg to the ThreadAsChannel channel of its thread
+// instead of channel, as a message without parent. The channel is joined when msg is
+// sent, see joinMessageThreadChannel.
+func (gw *Gateway) modifyThreadChannel(msg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo, canonicalParentMsgID string) {
+	name := threadChannelName(dest, channel, canonicalParentMsgID)
+	if name == "" {
+		return
+	}
+	msg.Channel = name
+	msg.ParentID = ""
+}
+
+// joinMessageThreadChannel joins the ThreadAsChannel channel msg was sent to by
+// modifyThreadChannel for rmsg. If joining fails msg is sent to channel as a reply instead.
+func (gw *Gateway) joinMessageThreadChannel(rmsg *config.Message, msg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo, canonicalParentMsgID string) {
+	name := threadChannelName(dest, channel, canonicalParentMsgID)
+	if name == "" || msg.Channel != name {
+		return
+	}
+	if err := gw.joinThreadChannel(dest, channel, name); err != nil {
+		gw.logger.Errorf("joining thread channel %s on %s failed: %s", name, dest.Account, err)
+		msg.Channel = channel.Name
+		msg.ParentID = gw.destParentID(rmsg, dest, channel, canonicalParentMsgID)
+	}
+}
@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigThreadChannel = []byte(`
+[irc.test]
+server=""
+ThreadAsChannel="{CHANNEL}-thread-{THREAD}"
+[slack.test]
+server=""
+PreserveThreading=true
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+`)
+
+func TestThreadAsChannel(t *testing.T) {
+	r := maketestRouterWithMap(testconfigThreadChannel, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := gw.Bridges["irc.test"]
+
+	r.relayMessage(config.Message{ID: "100", Text: "root", Username: "alice", Account: "slack.test", Channel: "main"})
+	r.relayMessage(config.Message{ID: "101", ParentID: "100", Text: "reply", Username: "bob", Account: "slack.test", Channel: "main"})
+	// a reply to the copy of the root on discord is in the same thread
+	discordRoot := testBridgerOf(gw, "discord.test").messages()[0]
+	require.Equal(t, "root", discordRoot.Text)
+	r.relayMessage(config.Message{ID: "d1", ParentID: "1", Text: "reply from discord", Username: "carol", Account: "discord.test", Channel: "main"})
+	r.relayMessage(config.Message{ID: "102", ParentID: "unknown", Text: "lost reply", Username: "bob", Account: "slack.test", Channel: "main"})
+
+	var sent []string
+	for _, msg := range testBridgerOf(gw, "irc.test").messages() {
+		if msg.Channel != "#main" {
+			assert.Empty(t, msg.ParentID)
+		}
+		sent = append(sent, msg.Channel+": "+msg.Text)
+	}
+	assert.Equal(t, []string{
+		"#main: root",
+		"#main-thread-100: reply",
+		"#main-thread-100: reply from discord",
+		"#main: lost reply",
+	}, sent)
+	assert.True(t, irc.Joined["#main-thread-100irc.test"])
+	assert.Equal(t, "#main-thread-100", irc.Channels["#main-thread-100irc.test"].Name)
+
+	// bridges without ThreadAsChannel keep the thread in the channel
+	slack := testBridgerOf(gw, "slack.test").messages()
+	require.NotEmpty(t, slack)
+	assert.Equal(t, "main", slack[len(slack)-1].Channel)
+}
+
+func TestThreadChannelName(t *testing.T) {
+	r := maketestRouterWithMap(testconfigThreadChannel, testBridgeMap)
+	gw := r.Gateways["main"]
+	channel := &config.ChannelInfo{Name: "#main"}
+	assert.Equal(t, "#main-thread-1234-5678", threadChannelName(gw.Bridges["irc.test"], channel, "slack 1234.5678"))
+	assert.Equal(t, "", threadChannelName(gw.Bridges["irc.test"], channel, ""))
+	assert.Equal(t, "", threadChannelName(gw.Bridges["discord.test"], channel, "1234"))
+}
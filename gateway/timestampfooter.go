@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// locationCache keeps the time zones of TimestampTimezone around so they don't get
+// loaded for every message.
+type locationCache struct {
+	sync.Mutex
+
+	locations map[string]*time.Location
+}
+
+func newLocationCache() *locationCache {
+	return &locationCache{locations: make(map[string]*time.Location)}
+}
+
+// load returns the time zone name, eg "Europe/Brussels". Invalid zones aren't cached.
+func (lc *locationCache) load(name string) (*time.Location, error) {
+	lc.Lock()
+	defer lc.Unlock()
+	if loc, ok := lc.locations[name]; ok {
+		return loc, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	lc.locations[name] = loc
+	return loc, nil
+}
+
+// validateTimestampTimezone returns an error if the TimestampTimezone of br is unknown.
+func validateTimestampTimezone(br *bridge.Bridge) error {
+	name := br.GetString("TimestampTimezone")
+	if name == "" {
+		return nil
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return fmt.Errorf("invalid TimestampTimezone %s of %s: %s", name, br.Account, err)
+	}
+	return nil
+}
+
+// modifyTimestampFooter appends the time of rmsg formatted with the TimestampFooter of
+// dest in its TimestampTimezone to text on a line of its own. The local time zone is
+// used without TimestampTimezone.
+func (gw *Gateway) modifyTimestampFooter(rmsg *config.Message, dest *bridge.Bridge, text string) string {
+	layout := dest.GetString("TimestampFooter")
+	if layout == "" || text == "" {
+		return text
+	}
+	if rmsg.Event != "" && rmsg.Event != config.EventUserAction {
+		return text
+	}
+	loc := time.Local
+	if name := dest.GetString("TimestampTimezone"); name != "" {
+		var err error
+		if loc, err = gw.locations.load(name); err != nil {
+			gw.logger.Errorf("invalid TimestampTimezone %s of %s: %s", name, dest.Account, err)
+			return text
+		}
+	}
+	ts := rmsg.Timestamp
+	if ts.IsZero() {
+		ts = gw.now()
+	}
+	return text + "\n" + ts.In(loc).Format(layout)
+}
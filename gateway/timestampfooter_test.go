@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigTimestampFooter = []byte(`
+[irc.test]
+server=""
+TimestampFooter="sent 2006-01-02 15:04 MST"
+TimestampTimezone="UTC"
+[discord.test]
+server=""
+TimestampFooter="15:04 MST"
+TimestampTimezone="America/New_York"
+[slack.test]
+server=""
+TimestampFooter="15:04 MST"
+TimestampTimezone="Asia/Tokyo"
+[telegram.test]
+server=""
+TimestampFooter="15:04"
+TimestampTimezone="Mars/Olympus_Mons"
+[mattermost.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="telegram.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="mattermost.test"
+    channel="main"
+`)
+
+func TestTimestampFooter(t *testing.T) {
+	r := maketestRouterWithMap(testconfigTimestampFooter, testBridgeMap)
+	gw := r.Gateways["main"]
+	ts := time.Date(2020, 6, 1, 23, 30, 0, 0, time.UTC)
+
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "mattermost.test", Channel: "main", Timestamp: ts})
+	r.relayMessage(config.Message{Text: "alice joins", Username: "system", Account: "mattermost.test", Channel: "main", Event: config.EventJoinLeave, Timestamp: ts})
+
+	irc := testBridgerOf(gw, "irc.test").messages()
+	require.NotEmpty(t, irc)
+	assert.Equal(t, "hello\nsent 2020-06-01 23:30 UTC", irc[0].Text)
+	discord := testBridgerOf(gw, "discord.test").messages()
+	require.NotEmpty(t, discord)
+	assert.Equal(t, "hello\n19:30 EDT", discord[0].Text)
+	slack := testBridgerOf(gw, "slack.test").messages()
+	require.NotEmpty(t, slack)
+	assert.Equal(t, "hello\n08:30 JST", slack[0].Text)
+	// an invalid time zone skips the footer
+	telegram := testBridgerOf(gw, "telegram.test").messages()
+	require.NotEmpty(t, telegram)
+	assert.Equal(t, "hello", telegram[0].Text)
+	for _, msg := range append(irc, append(discord, slack...)...) {
+		if msg.Event == config.EventJoinLeave {
+			assert.Equal(t, "alice joins", msg.Text)
+		}
+	}
+}
+
+func TestValidateTimestampTimezone(t *testing.T) {
+	r := maketestRouterWithMap(testconfigTimestampFooter, testBridgeMap)
+	gw := r.Gateways["main"]
+	for account, valid := range map[string]bool{
+		"irc.test":        true,
+		"discord.test":    true,
+		"slack.test":      true,
+		"mattermost.test": true,
+		"telegram.test":   false,
+	} {
+		err := validateTimestampTimezone(gw.Bridges[account])
+		if valid {
+			assert.NoError(t, err, account)
+		} else {
+			assert.Error(t, err, account)
+		}
+	}
+}
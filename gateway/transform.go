@@ -0,0 +1,47 @@
+package gateway
+
+import (
+	"fmt"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/matterbridge/emoji"
+)
+
+// transformStages are the modifications of modifyMessage that can be ordered
+// with the TransformOrder of a gateway.
+var transformStages = map[string]func(gw *Gateway, msg *config.Message){
+	// tengo runs the tengo scripts and the other registered modifiers
+	"tengo": (*Gateway).runModifiers,
+	// emoji replaces the CustomEmojiFile shortcodes and :emoji: with unicode
+	"emoji": func(gw *Gateway, msg *config.Message) {
+		gw.replaceCustomEmoji(msg)
+		msg.Text = emoji.Sprint(msg.Text)
+	},
+	"replacemessages": (*Gateway).replaceMessages,
+	"extractnicks":    (*Gateway).handleExtractNicks,
+}
+
+var defaultTransformOrder = []string{"tengo", "emoji", "replacemessages", "extractnicks"}
+
+// validateTransformOrder returns an error if order contains an unknown or duplicate stage.
+func validateTransformOrder(order []string) error {
+	seen := make(map[string]bool)
+	for _, stage := range order {
+		if _, ok := transformStages[stage]; !ok {
+			return fmt.Errorf("unknown TransformOrder stage %s, valid stages are %v", stage, defaultTransformOrder)
+		}
+		if seen[stage] {
+			return fmt.Errorf("TransformOrder stage %s is listed twice", stage)
+		}
+		seen[stage] = true
+	}
+	return nil
+}
+
+// transformOrder returns the order of the stages of modifyMessage for gw.
+func (gw *Gateway) transformOrder() []string {
+	if gw.MyConfig == nil || len(gw.MyConfig.TransformOrder) == 0 {
+		return defaultTransformOrder
+	}
+	return gw.MyConfig.TransformOrder
+}
@@ -0,0 +1,101 @@
+package gateway
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigTransformOrder = []byte(`
+[irc.test]
+server=""
+ReplaceMessages=[ [":smile:", ":wink:"] ]
+[slack.test]
+server=""
+
+[[gateway]]
+name="default"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#default"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="default"
+
+[[gateway]]
+name="reordered"
+enable=true
+TransformOrder=["tengo","replacemessages","emoji","extractnicks"]
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#reordered"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="reordered"
+
+[[gateway]]
+name="noemoji"
+enable=true
+TransformOrder=["replacemessages"]
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#noemoji"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="noemoji"
+`)
+
+func TestTransformOrder(t *testing.T) {
+	r := maketestRouterWithMap(testconfigTransformOrder, testBridgeMap)
+
+	msgTests := map[string]struct {
+		gateway string
+		output  string
+	}{
+		"emoji before replace": {gateway: "default", output: "hi 😄"},
+		"replace before emoji": {gateway: "reordered", output: "hi 😉"},
+		"stage left out":       {gateway: "noemoji", output: "hi :wink:"},
+	}
+	for testname, testcase := range msgTests {
+		gw := r.Gateways[testcase.gateway]
+		msg := &config.Message{Text: "hi :smile:", Account: "irc.test", Channel: "#" + testcase.gateway}
+		gw.modifyMessage(msg)
+		assert.Equalf(t, testcase.output, msg.Text, "case '%s' failed", testname)
+	}
+}
+
+func TestTransformOrderInvalid(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	for stages, errText := range map[string]string{
+		`["emoji","unknown"]`: "gateway main: unknown TransformOrder stage unknown",
+		`["emoji","emoji"]`:   "gateway main: TransformOrder stage emoji is listed twice",
+	} {
+		cfg := config.NewConfigFromString(logger, []byte(`
+[irc.test]
+server=""
+[[gateway]]
+name="main"
+enable=true
+TransformOrder=`+stages+`
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+`))
+		_, err := NewRouter(logger, cfg, testBridgeMap)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), errText)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package gateway
+
+import (
+	"sync"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Translator translates the text of the messages sent to bridges with a TargetLanguage.
+type Translator interface {
+	Translate(text, targetLang string) (string, error)
+}
+
+// TranslatorFunc is an adapter to allow the use of ordinary functions as a Translator.
+type TranslatorFunc func(text, targetLang string) (string, error)
+
+// Translate calls f(text, targetLang).
+func (f TranslatorFunc) Translate(text, targetLang string) (string, error) {
+	return f(text, targetLang)
+}
+
+// noopTranslator is the Translator of a gateway until SetTranslator is called, it
+// leaves the text as is.
+type noopTranslator struct{}
+
+func (noopTranslator) Translate(text, targetLang string) (string, error) {
+	return text, nil
+}
+
+// translationKey is the key of a translation in the cache.
+type translationKey struct {
+	text string
+	lang string
+}
+
+type translations struct {
+	sync.RWMutex
+	translator Translator
+	cache      *lru.Cache
+}
+
+func newTranslations() *translations {
+	cache, _ := lru.New(1000)
+	return &translations{translator: noopTranslator{}, cache: cache}
+}
+
+// SetTranslator sets the Translator used for the bridges with a TargetLanguage and
+// clears the translations of the previous one.
+func (gw *Gateway) SetTranslator(t Translator) {
+	gw.translations.Lock()
+	defer gw.translations.Unlock()
+	gw.translations.translator = t
+	gw.translations.cache.Purge()
+}
+
+// translate returns text translated to lang, translations are cached.
+func (gw *Gateway) translate(text, lang string) (string, error) {
+	gw.translations.RLock()
+	defer gw.translations.RUnlock()
+	key := translationKey{text: text, lang: lang}
+	if v, ok := gw.translations.cache.Get(key); ok {
+		return v.(string), nil
+	}
+	translated, err := gw.translations.translator.Translate(text, lang)
+	if err != nil {
+		return text, err
+	}
+	gw.translations.cache.Add(key, translated)
+	return translated, nil
+}
+
+// translateMessage returns msg with its text translated to the TargetLanguage of dest.
+// msg is returned as is without TargetLanguage, for events and when translating fails.
+func (gw *Gateway) translateMessage(msg *config.Message, dest *bridge.Bridge) *config.Message {
+	lang := dest.GetString("TargetLanguage")
+	if lang == "" || msg.Text == "" || msg.Event != "" && msg.Event != config.EventUserAction {
+		return msg
+	}
+	text, err := gw.translate(msg.Text, lang)
+	if err != nil {
+		gw.logger.Errorf("translating message to %s for %s failed: %s", lang, dest.Account, err)
+		return msg
+	}
+	translated := *msg
+	translated.Text = text
+	return &translated
+}
@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigTranslate = []byte(`
+[general]
+RemoteNickFormat="{NICK}: "
+[irc.test]
+server=""
+[slack.test]
+server=""
+TargetLanguage="upper"
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+`)
+
+func TestTranslate(t *testing.T) {
+	r := maketestRouterWithMap(testconfigTranslate, testBridgeMap)
+	gw := r.Gateways["main"]
+	slack := testBridgerOf(gw, "slack.test")
+	discord := testBridgerOf(gw, "discord.test")
+
+	// without a translator the text is kept
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#main"})
+	require.Len(t, slack.messages(), 1)
+	assert.Equal(t, "hello", slack.messages()[0].Text)
+
+	calls := 0
+	gw.SetTranslator(TranslatorFunc(func(text, targetLang string) (string, error) {
+		calls++
+		assert.Equal(t, "upper", targetLang)
+		return strings.ToUpper(text), nil
+	}))
+	for _, text := range []string{"hello", "hello", "bye"} {
+		r.relayMessage(config.Message{Text: text, Username: "alice", Account: "irc.test", Channel: "#main"})
+	}
+	sent := slack.messages()
+	require.Len(t, sent, 4)
+	assert.Equal(t, "HELLO", sent[1].Text)
+	assert.Equal(t, "HELLO", sent[2].Text)
+	assert.Equal(t, "BYE", sent[3].Text)
+	// the username isn't translated
+	assert.Equal(t, "alice: ", sent[3].Username)
+	// translations are cached
+	assert.Equal(t, 2, calls)
+	// destinations without TargetLanguage aren't translated
+	assert.Equal(t, "bye", discord.messages()[3].Text)
+
+	// the original text is sent when translating fails
+	gw.SetTranslator(TranslatorFunc(func(text, targetLang string) (string, error) {
+		return "", errors.New("quota exceeded")
+	}))
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#main"})
+	sent = slack.messages()
+	assert.Equal(t, "hello", sent[len(sent)-1].Text)
+}
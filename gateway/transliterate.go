@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/matterbridge/emoji"
+	"golang.org/x/text/unicode/norm"
+)
+
+// transliterations are the ASCII approximations of characters that don't
+// decompose into an ASCII letter and combining marks.
+var transliterations = map[rune]string{
+	'ß': "ss", 'ẞ': "SS",
+	'æ': "ae", 'Æ': "AE",
+	'œ': "oe", 'Œ': "OE",
+	'ø': "o", 'Ø': "O",
+	'đ': "d", 'Đ': "D",
+	'ð': "d", 'Ð': "D",
+	'ł': "l", 'Ł': "L",
+	'þ': "th", 'Þ': "TH",
+	'ı': "i",
+	'‘': "'", '’': "'", '‚': "'",
+	'“': "\"", '”': "\"", '„': "\"",
+	'«': "<<", '»': ">>",
+	'–': "-", '—': "-", '‐': "-",
+	'…': "...",
+	'•': "*",
+	'€': "EUR", '£': "GBP",
+	'©': "(c)", '®': "(r)", '™': "(tm)",
+}
+
+var (
+	emojiCodesOnce sync.Once
+	// emojiCodes maps an emoji to its shortest :code:.
+	emojiCodes map[string]string
+	// emojiMaxRunes is the length in runes of the longest emoji in emojiCodes.
+	emojiMaxRunes int
+)
+
+func loadEmojiCodes() {
+	emojiCodes = make(map[string]string)
+	for code, e := range emoji.CodeMap() {
+		if old, ok := emojiCodes[e]; ok && (len(old) < len(code) || len(old) == len(code) && old < code) {
+			continue
+		}
+		emojiCodes[e] = code
+		if n := utf8.RuneCountInString(e); n > emojiMaxRunes {
+			emojiMaxRunes = n
+		}
+	}
+}
+
+// transliterate returns an ASCII approximation of text for bridges that can't
+// handle unicode. Accents are removed, emoji are replaced by their :code: and
+// other characters without an approximation by "?".
+func transliterate(text string) string {
+	emojiCodesOnce.Do(loadEmojiCodes)
+	runes := []rune(text)
+	var res strings.Builder
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r < utf8.RuneSelf {
+			res.WriteRune(r)
+			continue
+		}
+		if code, n := matchEmoji(runes[i:]); n > 0 {
+			res.WriteString(code)
+			i += n - 1
+			continue
+		}
+		res.WriteString(transliterateRune(r))
+	}
+	return res.String()
+}
+
+// matchEmoji returns the :code: of the longest emoji at the start of runes and
+// its length in runes, including a trailing variation selector.
+func matchEmoji(runes []rune) (string, int) {
+	max := emojiMaxRunes
+	if len(runes) < max {
+		max = len(runes)
+	}
+	for n := max; n > 0; n-- {
+		code, ok := emojiCodes[string(runes[:n])]
+		if !ok {
+			continue
+		}
+		if n < len(runes) && runes[n] == '\uFE0F' {
+			n++
+		}
+		return code, n
+	}
+	return "", 0
+}
+
+// transliterateRune returns an ASCII approximation of the non-ASCII rune r.
+func transliterateRune(r rune) string {
+	if s, ok := transliterations[r]; ok {
+		return s
+	}
+	// variation selectors and joiners have no meaning without the emoji
+	if r == '\uFE0F' || r == '\u200D' {
+		return ""
+	}
+	var res strings.Builder
+	for _, d := range norm.NFKD.String(string(r)) {
+		switch {
+		case d < utf8.RuneSelf:
+			res.WriteRune(d)
+		case unicode.Is(unicode.Mn, d):
+			// drop accents
+		default:
+			return "?"
+		}
+	}
+	if res.Len() == 0 {
+		return "?"
+	}
+	return res.String()
+}
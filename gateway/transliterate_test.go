@@ -0,0 +1,46 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransliterate(t *testing.T) {
+	msgTests := map[string]struct {
+		input  string
+		output string
+	}{
+		"ascii":               {input: "hello world", output: "hello world"},
+		"accents":             {input: "café crème brûlée", output: "cafe creme brulee"},
+		"uppercase accents":   {input: "ÉCOLE Ñandú", output: "ECOLE Nandu"},
+		"table":               {input: "Straße Ærø Łódź", output: "Strasse AEro Lodz"},
+		"punctuation":         {input: "“quoted” – it’s…", output: "\"quoted\" - it's..."},
+		"emoji":               {input: "great 👍", output: "great :+1:"},
+		"emoji with selector": {input: "love ❤️ you", output: "love :heart: you"},
+		"unknown":             {input: "日本", output: "??"},
+		"ligature":            {input: "ﬁne", output: "fine"},
+	}
+	for testname, testcase := range msgTests {
+		assert.Equalf(t, testcase.output, transliterate(testcase.input), "case '%s' failed", testname)
+	}
+}
+
+func TestSendMessageTransliterate(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	dest := gw.Bridges["irc.freenode"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+	dest.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"irc.freenode.Transliterate": true, "irc.freenode.RemoteNickFormat": "<{NICK}> "}}
+
+	r.relayMessage(config.Message{Text: "déjà vu 🎉", Username: "zoë", Account: "discord.test", Channel: "general"})
+
+	sent := testBridgerOf(gw, "irc.freenode").messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "deja vu :tada:", sent[0].Text)
+	assert.Equal(t, "<zoe> ", sent[0].Username)
+	// other bridges get the original text
+	assert.Equal(t, "déjà vu 🎉", testBridgerOf(gw, "slack.test").messages()[0].Text)
+}
@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+const defaultTruncateSuffix = "…"
+
+// The SplitStrategy values other than the default "word".
+const (
+	splitSentence = "sentence"
+	splitHard     = "hard"
+)
+
+// limitMessageLength returns the texts that need to be sent to dest for msg so that
+// none of them is longer than the MaxMessageLength of dest.
+// Edits are always truncated as only one message can be edited.
+func limitMessageLength(msg *config.Message, dest *bridge.Bridge) []string {
+	max := dest.GetInt("MaxMessageLength")
+	if max <= 0 || len([]rune(msg.Text)) <= max {
+		return []string{msg.Text}
+	}
+	if dest.GetBool("SplitLongMessages") && msg.ID == "" {
+		return splitText(msg.Text, max, dest.GetString("SplitStrategy"))
+	}
+	suffix := dest.GetString("TruncateSuffix")
+	if suffix == "" {
+		suffix = defaultTruncateSuffix
+	}
+	return []string{truncateText(msg.Text, max, suffix)}
+}
+
+// truncateText cuts text to at most max runes, including the appended suffix.
+func truncateText(text string, max int, suffix string) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	keep := max - len([]rune(suffix))
+	if keep <= 0 {
+		return string(runes[:max])
+	}
+	return string(runes[:keep]) + suffix
+}
+
+// splitText splits text in parts of at most max runes. Depending on strategy a part ends
+// at the end of a sentence or at whitespace when possible, so words don't get split.
+// "hard" always splits at max runes, the default is "word".
+func splitText(text string, max int, strategy string) []string {
+	var parts []string
+	runes := []rune(text)
+	for len(runes) > max {
+		end := splitPoint(runes, max, strategy)
+		parts = append(parts, strings.TrimRightFunc(string(runes[:end]), unicode.IsSpace))
+		runes = []rune(strings.TrimLeftFunc(string(runes[end:]), unicode.IsSpace))
+	}
+	if len(runes) > 0 {
+		parts = append(parts, string(runes))
+	}
+	return parts
+}
+
+// splitPoint returns where to end the next part of runes, which is longer than max.
+// Sentences end at a newline or at whitespace after '.', '!' or '?'. Without a sentence
+// boundary it falls back to whitespace in the second half of the part and then to max.
+func splitPoint(runes []rune, max int, strategy string) int {
+	if strategy == splitHard {
+		return max
+	}
+	if strategy == splitSentence {
+		for i := max; i > 0; i-- {
+			if runes[i] == '\n' || unicode.IsSpace(runes[i]) && strings.ContainsRune(".!?", runes[i-1]) {
+				return i
+			}
+		}
+	}
+	for i := max; i > max/2; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return i
+		}
+	}
+	return max
+}
@@ -0,0 +1,179 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitMessageLength(t *testing.T) {
+	r := maketestRouter(testconfig)
+	gw := r.Gateways["bridge1"]
+	dest := gw.Bridges["slack.test"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+
+	msgTests := map[string]struct {
+		msg       *config.Message
+		overrides map[string]interface{}
+		output    []string
+	}{
+		"no limit": {
+			msg:    &config.Message{Text: "a long message"},
+			output: []string{"a long message"},
+		},
+		"short enough": {
+			msg:       &config.Message{Text: "short"},
+			overrides: map[string]interface{}{"slack.test.MaxMessageLength": 5},
+			output:    []string{"short"},
+		},
+		"truncate": {
+			msg:       &config.Message{Text: "a long message"},
+			overrides: map[string]interface{}{"slack.test.MaxMessageLength": 7},
+			output:    []string{"a long…"},
+		},
+		"truncate runes": {
+			msg:       &config.Message{Text: "ünïcödé text"},
+			overrides: map[string]interface{}{"slack.test.MaxMessageLength": 8},
+			output:    []string{"ünïcödé…"},
+		},
+		"truncate suffix": {
+			msg: &config.Message{Text: "a long message"},
+			overrides: map[string]interface{}{
+				"slack.test.MaxMessageLength": 9,
+				"slack.test.TruncateSuffix":   "...",
+			},
+			output: []string{"a long..."},
+		},
+		"split": {
+			msg: &config.Message{Text: "split this long message"},
+			overrides: map[string]interface{}{
+				"slack.test.MaxMessageLength":  12,
+				"slack.test.SplitLongMessages": true,
+			},
+			output: []string{"split this", "long message"},
+		},
+		"split without whitespace": {
+			msg: &config.Message{Text: "abcdefghij"},
+			overrides: map[string]interface{}{
+				"slack.test.MaxMessageLength":  4,
+				"slack.test.SplitLongMessages": true,
+			},
+			output: []string{"abcd", "efgh", "ij"},
+		},
+		"split strategy": {
+			msg: &config.Message{Text: "Split this. Long message"},
+			overrides: map[string]interface{}{
+				"slack.test.MaxMessageLength":  16,
+				"slack.test.SplitLongMessages": true,
+				"slack.test.SplitStrategy":     "sentence",
+			},
+			output: []string{"Split this.", "Long message"},
+		},
+		"split edit": {
+			msg: &config.Message{Text: "split this long message", ID: "1"},
+			overrides: map[string]interface{}{
+				"slack.test.MaxMessageLength":  10,
+				"slack.test.SplitLongMessages": true,
+			},
+			output: []string{"split thi…"},
+		},
+	}
+	for testname, testcase := range msgTests {
+		dest.Config = &config.TestConfig{Config: cfg, Overrides: testcase.overrides}
+		assert.Equalf(t, testcase.output, limitMessageLength(testcase.msg, dest), "case '%s' failed", testname)
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	for testname, testcase := range map[string]struct {
+		text     string
+		max      int
+		strategy string
+		output   []string
+	}{
+		"word": {
+			text: "one two three four", max: 10, strategy: "word",
+			output: []string{"one two", "three four"},
+		},
+		"word default": {
+			text: "one two three four", max: 10,
+			output: []string{"one two", "three four"},
+		},
+		"word longer than limit": {
+			text: "a abcdefghijkl b", max: 5, strategy: "word",
+			output: []string{"a abc", "defgh", "ijkl", "b"},
+		},
+		"word unknown strategy": {
+			text: "one two three four", max: 10, strategy: "paragraph",
+			output: []string{"one two", "three four"},
+		},
+		"sentence": {
+			text: "Hi there. How are you doing today?", max: 25, strategy: "sentence",
+			output: []string{"Hi there.", "How are you doing today?"},
+		},
+		"sentence question and exclamation": {
+			text: "Really? Yes! Good.", max: 14, strategy: "sentence",
+			output: []string{"Really? Yes!", "Good."},
+		},
+		"sentence newline": {
+			text: "first line\nsecond line", max: 15, strategy: "sentence",
+			output: []string{"first line", "second line"},
+		},
+		"sentence ends at limit": {
+			text: "Hi there. Bye.", max: 9, strategy: "sentence",
+			output: []string{"Hi there.", "Bye."},
+		},
+		"sentence falls back to word": {
+			text: "no sentence end in here", max: 12, strategy: "sentence",
+			output: []string{"no sentence", "end in here"},
+		},
+		"sentence dot inside word": {
+			text: "see example.com for more", max: 16, strategy: "sentence",
+			output: []string{"see example.com", "for more"},
+		},
+		"sentence longer than limit": {
+			text: "Supercalifragilistic.", max: 8, strategy: "sentence",
+			output: []string{"Supercal", "ifragili", "stic."},
+		},
+		"hard": {
+			text: "one two three", max: 5, strategy: "hard",
+			output: []string{"one t", "wo th", "ree"},
+		},
+		"hard runes": {
+			text: "ünïcödé", max: 3, strategy: "hard",
+			output: []string{"ünï", "cöd", "é"},
+		},
+	} {
+		assert.Equalf(t, testcase.output, splitText(testcase.text, testcase.max, testcase.strategy), "case '%s' failed", testname)
+	}
+}
+
+func TestSendMessageSplit(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	dest := gw.Bridges["slack.test"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+	dest.Config = &config.TestConfig{
+		Config: cfg,
+		Overrides: map[string]interface{}{
+			"slack.test.MaxMessageLength":  12,
+			"slack.test.SplitLongMessages": true,
+		},
+	}
+	slack := testBridgerOf(gw, "slack.test")
+
+	msg := &config.Message{Text: "split this long message", Username: "user", Account: "irc.freenode", Channel: "#wimtesting"}
+	channel := &config.ChannelInfo{Name: "testing", Account: "slack.test", Direction: "inout"}
+	mID, err := gw.SendMessage(msg, dest, channel, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", mID)
+
+	var texts []string
+	for _, m := range slack.messages() {
+		texts = append(texts, m.Text)
+	}
+	assert.Equal(t, []string{"split this", "long message"}, texts)
+}
@@ -0,0 +1,74 @@
+package gateway
+
+import (
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// pendingTyping is a typing event for a destination channel with TypingDebounce enabled
+// that is only forwarded when its debounce elapses.
+type pendingTyping struct {
+	gw      *Gateway
+	key     string
+	msg     config.Message
+	dest    *bridge.Bridge
+	channel *config.ChannelInfo
+	timer   *time.Timer
+}
+
+// typingKey returns the key of the typing of the sender of rmsg on channel. Bridges that
+// don't set the UserID of typing events share one key for the source channel.
+func typingKey(rmsg *config.Message, channel *config.ChannelInfo) string {
+	return channel.ID + " " + getChannelID(rmsg) + " " + rmsg.UserID
+}
+
+// debounceTyping delays the typing event rmsg for channel if TypingDebounce is set on
+// dest, typing events during the debounce are dropped. Any other message of the sender
+// means the typing stopped and cancels the pending typing event. Returns true if rmsg is
+// delayed or dropped.
+func (gw *Gateway) debounceTyping(rmsg *config.Message, dest *bridge.Bridge, channel *config.ChannelInfo) bool {
+	key := typingKey(rmsg, channel)
+	if rmsg.Event != config.EventUserTyping {
+		if t, ok := gw.pendingTyping[key]; ok {
+			t.timer.Stop()
+			delete(gw.pendingTyping, key)
+		}
+		return false
+	}
+	debounce := dest.GetInt("TypingDebounce")
+	if debounce <= 0 {
+		return false
+	}
+	if _, ok := gw.pendingTyping[key]; ok {
+		return true
+	}
+	t := &pendingTyping{gw: gw, key: key, msg: *rmsg, dest: dest, channel: channel}
+	t.timer = time.AfterFunc(time.Duration(debounce)*time.Millisecond, func() {
+		gw.Router.typingExpired <- t
+	})
+	gw.pendingTyping[key] = t
+	return true
+}
+
+// sendTyping forwards the typing event of t, unless it was cancelled.
+func (gw *Gateway) sendTyping(t *pendingTyping) {
+	if gw.pendingTyping[t.key] != t {
+		return
+	}
+	delete(gw.pendingTyping, t.key)
+	t.timer.Stop()
+	if _, err := gw.SendMessage(&t.msg, t.dest, t.channel, ""); err != nil {
+		gw.logger.Errorf("SendMessage failed: %s", err)
+	}
+}
+
+// cancelTyping drops all pending typing events of gw, they're stale by the time they
+// would be sent.
+func (gw *Gateway) cancelTyping() {
+	for key, t := range gw.pendingTyping {
+		t.timer.Stop()
+		delete(gw.pendingTyping, key)
+	}
+}
@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigTyping = []byte(`
+[irc.test]
+server=""
+[slack.test]
+server=""
+TypingDebounce=60000
+[discord.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="main"
+`)
+
+func TestTypingDebounce(t *testing.T) {
+	r := maketestRouterWithMap(testconfigTyping, testBridgeMap)
+	gw := r.Gateways["main"]
+	slack := testBridgerOf(gw, "slack.test")
+	discord := testBridgerOf(gw, "discord.test")
+	typing := config.Message{Account: "irc.test", Channel: "#main", UserID: "alice", Event: config.EventUserTyping}
+
+	// quick toggles are cancelled by the message of the sender
+	for i := 0; i < 3; i++ {
+		r.relayMessage(typing)
+		r.relayMessage(config.Message{Text: "hi", Username: "alice", UserID: "alice", Account: "irc.test", Channel: "#main"})
+	}
+	assert.Empty(t, gw.pendingTyping)
+	for _, msg := range slack.messages() {
+		assert.NotEqual(t, config.EventUserTyping, msg.Event)
+	}
+	assert.Len(t, slack.messages(), 3)
+	// destinations without TypingDebounce get every typing event
+	assert.Len(t, discord.messages(), 6)
+
+	// sustained typing is forwarded once when the debounce elapses
+	r.relayMessage(typing)
+	r.relayMessage(typing)
+	// a message of another user doesn't stop the typing
+	r.relayMessage(config.Message{Text: "hello", Username: "bob", UserID: "bob", Account: "irc.test", Channel: "#main"})
+	assert.Len(t, gw.pendingTyping, 1)
+	for _, p := range gw.pendingTyping {
+		gw.sendTyping(p)
+	}
+	sent := slack.messages()
+	assert.Len(t, sent, 5)
+	assert.Equal(t, config.EventUserTyping, sent[4].Event)
+	assert.Empty(t, gw.pendingTyping)
+
+	// typing events that are sent late aren't forwarded
+	r.relayMessage(typing)
+	var late *pendingTyping
+	for _, p := range gw.pendingTyping {
+		late = p
+	}
+	gw.cancelTyping()
+	gw.sendTyping(late)
+	assert.Len(t, slack.messages(), 5)
+}
@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"html"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+const (
+	// unfurlTimeout is the maximum time fetching the title of a link may take.
+	unfurlTimeout = 5 * time.Second
+	// unfurlMaxSize is the maximum number of bytes of a page read to find its title.
+	unfurlMaxSize = 64 * 1024
+	// linkTitleTTL is how long fetched titles are cached, also when there was no title.
+	linkTitleTTL = 10 * time.Minute
+)
+
+var titleRE = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+type linkTitle struct {
+	title   string
+	fetched time.Time
+}
+
+// singleURL returns the URL if text only consists of one http(s) URL.
+func singleURL(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.ContainsAny(text, " \t\n") {
+		return "", false
+	}
+	u, err := url.Parse(text)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+	return text, true
+}
+
+// parseTitle returns the unescaped title of the HTML page, with whitespace collapsed.
+func parseTitle(page []byte) string {
+	m := titleRE.FindSubmatch(page)
+	if m == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+}
+
+// fetchTitle returns the title of the HTML page at link, or an empty string if it
+// has none or can't be fetched.
+func (gw *Gateway) fetchTitle(link string) string {
+	client := &http.Client{
+		Timeout: unfurlTimeout,
+	}
+	resp, err := client.Get(link)
+	if err != nil {
+		gw.logger.Debugf("fetching title of %s failed: %s", link, err)
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		return ""
+	}
+	page, err := ioutil.ReadAll(&io.LimitedReader{R: resp.Body, N: unfurlMaxSize})
+	if err != nil {
+		gw.logger.Debugf("fetching title of %s failed: %s", link, err)
+		return ""
+	}
+	return parseTitle(page)
+}
+
+// linkTitle returns the title of link, from the cache when it was fetched recently.
+func (gw *Gateway) linkTitle(link string) string {
+	if v, ok := gw.linkTitles.Get(link); ok {
+		if cached := v.(linkTitle); gw.now().Sub(cached.fetched) < linkTitleTTL {
+			return cached.title
+		}
+	}
+	title := gw.fetchTitle(link)
+	gw.linkTitles.Add(link, linkTitle{title: title, fetched: gw.now()})
+	return title
+}
+
+// unfurlLink appends the title of the page to the text of msg if the text of rmsg, the
+// received message, is only a link. Edits aren't unfurled.
+func (gw *Gateway) unfurlLink(rmsg *config.Message, msg *config.Message) {
+	if msg.Event != "" || msg.ID != "" {
+		return
+	}
+	link, ok := singleURL(rmsg.Text)
+	if !ok {
+		return
+	}
+	if title := gw.linkTitle(link); title != "" {
+		msg.Text += " - " + title
+	}
+}
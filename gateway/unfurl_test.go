@@ -0,0 +1,103 @@
+package gateway
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingleURL(t *testing.T) {
+	msgTests := map[string]struct {
+		text string
+		ok   bool
+	}{
+		"url":           {text: "https://example.com/page?a=1", ok: true},
+		"url spaces":    {text: "  http://example.com\n", ok: true},
+		"text and url":  {text: "look https://example.com"},
+		"two urls":      {text: "https://example.com https://example.org"},
+		"other scheme":  {text: "ftp://example.com"},
+		"no host":       {text: "https:///path"},
+		"word":          {text: "hello"},
+		"empty message": {text: ""},
+	}
+	for testname, testcase := range msgTests {
+		_, ok := singleURL(testcase.text)
+		assert.Equalf(t, testcase.ok, ok, "case '%s' failed", testname)
+	}
+}
+
+func TestParseTitle(t *testing.T) {
+	assert.Equal(t, "Tom & Jerry", parseTitle([]byte("<html><head><TITLE lang=\"en\">\n  Tom &amp;\n Jerry </TITLE></head></html>")))
+	assert.Equal(t, "", parseTitle([]byte("<html><body>no title</body></html>")))
+}
+
+func TestUnfurlLinks(t *testing.T) {
+	var hits int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch req.URL.Path {
+		case "/titled":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, "<html><head><title>A titled page</title></head></html>")
+		case "/large":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html><head>"+strings.Repeat(" ", unfurlMaxSize)+"<title>Too far</title></head></html>")
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			fmt.Fprint(w, "<title>Not a page</title>")
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer server.Close()
+
+	r := maketestRouterWithMap(testconfigUpdate, testBridgeMap)
+	gw := r.Gateways["main"]
+	discord := gw.Bridges["discord.test"]
+	cfg := discord.Config
+	defer func() { discord.Config = cfg }()
+	discord.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"discord.test.UnfurlLinks": true}}
+	now := time.Now()
+	gw.now = func() time.Time { return now }
+
+	send := func(text string) {
+		r.relayMessage(config.Message{Text: text, Username: "bot", Account: "api.test", Channel: "api", Gateway: "main"})
+	}
+	lastText := func(account string) string {
+		sent := testBridgerOf(gw, account).messages()
+		require.NotEmpty(t, sent)
+		return sent[len(sent)-1].Text
+	}
+
+	send(server.URL + "/titled")
+	assert.Equal(t, server.URL+"/titled - A titled page", lastText("discord.test"))
+	// irc doesn't have UnfurlLinks
+	assert.Equal(t, server.URL+"/titled", lastText("irc.test"))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	send("see " + server.URL + "/titled")
+	assert.Equal(t, "see "+server.URL+"/titled", lastText("discord.test"))
+
+	for _, path := range []string{"/large", "/image", "/missing"} {
+		send(server.URL + path)
+		assert.Equal(t, server.URL+path, lastText("discord.test"), path)
+	}
+
+	// the title is cached
+	send(server.URL + "/titled")
+	assert.Equal(t, server.URL+"/titled - A titled page", lastText("discord.test"))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&hits))
+
+	now = now.Add(linkTitleTTL)
+	send(server.URL + "/titled")
+	assert.Equal(t, server.URL+"/titled - A titled page", lastText("discord.test"))
+	assert.Equal(t, int32(5), atomic.LoadInt32(&hits))
+}
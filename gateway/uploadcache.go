@@ -0,0 +1,53 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// hasFiles returns true if msg has attached files.
+func hasFiles(msg *config.Message) bool {
+	return msg.Extra != nil && len(msg.Extra["file"]) > 0
+}
+
+// uploadKey returns the key of the upload cache for a file with data sent to dest.
+func uploadKey(dest *bridge.Bridge, data []byte) string {
+	return fmt.Sprintf("%s %x", dest.Account, sha256.Sum256(data))
+}
+
+// sendCachedUploads sends the files of msg to dest using uploader. Files that
+// were uploaded to dest before are not uploaded again, instead a message with the
+// cached remote URL of the file is sent.
+func (gw *Gateway) sendCachedUploads(msg config.Message, dest *bridge.Bridge, uploader bridge.FileUploader) error {
+	for _, f := range msg.Extra["file"] {
+		fi := f.(config.FileInfo)
+		if fi.Data == nil {
+			continue
+		}
+		key := uploadKey(dest, *fi.Data)
+		if v, ok := gw.uploads.Get(key); ok {
+			gw.logger.Debugf("file %s already uploaded to %s, sending %s", fi.Name, dest.Account, v)
+			link := msg
+			link.Extra = nil
+			link.Text = v.(string)
+			if fi.Comment != "" {
+				link.Text = fi.Comment + " " + link.Text
+			}
+			if _, err := gw.send(dest, link); err != nil {
+				return err
+			}
+			continue
+		}
+		url, err := uploader.UploadFile(msg, fi)
+		if err != nil {
+			return err
+		}
+		if url != "" {
+			gw.uploads.Add(key, url)
+		}
+	}
+	return nil
+}
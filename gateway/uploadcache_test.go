@@ -0,0 +1,118 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigUploads = []byte(`
+[slack.test]
+server=""
+[discord.test]
+server=""
+CacheUploads=true
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="one"
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="two"
+`)
+
+// testUploadBridger is a testBridger that uploads files on its own.
+type testUploadBridger struct {
+	*testBridger
+
+	uploads []config.FileInfo
+}
+
+func newTestUploadBridger(cfg *bridge.Config) bridge.Bridger {
+	return &testUploadBridger{testBridger: &testBridger{}}
+}
+
+func (b *testUploadBridger) UploadFile(msg config.Message, fi config.FileInfo) (string, error) {
+	b.Lock()
+	defer b.Unlock()
+	b.uploads = append(b.uploads, fi)
+	return "https://cdn.example.com/" + fi.Name, nil
+}
+
+func TestCacheUploads(t *testing.T) {
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["discord"] = newTestUploadBridger
+
+	r := maketestRouterWithMap(testconfigUploads, bridgeMap)
+	gw := r.Gateways["main"]
+	discord := gw.Bridges["discord.test"].Bridger.(*testUploadBridger)
+
+	data := []byte("image data")
+	msg := config.Message{
+		Username: "user", Account: "slack.test", Channel: "main",
+		Extra: map[string][]interface{}{
+			"file": {config.FileInfo{Name: "cat.png", Data: &data, Comment: "look"}},
+		},
+	}
+	r.relayMessage(msg)
+	r.relayMessage(msg)
+
+	// uploaded once for both channels and both messages
+	assert.Len(t, discord.uploads, 1)
+	sent := discord.messages()
+	assert.Len(t, sent, 3)
+	for _, m := range sent {
+		assert.Equal(t, "look https://cdn.example.com/cat.png", m.Text)
+		assert.Nil(t, m.Extra)
+	}
+
+	// other content is uploaded again
+	other := []byte("other data")
+	msg.Extra = map[string][]interface{}{
+		"file": {config.FileInfo{Name: "cat.png", Data: &other}},
+	}
+	r.relayMessage(msg)
+	assert.Len(t, discord.uploads, 2)
+}
+
+func TestCacheUploadsDisabled(t *testing.T) {
+	bridgeMap := map[string]bridge.Factory{}
+	for protocol, factory := range testBridgeMap {
+		bridgeMap[protocol] = factory
+	}
+	bridgeMap["discord"] = newTestUploadBridger
+
+	r := maketestRouterWithMap(testconfigUploads, bridgeMap)
+	gw := r.Gateways["main"]
+	dest := gw.Bridges["discord.test"]
+	dest.Config = &config.TestConfig{
+		Config:    dest.Config,
+		Overrides: map[string]interface{}{"discord.test.CacheUploads": false},
+	}
+	discord := dest.Bridger.(*testUploadBridger)
+
+	data := []byte("image data")
+	msg := config.Message{
+		Username: "user", Account: "slack.test", Channel: "main",
+		Extra: map[string][]interface{}{
+			"file": {config.FileInfo{Name: "cat.png", Data: &data}},
+		},
+	}
+	r.relayMessage(msg)
+	assert.Len(t, discord.uploads, 0)
+	assert.Len(t, discord.messages(), 2)
+}
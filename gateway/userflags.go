@@ -0,0 +1,40 @@
+package gateway
+
+import (
+	"strings"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// prefixUserFlags prefixes the username of msg with the prefix of the first entry
+// of UserFlagPrefixes of the source bridge that matches one of the flags of the user.
+func (gw *Gateway) prefixUserFlags(msg *config.Message) error {
+	if len(msg.UserFlags) == 0 {
+		return nil
+	}
+	br := gw.Router.getBridge(msg.Account)
+	if br == nil {
+		return nil
+	}
+	for _, outer := range br.GetStringSlice2D("UserFlagPrefixes") {
+		if len(outer) != 2 || !hasUserFlag(msg, outer[0]) {
+			continue
+		}
+		// the message may already have been modified by another gateway
+		if !strings.HasPrefix(msg.Username, outer[1]) {
+			msg.Username = outer[1] + msg.Username
+		}
+		return nil
+	}
+	return nil
+}
+
+// hasUserFlag returns true if the author of msg has flag.
+func hasUserFlag(msg *config.Message, flag string) bool {
+	for _, f := range msg.UserFlags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
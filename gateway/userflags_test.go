@@ -0,0 +1,77 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+var testconfigUserFlags = []byte(`
+[irc.test]
+server=""
+UserFlagPrefixes=[ ["owner","👑 "], ["op","@"] ]
+[slack.test]
+server=""
+RemoteNickFormat="<{NICK}> "
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+
+[[gateway]]
+name="other"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="other"
+`)
+
+func TestPrefixUserFlags(t *testing.T) {
+	r := maketestRouterWithMap(testconfigUserFlags, testBridgeMap)
+	gw := r.Gateways["main"]
+
+	msgTests := map[string]struct {
+		flags  []string
+		output string
+	}{
+		"no flags":     {output: "alice"},
+		"owner":        {flags: []string{config.UserFlagOwner}, output: "👑 alice"},
+		"op":           {flags: []string{config.UserFlagOp}, output: "@alice"},
+		"first match":  {flags: []string{config.UserFlagOp, config.UserFlagOwner}, output: "👑 alice"},
+		"no prefix":    {flags: []string{config.UserFlagVoice}, output: "alice"},
+		"unknown flag": {flags: []string{"moderator"}, output: "alice"},
+	}
+	for testname, testcase := range msgTests {
+		msg := &config.Message{Username: "alice", Account: "irc.test", Channel: "#main", UserFlags: testcase.flags}
+		assert.NoError(t, gw.prefixUserFlags(msg))
+		assert.Equalf(t, testcase.output, msg.Username, "case '%s' failed", testname)
+	}
+}
+
+func TestRelayUserFlags(t *testing.T) {
+	r := maketestRouterWithMap(testconfigUserFlags, testBridgeMap)
+	slack := testBridgerOf(r.Gateways["main"], "slack.test")
+
+	r.relayMessage(config.Message{Text: "hello", Username: "alice", Account: "irc.test", Channel: "#main", UserFlags: []string{config.UserFlagOwner}})
+
+	// the prefix is only added once when relaying to multiple gateways
+	sent := slack.messages()
+	assert.Len(t, sent, 2)
+	for _, msg := range sent {
+		assert.Equal(t, "<👑 alice> ", msg.Username)
+	}
+}
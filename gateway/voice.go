@@ -0,0 +1,31 @@
+package gateway
+
+import (
+	"fmt"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// isVoiceActivity returns true if msg is a user joining or leaving a voice channel.
+// The username of these messages is the user and the text the name of the voice channel.
+func isVoiceActivity(msg *config.Message) bool {
+	return msg.Event == config.EventVoiceJoin || msg.Event == config.EventVoiceLeave
+}
+
+// voiceActivityText returns the text used to relay the voice activity msg.
+func voiceActivityText(msg *config.Message) string {
+	if msg.Event == config.EventVoiceLeave {
+		return fmt.Sprintf("%s left voice %s", msg.Username, msg.Text)
+	}
+	return fmt.Sprintf("%s joined voice %s", msg.Username, msg.Text)
+}
+
+// voiceActivityMessage returns the voice activity msg as a message from "system", like the
+// join/leave messages, so it can be sent to every bridge.
+func voiceActivityMessage(msg *config.Message) config.Message {
+	voice := *msg
+	voice.Text = voiceActivityText(msg)
+	voice.Username = "system"
+	voice.Event = ""
+	return voice
+}
@@ -0,0 +1,65 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigVoice = []byte(`
+[general]
+RemoteNickFormat="{NICK}: "
+[discord.test]
+server=""
+[irc.test]
+server=""
+ShowVoiceActivity=true
+[slack.test]
+server=""
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="discord.test"
+    channel="general"
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.inout]]
+    account="slack.test"
+    channel="main"
+`)
+
+func TestVoiceActivityText(t *testing.T) {
+	join := &config.Message{Username: "alice", Text: "Gaming", Event: config.EventVoiceJoin}
+	assert.Equal(t, "alice joined voice Gaming", voiceActivityText(join))
+	leave := &config.Message{Username: "alice", Text: "Gaming", Event: config.EventVoiceLeave}
+	assert.Equal(t, "alice left voice Gaming", voiceActivityText(leave))
+}
+
+func TestVoiceActivity(t *testing.T) {
+	r := maketestRouterWithMap(testconfigVoice, testBridgeMap)
+	gw := r.Gateways["main"]
+	irc := testBridgerOf(gw, "irc.test")
+	slack := testBridgerOf(gw, "slack.test")
+
+	r.relayMessage(config.Message{Username: "alice", Text: "Gaming", Account: "discord.test", Event: config.EventVoiceJoin})
+	r.relayMessage(config.Message{Username: "alice", Text: "Gaming", Account: "discord.test", Event: config.EventVoiceLeave})
+
+	sent := irc.messages()
+	require.Len(t, sent, 2)
+	assert.Equal(t, "alice joined voice Gaming", sent[0].Text)
+	assert.Equal(t, "system: ", sent[0].Username)
+	assert.Equal(t, "", sent[0].Event)
+	assert.Equal(t, "#main", sent[0].Channel)
+	assert.Equal(t, "alice left voice Gaming", sent[1].Text)
+
+	// slack didn't opt in
+	assert.Empty(t, slack.messages())
+}
@@ -0,0 +1,84 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/42wim/matterbridge/bridge"
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// webhookProtocol is the protocol of the webhook pseudo-destinations. Their messages
+// aren't sent by a bridge but POSTed to WebhookURL by the gateway, see sendWebhook.
+const webhookProtocol = "webhook"
+
+// defaultPayloadTemplate is the payload sent to a webhook without PayloadTemplate.
+const defaultPayloadTemplate = `{"text":{{json .Text}},"username":{{json .Username}},"channel":{{json .Channel}},"gateway":{{json .Gateway}}}`
+
+var payloadFuncs = template.FuncMap{
+	// json returns v as JSON, so strings are quoted and escaped
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// webhookBridger is the bridger of webhook destinations, there's nothing to connect to.
+type webhookBridger struct{}
+
+func (webhookBridger) Connect() error                               { return nil }
+func (webhookBridger) JoinChannel(channel config.ChannelInfo) error { return nil }
+func (webhookBridger) Disconnect() error                            { return nil }
+
+func (webhookBridger) Send(msg config.Message) (string, error) {
+	return "", fmt.Errorf("webhook messages are sent by the gateway")
+}
+
+// webhookPayload executes the PayloadTemplate of dest with msg.
+func webhookPayload(msg config.Message, dest *bridge.Bridge) ([]byte, error) {
+	text := dest.GetString("PayloadTemplate")
+	if text == "" {
+		text = defaultPayloadTemplate
+	}
+	tmpl, err := template.New(dest.Account).Funcs(payloadFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PayloadTemplate: %s", err)
+	}
+	var payload bytes.Buffer
+	if err := tmpl.Execute(&payload, msg); err != nil {
+		return nil, fmt.Errorf("PayloadTemplate failed: %s", err)
+	}
+	return payload.Bytes(), nil
+}
+
+// sendWebhook POSTs msg formatted with the PayloadTemplate of dest to its WebhookURL.
+// Responses other than 2xx are returned as an error.
+func (gw *Gateway) sendWebhook(msg config.Message, dest *bridge.Bridge) (string, error) {
+	payload, err := webhookPayload(msg, dest)
+	if err != nil {
+		return "", err
+	}
+	timeout := sendTimeout(dest)
+	if timeout <= 0 {
+		timeout = time.Second * 10
+	}
+	client := &http.Client{
+		Timeout: timeout,
+	}
+	resp, err := client.Post(dest.GetString("WebhookURL"), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("webhook %s failed: %s", dest.Account, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("webhook %s failed: %s", dest.Account, resp.Status)
+	}
+	return "", nil
+}
@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testconfigWebhook = `
+[irc.test]
+server=""
+[general]
+RemoteNickFormat="{NICK}"
+[webhook.default]
+WebhookURL="%[1]s/default"
+[webhook.custom]
+WebhookURL="%[1]s/custom"
+PayloadTemplate='{"content":{{json .Text}},"author":{"name":{{json .Username}}},"source":"{{.Protocol}}"}'
+[webhook.broken]
+WebhookURL="%[1]s/broken"
+
+[[gateway]]
+name="main"
+enable=true
+
+    [[gateway.inout]]
+    account="irc.test"
+    channel="#main"
+
+    [[gateway.out]]
+    account="webhook.default"
+    channel="default"
+
+    [[gateway.out]]
+    account="webhook.custom"
+    channel="custom"
+
+    [[gateway.out]]
+    account="webhook.broken"
+    channel="broken"
+`
+
+func TestSendWebhook(t *testing.T) {
+	payloads := make(map[string]map[string]interface{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/broken" {
+			http.Error(w, "nope", http.StatusBadGateway)
+			return
+		}
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		body, _ := ioutil.ReadAll(req.Body)
+		var payload map[string]interface{}
+		assert.NoError(t, json.Unmarshal(body, &payload), string(body))
+		payloads[req.URL.Path] = payload
+	}))
+	defer server.Close()
+
+	r := maketestRouterWithMap([]byte(fmt.Sprintf(testconfigWebhook, server.URL)), testBridgeMap)
+	gw := r.Gateways["main"]
+	msg := &config.Message{Text: `say "hi"`, Username: "alice", Account: "irc.test", Channel: "#main", Protocol: "irc", Gateway: "main"}
+
+	for _, account := range []string{"webhook.default", "webhook.custom", "webhook.broken"} {
+		dest := gw.Bridges[account]
+		require.NotNil(t, dest, account)
+		// the channels of the webhooks are named like the webhooks
+		channel := gw.Channels[dest.Name+account]
+		require.NotNil(t, channel, account)
+		_, err := gw.SendMessage(msg, dest, channel, "")
+		if account == "webhook.broken" {
+			assert.EqualError(t, err, "webhook webhook.broken failed: 502 Bad Gateway")
+			continue
+		}
+		assert.NoError(t, err, account)
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"text": `say "hi"`, "username": "alice", "channel": "default", "gateway": "main",
+	}, payloads["/default"])
+	assert.Equal(t, map[string]interface{}{
+		"content": `say "hi"`, "author": map[string]interface{}{"name": "alice"}, "source": "irc",
+	}, payloads["/custom"])
+}
+
+func TestWebhookPayloadInvalid(t *testing.T) {
+	r := maketestRouterWithMap([]byte(fmt.Sprintf(testconfigWebhook, "http://localhost")), testBridgeMap)
+	dest := r.Gateways["main"].Bridges["webhook.custom"]
+	cfg := dest.Config
+	defer func() { dest.Config = cfg }()
+
+	dest.Config = &config.TestConfig{Config: cfg, Overrides: map[string]interface{}{"webhook.custom.PayloadTemplate": "{{.Text"}}
+	_, err := webhookPayload(config.Message{Text: "hi"}, dest)
+	assert.Error(t, err)
+}
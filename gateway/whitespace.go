@@ -0,0 +1,56 @@
+package gateway
+
+import (
+	"strings"
+
+	"github.com/42wim/matterbridge/bridge/config"
+)
+
+// maxBlankLines is the number of consecutive blank lines NormalizeWhitespace keeps,
+// longer runs of blank lines are collapsed to a single one.
+const maxBlankLines = 2
+
+// zeroWidthChars are removed by StripZeroWidth. The zero width joiner (U+200D) is kept
+// as it joins the emoji of sequences like the family emoji.
+var zeroWidthChars = strings.NewReplacer(
+	"\u200b", "", // zero width space
+	"\u200c", "", // zero width non-joiner
+	"\u2060", "", // word joiner
+	"\ufeff", "", // zero width no-break space
+)
+
+// normalizeWhitespace trims the trailing whitespace of every line of text and collapses
+// more than maxBlankLines consecutive blank lines to one.
+func normalizeWhitespace(text string) string {
+	lines := strings.Split(text, "\n")
+	out := lines[:0]
+	blank := 0
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line != "" {
+			if blank > maxBlankLines {
+				blank = 1
+			}
+			for ; blank > 0; blank-- {
+				out = append(out, "")
+			}
+			out = append(out, line)
+			continue
+		}
+		blank++
+	}
+	return strings.Join(out, "\n")
+}
+
+// normalizeMessage applies the NormalizeWhitespace and StripZeroWidth of the source bridge
+// to the text of msg. The username isn't changed, so the zero width space of {NOPINGNICK}
+// that is added when sending is kept.
+func (gw *Gateway) normalizeMessage(msg *config.Message) {
+	br := gw.Bridges[msg.Account]
+	if br.GetBool("StripZeroWidth") {
+		msg.Text = zeroWidthChars.Replace(msg.Text)
+	}
+	if br.GetBool("NormalizeWhitespace") {
+		msg.Text = normalizeWhitespace(msg.Text)
+	}
+}
@@ -0,0 +1,74 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/42wim/matterbridge/bridge/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeWhitespace(t *testing.T) {
+	msgTests := map[string]struct {
+		input  string
+		output string
+	}{
+		"unchanged":         {input: "hello\nworld", output: "hello\nworld"},
+		"trailing spaces":   {input: "hello  \nworld\t", output: "hello\nworld"},
+		"windows newlines":  {input: "hello\r\nworld\r\n", output: "hello\nworld"},
+		"leading spaces":    {input: "  indented\n\tcode", output: "  indented\n\tcode"},
+		"two blank lines":   {input: "a\n\n\nb", output: "a\n\n\nb"},
+		"three blank lines": {input: "a\n\n\n\nb", output: "a\n\nb"},
+		"blank with spaces": {input: "a\n \n\t\n  \n\nb", output: "a\n\nb"},
+		"trailing blank":    {input: "a\n\n\n", output: "a"},
+		"empty":             {input: "", output: ""},
+	}
+	for testname, testcase := range msgTests {
+		assert.Equalf(t, testcase.output, normalizeWhitespace(testcase.input), "case '%s' failed", testname)
+	}
+}
+
+func TestNormalizeMessage(t *testing.T) {
+	r := maketestRouterWithMap(testconfig, testBridgeMap)
+	gw := r.Gateways["bridge1"]
+	br := gw.Bridges["irc.freenode"]
+	cfg := br.Config
+	defer func() { br.Config = cfg }()
+
+	msgTests := map[string]struct {
+		text      string
+		overrides map[string]interface{}
+		output    string
+	}{
+		"disabled": {
+			text:   "hi \u200b\n\n\n\nthere",
+			output: "hi \u200b\n\n\n\nthere",
+		},
+		"whitespace": {
+			text:      "hi \u200b\n\n\n\nthere",
+			overrides: map[string]interface{}{"irc.freenode.NormalizeWhitespace": true},
+			output:    "hi \u200b\n\nthere",
+		},
+		"zero width": {
+			text:      "hi \u200bthe\u200cre\u2060\ufeff",
+			overrides: map[string]interface{}{"irc.freenode.StripZeroWidth": true},
+			output:    "hi there",
+		},
+		"zero width joiner": {
+			text:      "\U0001F468\u200d\U0001F469\u200d\U0001F467",
+			overrides: map[string]interface{}{"irc.freenode.StripZeroWidth": true},
+			output:    "\U0001F468\u200d\U0001F469\u200d\U0001F467",
+		},
+		"both": {
+			text:      "hi \u200b\n\n\n\nthere",
+			overrides: map[string]interface{}{"irc.freenode.NormalizeWhitespace": true, "irc.freenode.StripZeroWidth": true},
+			output:    "hi\n\nthere",
+		},
+	}
+	for testname, testcase := range msgTests {
+		br.Config = &config.TestConfig{Config: cfg, Overrides: testcase.overrides}
+		msg := config.Message{Text: testcase.text, Username: "us\u200ber", Account: "irc.freenode", Channel: "#wimtesting"}
+		gw.modifyMessage(&msg)
+		assert.Equalf(t, testcase.output, msg.Text, "case '%s' failed", testname)
+		assert.Equalf(t, "us\u200ber", msg.Username, "case '%s' failed", testname)
+	}
+}
@@ -54,6 +54,7 @@ require (
 	github.com/zfjagann/golang-ring v0.0.0-20190106091943-a88bb6aef447
 	golang.org/x/image v0.0.0-20191214001246-9130b4cfad52
 	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
+	golang.org/x/text v0.3.2
 	gopkg.in/fsnotify.v1 v1.4.7 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0 // indirect
 	gopkg.in/tomb.v1 v1.0.0-20141024135613-dd632973f1e7 // indirect
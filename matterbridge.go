@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/42wim/matterbridge/bridge/config"
 	"github.com/42wim/matterbridge/gateway"
@@ -58,7 +62,18 @@ func main() {
 		logger.Fatalf("Starting gateway failed: %s", err)
 	}
 	logger.Printf("Gateway(s) started succesfully. Now relaying messages")
-	select {}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	<-sig
+	logger.Printf("Shutting down, relaying pending messages")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, gw := range r.Gateways {
+		if err := gw.Shutdown(ctx); err != nil {
+			logger.Errorf("Shutting down gateway %s failed: %s", gw.Name, err)
+		}
+	}
 }
 
 func setupLogger() *logrus.Logger {
@@ -3,6 +3,7 @@ package matterhook
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -148,11 +149,22 @@ func (c *Client) Receive() IMessage {
 
 // Send sends a msg to mattermost incoming webhooks URL.
 func (c *Client) Send(msg OMessage) error {
+	return c.SendContext(context.Background(), msg)
+}
+
+// SendContext sends a msg to mattermost incoming webhooks URL, the request is cancelled
+// when ctx is done.
+func (c *Client) SendContext(ctx context.Context, msg OMessage) error {
 	buf, err := json.Marshal(msg)
 	if err != nil {
 		return err
 	}
-	resp, err := c.httpclient.Post(c.Url, "application/json", bytes.NewReader(buf))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpclient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,35 @@
+package matterhook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendContext(t *testing.T) {
+	unblock := make(chan struct{})
+	received := make(chan OMessage, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg OMessage
+		if err := json.NewDecoder(r.Body).Decode(&msg); err == nil {
+			received <- msg
+		}
+		<-unblock
+	}))
+	defer srv.Close()
+	defer close(unblock)
+	c := New(srv.URL, Config{DisableServer: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.SendContext(ctx, OMessage{Text: "hello"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+	assert.Equal(t, "hello", (<-received).Text)
+}